@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+)
+
+const usage = `usage: cdsl <command> [arguments]
+
+commands:
+    fmt [-l] path...    rewrite XML and JSON definition files in canonical form
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "fmt":
+		os.Exit(runFmt(os.Args[2:]))
+	default:
+		fmt.Fprintf(os.Stderr, "cdsl: unknown command %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runFmt formats the definition files named by args, descending into directories
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "list files whose formatting differs instead of rewriting them")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	status := 0
+	for _, root := range flags.Args() {
+		err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !isDefinitionFile(name) {
+				return nil
+			}
+			if err := formatFile(name, *list); err != nil {
+				fmt.Fprintf(os.Stderr, "cdsl fmt: %v\n", err)
+				status = 1
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cdsl fmt: %v\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// isDefinitionFile reports whether name has an extension that fmt can write
+func isDefinitionFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xml", ".json":
+		return true
+	}
+	return false
+}
+
+// formatFile rewrites a single file in canonical form if it differs, or lists it when list is set
+func formatFile(name string, list bool) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := definitionsource.Format(name, data)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, formatted) {
+		return nil
+	}
+
+	if list {
+		fmt.Println(name)
+		return nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, formatted, info.Mode().Perm())
+}
@@ -20,12 +20,12 @@ func main() {
 	dslInitHelper := registry.NewDslInitialisationHelper()
 	
 	// Register DSL implementations
-	dslInitHelper.RegisterDsl("setState", func() dsl.Dsl { return &dsl.SetState{} })
-	dslInitHelper.RegisterDsl("setVar", func() dsl.Dsl { return &dsl.SetVar{} })
-	dslInitHelper.RegisterDsl("routeTo", func() dsl.Dsl { return &dsl.RouteTo{} })
-	dslInitHelper.RegisterDsl("endRoute", func() dsl.Dsl { return &dsl.EndRoute{} })
-	dslInitHelper.RegisterDsl("await", func() dsl.Dsl { return &dsl.Await{} })
-	dslInitHelper.RegisterDsl("sayHello", func() dsl.Dsl { return &dsl.SayHello{} })
+	if err := dslInitHelper.RegisterLibrary(dsl.CoreLibrary()); err != nil {
+		log.Fatalf("Failed to register core DSLs: %v", err)
+	}
+	if err := dslInitHelper.RegisterDsl("sayHello", func() dsl.Dsl { return &dsl.SayHello{} }); err != nil {
+		log.Fatalf("Failed to register sayHello: %v", err)
+	}
 	
 	// Create the flow registry
 	flowRegistry := registry.NewInMemoryFlowRegistry()
@@ -1,6 +1,7 @@
 package concurrency
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -58,6 +59,13 @@ type LockProvider interface {
 	Release(lock *Lock) error
 }
 
+// ContextLockProvider is a LockProvider whose retries stop when a context.Context is done. The executor calls
+// ObtainContext with the context.Context of the execution when the provider implements it.
+type ContextLockProvider interface {
+	LockProvider
+	ObtainContext(goCtx context.Context, owner string, resource string, duration time.Duration, retries int, retryMaxDuration time.Duration) (*Lock, error)
+}
+
 // LockProviderUnitTestSupport is a simple implementation of LockProvider for unit tests
 type LockProviderUnitTestSupport struct {
 	locks map[string]*Lock
@@ -82,6 +90,14 @@ func (p *LockProviderUnitTestSupport) Obtain(owner string, resource string, dura
 	return lock, nil
 }
 
+// ObtainContext implements ContextLockProvider
+func (p *LockProviderUnitTestSupport) ObtainContext(goCtx context.Context, owner string, resource string, duration time.Duration, retries int, retryMaxDuration time.Duration) (*Lock, error) {
+	if err := goCtx.Err(); err != nil {
+		return nil, err
+	}
+	return p.Obtain(owner, resource, duration, retries, retryMaxDuration)
+}
+
 // Release implements LockProvider
 func (p *LockProviderUnitTestSupport) Release(lock *Lock) error {
 	delete(p.locks, lock.Resource)
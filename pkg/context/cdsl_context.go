@@ -31,7 +31,14 @@ type CdslContext struct {
 	ID            string            `json:"id"`
 	State         State             `json:"state"`
 	CurrentFlow   string            `json:"currentFlow"`
+	// FlowVersion, FlowChecksum and DocumentChecksum identify the flow definition the context was created with
+	FlowVersion      int            `json:"flowVersion,omitempty"`
+	FlowChecksum     string         `json:"flowChecksum,omitempty"`
+	DocumentChecksum string         `json:"documentChecksum,omitempty"`
 	CurrentStep   string            `json:"currentStep"`
+	// Interrupted is set when the execution of CurrentStep was canceled, so the next execution restarts it
+	// without counting a new transition into it
+	Interrupted   bool              `json:"interrupted,omitempty"`
 	TransientVars map[string]interface{} `json:"-"`
 	Vars          map[string]string `json:"vars"`
 	Transitions   []string          `json:"transitions"`
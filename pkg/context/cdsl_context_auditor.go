@@ -1,5 +1,9 @@
 package context
 
+import (
+	"github.com/rsqn/go-cdsl/pkg/types"
+)
+
 // CdslContextAuditor is responsible for auditing context operations
 type CdslContextAuditor interface {
 	// SetVar audits a variable change
@@ -8,8 +12,8 @@ type CdslContextAuditor interface {
 	// Transition audits a transition between steps
 	Transition(ctx *CdslContext, flowID string, stepID string)
 	
-	// Execute audits the execution of a DSL element
-	Execute(ctx *CdslContext, flowID string, stepID string, dslName string)
+	// Execute audits the execution of a DSL element declared at position
+	Execute(ctx *CdslContext, flowID string, stepID string, dslName string, position types.SourcePosition)
 	
 	// ExecutePostStep audits the execution of a post-step task
 	ExecutePostStep(ctx *CdslContext, flowID string, stepID string, task PostStepTask)
@@ -17,8 +21,8 @@ type CdslContextAuditor interface {
 	// ExecutePostCommit audits the execution of a post-commit task
 	ExecutePostCommit(ctx *CdslContext, flowID string, task PostCommitTask)
 	
-	// Error audits an error
-	Error(ctx *CdslContext, flowID string, stepID string, dslName string, err error)
+	// Error audits an error, raised by the DSL element declared at position when dslName is set
+	Error(ctx *CdslContext, flowID string, stepID string, dslName string, position types.SourcePosition, err error)
 }
 
 // CdslContextAuditorUnitTestSupport is a simple implementation of CdslContextAuditor for unit tests
@@ -36,7 +40,7 @@ func (a *CdslContextAuditorUnitTestSupport) SetVar(ctx *CdslContext, key string,
 func (a *CdslContextAuditorUnitTestSupport) Transition(ctx *CdslContext, flowID string, stepID string) {}
 
 // Execute implements CdslContextAuditor
-func (a *CdslContextAuditorUnitTestSupport) Execute(ctx *CdslContext, flowID string, stepID string, dslName string) {}
+func (a *CdslContextAuditorUnitTestSupport) Execute(ctx *CdslContext, flowID string, stepID string, dslName string, position types.SourcePosition) {}
 
 // ExecutePostStep implements CdslContextAuditor
 func (a *CdslContextAuditorUnitTestSupport) ExecutePostStep(ctx *CdslContext, flowID string, stepID string, task PostStepTask) {}
@@ -45,4 +49,4 @@ func (a *CdslContextAuditorUnitTestSupport) ExecutePostStep(ctx *CdslContext, fl
 func (a *CdslContextAuditorUnitTestSupport) ExecutePostCommit(ctx *CdslContext, flowID string, task PostCommitTask) {}
 
 // Error implements CdslContextAuditor
-func (a *CdslContextAuditorUnitTestSupport) Error(ctx *CdslContext, flowID string, stepID string, dslName string, err error) {}
+func (a *CdslContextAuditorUnitTestSupport) Error(ctx *CdslContext, flowID string, stepID string, dslName string, position types.SourcePosition, err error) {}
@@ -31,3 +31,14 @@ func (r *CdslContextRepositoryUnitTestSupport) SaveContext(transactionID string,
 func (r *CdslContextRepositoryUnitTestSupport) GetContext(transactionID string, contextID string) (*CdslContext, error) {
 	return r.contexts[contextID], nil
 }
+
+// CountLiveContexts counts the contexts pinned to a version of a flow that have not ended
+func (r *CdslContextRepositoryUnitTestSupport) CountLiveContexts(flowID string, version int) (int, error) {
+	count := 0
+	for _, ctx := range r.contexts {
+		if ctx.CurrentFlow == flowID && ctx.FlowVersion == version && ctx.State != StateEnd {
+			count++
+		}
+	}
+	return count, nil
+}
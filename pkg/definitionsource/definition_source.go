@@ -0,0 +1,236 @@
+package definitionsource
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// DefinitionSource provides flow definition documents
+type DefinitionSource interface {
+	// LoadDocument loads a single document by path
+	LoadDocument(path string) (*DocumentDefinition, error)
+
+	// LoadAll loads every document the source provides
+	LoadAll() ([]*DocumentDefinition, error)
+}
+
+// DocumentParser parses the content of a single definition document
+type DocumentParser interface {
+	// ParseDocument parses a document, using name to identify it in errors
+	ParseDocument(name string, reader io.Reader) (*DocumentDefinition, error)
+}
+
+// signatureExt is appended to a document's name to find its detached signature
+const signatureExt = ".sig"
+
+// FSDefinitionSource loads flow definitions from an fs.FS, choosing a parser by file extension.
+// Any fs.FS will do: os.DirFS, embed.FS, a zip.Reader or a testing/fstest.MapFS.
+type FSDefinitionSource struct {
+	fsys    fs.FS
+	parsers map[string]DocumentParser
+}
+
+// NewFSDefinitionSource creates a new FSDefinitionSource that understands XML, JSON and YAML documents
+func NewFSDefinitionSource(fsys fs.FS) *FSDefinitionSource {
+	return NewFSDefinitionSourceWithParsers(fsys, defaultParsers())
+}
+
+// defaultParsers returns the built in parsers keyed by file extension
+func defaultParsers() map[string]DocumentParser {
+	return map[string]DocumentParser{
+		".xml":  XmlDocumentParser{},
+		".json": JsonDocumentParser{},
+		".yaml": YamlDocumentParser{},
+		".yml":  YamlDocumentParser{},
+	}
+}
+
+// NewFSDefinitionSourceWithParsers creates a new FSDefinitionSource using the given parsers keyed by file extension
+func NewFSDefinitionSourceWithParsers(fsys fs.FS, parsers map[string]DocumentParser) *FSDefinitionSource {
+	s := &FSDefinitionSource{
+		fsys:    fsys,
+		parsers: make(map[string]DocumentParser),
+	}
+	for ext, parser := range parsers {
+		s.RegisterParser(ext, parser)
+	}
+	return s
+}
+
+// RegisterParser registers a parser for files with the given extension
+func (s *FSDefinitionSource) RegisterParser(ext string, parser DocumentParser) {
+	s.parsers[strings.ToLower(ext)] = parser
+}
+
+// LoadDocument implements DefinitionSource, resolving the document's imports
+func (s *FSDefinitionSource) LoadDocument(name string) (*DocumentDefinition, error) {
+	return s.newSession().load(name)
+}
+
+// LoadAll implements DefinitionSource, loading every file with a registered extension in lexical order
+func (s *FSDefinitionSource) LoadAll() ([]*DocumentDefinition, error) {
+	return s.LoadDir(".")
+}
+
+// LoadDir loads every file with a registered extension beneath dir, in lexical order
+func (s *FSDefinitionSource) LoadDir(dir string) ([]*DocumentDefinition, error) {
+	var names []string
+
+	err := fs.WalkDir(s.fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && s.parserFor(name) != nil {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loadAll(names)
+}
+
+// LoadGlob loads every file matching pattern, using the syntax of path.Match
+func (s *FSDefinitionSource) LoadGlob(pattern string) ([]*DocumentDefinition, error) {
+	names, err := fs.Glob(s.fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loadAll(names)
+}
+
+// loadAll loads the named documents in one session so shared imports are parsed once
+func (s *FSDefinitionSource) loadAll(names []string) ([]*DocumentDefinition, error) {
+	session := s.newSession()
+	docs := make([]*DocumentDefinition, 0, len(names))
+
+	for _, name := range names {
+		doc, err := session.load(name)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// parseFile parses a single file without resolving its imports
+func (s *FSDefinitionSource) parseFile(name string) (*DocumentDefinition, error) {
+	parser := s.parserFor(name)
+	if parser == nil {
+		return nil, fmt.Errorf("no definition parser registered for %s", name)
+	}
+
+	data, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parser.ParseDocument(name, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	doc.Source = name
+	doc.Raw = data
+	doc.Checksum = Checksum(data)
+
+	if doc.Signature, err = s.readSignature(name); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// readSignature reads the detached signature stored beside a document as name.sig, either raw
+// or base64 encoded. A document without a signature file has a nil signature.
+func (s *FSDefinitionSource) readSignature(name string) ([]byte, error) {
+	data, err := fs.ReadFile(s.fsys, name+signatureExt)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err == nil {
+		return decoded, nil
+	}
+	return data, nil
+}
+
+// Checksum returns the hex encoded SHA-256 of data
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newSession starts a load session
+func (s *FSDefinitionSource) newSession() *loadSession {
+	return &loadSession{
+		source: s,
+		loaded: make(map[string]*DocumentDefinition),
+	}
+}
+
+// loadSession caches documents loaded together and tracks the import chain to detect cycles
+type loadSession struct {
+	source *FSDefinitionSource
+	loaded map[string]*DocumentDefinition
+	chain  []string
+}
+
+// load loads a document and, recursively, the documents it imports
+func (l *loadSession) load(name string) (*DocumentDefinition, error) {
+	name = path.Clean(name)
+
+	for i, entry := range l.chain {
+		if entry == name {
+			cycle := append(append([]string{}, l.chain[i:]...), name)
+			return nil, fmt.Errorf("import cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if doc, exists := l.loaded[name]; exists {
+		return doc, nil
+	}
+
+	doc, err := l.source.parseFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	l.chain = append(l.chain, name)
+	defer func() { l.chain = l.chain[:len(l.chain)-1] }()
+
+	for _, href := range doc.Imports {
+		target := path.Join(path.Dir(name), href)
+		if !fs.ValidPath(target) {
+			return nil, fmt.Errorf("import %s in %s resolves outside the definition source", href, name)
+		}
+
+		imported, err := l.load(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import %s from %s: %w", href, name, err)
+		}
+		doc.Imported = append(doc.Imported, imported)
+	}
+
+	l.loaded[name] = doc
+	return doc, nil
+}
+
+// parserFor returns the parser registered for a file's extension
+func (s *FSDefinitionSource) parserFor(name string) DocumentParser {
+	return s.parsers[strings.ToLower(path.Ext(name))]
+}
@@ -0,0 +1,69 @@
+package definitionsource
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fsTestXml = `<cdsl><flow id="fromXml" defaultStep="init"><step id="init"><endRoute/></step></flow></cdsl>`
+
+const fsTestJson = `{"flows": {"fromJson": {"defaultStep": "init", "steps": {"init": {"elements": [{"name": "endRoute"}]}}}}}`
+
+const fsTestYaml = `
+flows:
+  fromYaml:
+    defaultStep: init
+    steps:
+      init:
+        elements:
+          - {name: endRoute}
+`
+
+func TestFSDefinitionSource_DetectsFormatByExtension(t *testing.T) {
+	fsys := fstest.MapFS{
+		"flows/a.xml":      {Data: []byte(fsTestXml)},
+		"flows/b.json":     {Data: []byte(fsTestJson)},
+		"flows/c.yml":      {Data: []byte(fsTestYaml)},
+		"flows/README.txt": {Data: []byte("not a flow")},
+	}
+
+	source := NewFSDefinitionSource(fsys)
+
+	doc, err := source.LoadDocument("flows/b.json")
+	require.NoError(t, err)
+	assert.Equal(t, "flows/b.json", doc.Source)
+	assert.NotNil(t, doc.Flows["fromJson"])
+
+	docs, err := source.LoadAll()
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	assert.NotNil(t, docs[0].Flows["fromXml"])
+	assert.NotNil(t, docs[1].Flows["fromJson"])
+	assert.NotNil(t, docs[2].Flows["fromYaml"])
+
+	_, err = source.LoadDocument("flows/README.txt")
+	assert.Error(t, err)
+}
+
+func TestFSDefinitionSource_ReadsZipArchives(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	entry, err := archive.Create("flows/a.xml")
+	require.NoError(t, err)
+	_, err = entry.Write([]byte(fsTestXml))
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	docs, err := NewXmlDomDefinitionSourceFS(reader).LoadAll()
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.NotNil(t, docs[0].Flows["fromXml"])
+}
@@ -0,0 +1,476 @@
+package definitionsource
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DocumentWriter writes a DocumentDefinition in a particular format
+type DocumentWriter interface {
+	WriteDocument(writer io.Writer, doc *DocumentDefinition) error
+}
+
+// writerIndent is the indentation used for each level of nesting
+const writerIndent = "    "
+
+// writers maps file extensions to the writer for their format
+var writers = map[string]DocumentWriter{
+	".xml":  XmlDocumentWriter{},
+	".json": JsonDocumentWriter{},
+}
+
+// Format parses a definition document and writes it back out in the canonical form for its format,
+// chosen by the extension of name. It fails rather than return an XML document that has lost comments or
+// markup the definitions do not hold, listed by xmlFormattingLosses.
+func Format(name string, data []byte) ([]byte, error) {
+	ext := strings.ToLower(path.Ext(name))
+	writer, exists := writers[ext]
+	if !exists {
+		return nil, fmt.Errorf("no writer for %s files", ext)
+	}
+	parser, exists := defaultParsers()[ext]
+	if !exists {
+		return nil, fmt.Errorf("no parser for %s files", ext)
+	}
+
+	doc, err := parser.ParseDocument(name, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if ext == ".xml" {
+		if losses := xmlFormattingLosses(data); len(losses) > 0 {
+			return nil, fmt.Errorf("formatting %s would lose its %s", name, strings.Join(losses, ", "))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writer.WriteDocument(&buf, doc); err != nil {
+		return nil, err
+	}
+
+	if ext == ".xml" {
+		before, after := countXmlComments(data), countXmlComments(buf.Bytes())
+		if after < before {
+			return nil, fmt.Errorf("formatting %s would drop %d of its %d comments", name, before-after, before)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// countXmlComments counts the comments of an XML document, up to the first error
+func countXmlComments(data []byte) int {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	count := 0
+	for {
+		tok, err := decoder.RawToken()
+		if err != nil {
+			return count
+		}
+		if _, ok := tok.(xml.Comment); ok {
+			count++
+		}
+	}
+}
+
+// xmlFormattingLosses lists the markup of an XML document that the canonical form cannot reproduce: processing
+// instructions other than the XML declaration, DOCTYPE and other directives, namespaces declared below the root
+// element, prefixed attributes, which are read by their local name, and text after a child element, which
+// is written before the children
+func xmlFormattingLosses(data []byte) []string {
+	var losses []string
+	lose := func(loss string) {
+		if !slices.Contains(losses, loss) {
+			losses = append(losses, loss)
+		}
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// hasChildren holds whether each open element has had a child element
+	var hasChildren []bool
+	for {
+		tok, err := decoder.RawToken()
+		if err != nil {
+			return losses
+		}
+
+		switch t := tok.(type) {
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				lose("processing instructions")
+			}
+		case xml.Directive:
+			lose("DOCTYPE and other directives")
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				switch {
+				case isNamespaceDeclaration(attr) && len(hasChildren) > 0:
+					lose("namespace declarations below <cdsl>")
+				case !isNamespaceDeclaration(attr) && attr.Name.Space != "":
+					lose("attribute prefixes")
+				}
+			}
+			if len(hasChildren) > 0 {
+				hasChildren[len(hasChildren)-1] = true
+			}
+			hasChildren = append(hasChildren, false)
+		case xml.EndElement:
+			hasChildren = hasChildren[:len(hasChildren)-1]
+		case xml.CharData:
+			if len(hasChildren) > 0 && hasChildren[len(hasChildren)-1] && len(bytes.TrimSpace(t)) > 0 {
+				lose("text after child elements")
+			}
+		}
+	}
+}
+
+// orderedSteps returns the steps of a flow in document order, or ordered by ID for
+// flows built without a StepsList
+func orderedSteps(flow *FlowDefinition) []*StepDefinition {
+	steps := make([]*StepDefinition, 0, len(flow.Steps))
+	if len(flow.StepsList) > 0 {
+		for i := range flow.StepsList {
+			steps = append(steps, &flow.StepsList[i])
+		}
+		return steps
+	}
+
+	for _, step := range flow.Steps {
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].ID < steps[j].ID })
+	return steps
+}
+
+// sortedNames returns the keys of a map in order
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// XmlDocumentWriter writes canonical XML: four space indentation, fragments and flows ordered by ID,
+// steps in document order, attributes ordered by name and comments preserved. It fails on a comment that
+// XML cannot hold.
+type XmlDocumentWriter struct{}
+
+// WriteDocument implements DocumentWriter
+func (XmlDocumentWriter) WriteDocument(writer io.Writer, doc *DocumentDefinition) error {
+	w := &xmlDocumentWriter{}
+
+	w.line(0, `<?xml version="1.0" encoding="utf-8" ?>`)
+	w.comments(0, doc.Comments)
+	root := "<cdsl"
+	for _, prefix := range sortedNames(doc.Namespaces) {
+		if prefix == "" {
+			root += xmlAttr("xmlns", doc.Namespaces[prefix])
+		} else {
+			root += xmlAttr("xmlns:"+prefix, doc.Namespaces[prefix])
+		}
+	}
+	w.line(0, root+">")
+
+	separate := false
+	for _, href := range doc.Imports {
+		w.comments(1, doc.ImportComments[href])
+		w.line(1, "<import"+xmlAttr("href", href)+"/>")
+		separate = true
+	}
+	for _, id := range sortedNames(doc.Fragments) {
+		if separate {
+			w.blank()
+		}
+		w.fragment(doc.Fragments[id])
+		separate = true
+	}
+	for _, id := range sortedNames(doc.Flows) {
+		if separate {
+			w.blank()
+		}
+		w.flow(doc.Flows[id])
+		separate = true
+	}
+	if len(doc.TrailingComments) > 0 && separate {
+		w.blank()
+	}
+	w.comments(1, doc.TrailingComments)
+
+	w.line(0, "</cdsl>")
+
+	if w.err != nil {
+		return w.err
+	}
+	_, err := writer.Write(w.buf.Bytes())
+	return err
+}
+
+// xmlDocumentWriter accumulates the lines of an XML document, and the first content it could not write
+type xmlDocumentWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+// line writes a line at the given depth
+func (w *xmlDocumentWriter) line(depth int, text string) {
+	w.buf.WriteString(strings.Repeat(writerIndent, depth))
+	w.buf.WriteString(text)
+	w.buf.WriteByte('\n')
+}
+
+// blank writes an empty line
+func (w *xmlDocumentWriter) blank() {
+	w.buf.WriteByte('\n')
+}
+
+// comments writes each comment on its own line. The spaces around a comment keep one that starts or ends
+// with - valid, but no comment may contain --.
+func (w *xmlDocumentWriter) comments(depth int, comments []string) {
+	for _, comment := range comments {
+		if strings.Contains(comment, "--") {
+			if w.err == nil {
+				w.err = fmt.Errorf("comment %q cannot be written as XML, which does not allow -- in comments", comment)
+			}
+			continue
+		}
+		w.line(depth, "<!-- "+comment+" -->")
+	}
+}
+
+// fragment writes a <fragment> element
+func (w *xmlDocumentWriter) fragment(fragment *FragmentDefinition) {
+	w.comments(1, fragment.Comments)
+	w.line(1, "<fragment"+xmlAttr("id", fragment.ID)+">")
+	w.elements(2, fragment.Elements)
+	w.comments(2, fragment.TrailingComments)
+	w.line(1, "</fragment>")
+}
+
+// flow writes a <flow> element and its steps separated by blank lines
+func (w *xmlDocumentWriter) flow(flow *FlowDefinition) {
+	attrs := xmlAttr("id", flow.ID)
+	if flow.Extends != "" {
+		attrs += xmlAttr("extends", flow.Extends)
+	}
+	if flow.DefaultStep != "" {
+		attrs += xmlAttr("defaultStep", flow.DefaultStep)
+	}
+	if flow.ErrorStep != "" {
+		attrs += xmlAttr("errorStep", flow.ErrorStep)
+	}
+	if flow.MaxTransitions != 0 {
+		attrs += xmlAttr("maxTransitions", strconv.Itoa(flow.MaxTransitions))
+	}
+	if flow.MaxElements != 0 {
+		attrs += xmlAttr("maxElements", strconv.Itoa(flow.MaxElements))
+	}
+	if flow.MaxDuration != "" {
+		attrs += xmlAttr("maxDuration", flow.MaxDuration)
+	}
+
+	w.comments(1, flow.Comments)
+	w.line(1, "<flow"+attrs+">")
+	for i, step := range orderedSteps(flow) {
+		if i > 0 {
+			w.blank()
+		}
+		w.step(step)
+	}
+	w.comments(2, flow.TrailingComments)
+	w.line(1, "</flow>")
+}
+
+// step writes a <step> element including its <finally> block
+func (w *xmlDocumentWriter) step(step *StepDefinition) {
+	attrs := xmlAttr("id", step.ID)
+	if step.Override {
+		attrs += xmlAttr("override", "true")
+	}
+
+	w.comments(2, step.Comments)
+	if len(step.Elements) == 0 && len(step.Finally) == 0 && len(step.FinallyComments) == 0 && len(step.TrailingComments) == 0 {
+		w.line(2, "<step"+attrs+"/>")
+		return
+	}
+
+	w.line(2, "<step"+attrs+">")
+	w.elements(3, step.Elements)
+	if len(step.Finally) > 0 || len(step.FinallyComments) > 0 {
+		w.line(3, "<finally>")
+		w.elements(4, step.Finally)
+		w.comments(4, step.FinallyComments)
+		w.line(3, "</finally>")
+	}
+	w.comments(3, step.TrailingComments)
+	w.line(2, "</step>")
+}
+
+// elements writes DSL elements, nesting their children
+func (w *xmlDocumentWriter) elements(depth int, elems []ElementDefinition) {
+	for _, elem := range elems {
+		w.comments(depth, elem.Comments)
+
+		open := "<" + elem.Name
+		for _, name := range sortedNames(elem.Attributes) {
+			open += xmlAttr(name, elem.Attributes[name])
+		}
+
+		switch {
+		case len(elem.Elements) > 0 || len(elem.TrailingComments) > 0:
+			w.line(depth, open+">")
+			if elem.Content != "" {
+				w.line(depth+1, xmlText(elem.Content))
+			}
+			w.elements(depth+1, elem.Elements)
+			w.comments(depth+1, elem.TrailingComments)
+			w.line(depth, "</"+elem.Name+">")
+		case elem.Content != "":
+			w.line(depth, open+">"+xmlText(elem.Content)+"</"+elem.Name+">")
+		default:
+			w.line(depth, open+"/>")
+		}
+	}
+}
+
+// xmlAttrEscaper escapes attribute values, including whitespace that parsing would otherwise normalise
+var xmlAttrEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"\n", "&#xA;",
+	"\r", "&#xD;",
+	"\t", "&#x9;",
+)
+
+// xmlTextEscaper escapes text content
+var xmlTextEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+// xmlAttr formats an attribute with a leading space
+func xmlAttr(name string, value string) string {
+	return " " + name + `="` + xmlAttrEscaper.Replace(value) + `"`
+}
+
+// xmlText escapes text content
+func xmlText(text string) string {
+	return xmlTextEscaper.Replace(text)
+}
+
+// JsonDocumentWriter writes canonical JSON: four space indentation, keys ordered by name, IDs held
+// only by the map keys and empty values omitted
+type JsonDocumentWriter struct{}
+
+// jsonElement is the canonical JSON form of an ElementDefinition
+type jsonElement struct {
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Elements   []jsonElement     `json:"elements,omitempty"`
+	Content    string            `json:"content,omitempty"`
+}
+
+// jsonStep is the canonical JSON form of a StepDefinition
+type jsonStep struct {
+	Override bool          `json:"override,omitempty"`
+	Elements []jsonElement `json:"elements"`
+	Finally  []jsonElement `json:"finally,omitempty"`
+}
+
+// jsonFlow is the canonical JSON form of a FlowDefinition
+type jsonFlow struct {
+	Extends        string              `json:"extends,omitempty"`
+	DefaultStep    string              `json:"defaultStep,omitempty"`
+	ErrorStep      string              `json:"errorStep,omitempty"`
+	MaxTransitions int                 `json:"maxTransitions,omitempty"`
+	MaxElements    int                 `json:"maxElements,omitempty"`
+	MaxDuration    string              `json:"maxDuration,omitempty"`
+	Steps          map[string]jsonStep `json:"steps"`
+}
+
+// jsonFragment is the canonical JSON form of a FragmentDefinition
+type jsonFragment struct {
+	Elements []jsonElement `json:"elements"`
+}
+
+// jsonDocument is the canonical JSON form of a DocumentDefinition
+type jsonDocument struct {
+	Imports   []string                `json:"imports,omitempty"`
+	Fragments map[string]jsonFragment `json:"fragments,omitempty"`
+	Flows     map[string]jsonFlow     `json:"flows"`
+}
+
+// WriteDocument implements DocumentWriter
+func (JsonDocumentWriter) WriteDocument(writer io.Writer, doc *DocumentDefinition) error {
+	out := jsonDocument{
+		Imports: doc.Imports,
+		Flows:   make(map[string]jsonFlow, len(doc.Flows)),
+	}
+
+	if len(doc.Fragments) > 0 {
+		out.Fragments = make(map[string]jsonFragment, len(doc.Fragments))
+		for id, fragment := range doc.Fragments {
+			out.Fragments[id] = jsonFragment{Elements: toJsonElements(fragment.Elements)}
+		}
+	}
+
+	for id, flow := range doc.Flows {
+		f := jsonFlow{
+			Extends:        flow.Extends,
+			DefaultStep:    flow.DefaultStep,
+			ErrorStep:      flow.ErrorStep,
+			MaxTransitions: flow.MaxTransitions,
+			MaxElements:    flow.MaxElements,
+			MaxDuration:    flow.MaxDuration,
+			Steps:          make(map[string]jsonStep, len(flow.Steps)),
+		}
+		for _, step := range orderedSteps(flow) {
+			elements := toJsonElements(step.Elements)
+			if elements == nil {
+				elements = []jsonElement{}
+			}
+			f.Steps[step.ID] = jsonStep{
+				Override: step.Override,
+				Elements: elements,
+				Finally:  toJsonElements(step.Finally),
+			}
+		}
+		out.Flows[id] = f
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", writerIndent)
+	return encoder.Encode(out)
+}
+
+// toJsonElements converts element definitions to their canonical JSON form
+func toJsonElements(elems []ElementDefinition) []jsonElement {
+	if len(elems) == 0 {
+		return nil
+	}
+
+	result := make([]jsonElement, len(elems))
+	for i, elem := range elems {
+		result[i] = jsonElement{
+			Name:     elem.Name,
+			Elements: toJsonElements(elem.Elements),
+			Content:  elem.Content,
+		}
+		if len(elem.Attributes) > 0 {
+			result[i].Attributes = elem.Attributes
+		}
+	}
+	return result
+}
@@ -0,0 +1,297 @@
+package definitionsource
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withoutPositions clears every source position in a document so parsed documents compare equal
+func withoutPositions(doc *DocumentDefinition) *DocumentDefinition {
+	var clear func(elems []ElementDefinition)
+	clear = func(elems []ElementDefinition) {
+		for i := range elems {
+			elems[i].Position = types.SourcePosition{}
+			clear(elems[i].Elements)
+		}
+	}
+
+	doc.Source = ""
+	for _, fragment := range doc.Fragments {
+		fragment.Position = types.SourcePosition{}
+		clear(fragment.Elements)
+	}
+	for _, flow := range doc.Flows {
+		flow.Position = types.SourcePosition{}
+		for i := range flow.StepsList {
+			flow.StepsList[i].Position = types.SourcePosition{}
+			clear(flow.StepsList[i].Elements)
+			clear(flow.StepsList[i].Finally)
+		}
+	}
+	return doc
+}
+
+// TestResourcesAreCanonical tests that the definitions in the resources directory are already formatted
+func TestResourcesAreCanonical(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("..", "..", "resources", "*"))
+	require.NoError(t, err)
+
+	for _, name := range names {
+		if _, exists := writers[filepath.Ext(name)]; !exists {
+			continue
+		}
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			data, err := os.ReadFile(name)
+			require.NoError(t, err)
+
+			formatted, err := Format(name, data)
+			require.NoError(t, err)
+			assert.Equal(t, string(data), string(formatted))
+		})
+	}
+}
+
+// TestWritersRoundTrip tests that writing a document and parsing it back gives the same definitions
+func TestWritersRoundTrip(t *testing.T) {
+	xmlDoc := `<cdsl>
+    <import href="shared/common.xml"/>
+    <fragment id="enter"><setVar name="status" val="${status}"/></fragment>
+    <!-- the parent -->
+    <flow id="base" defaultStep="init" errorStep="error">
+        <step id="init">
+            <!-- say hello -->
+            <setVar name="greeting" val="a &amp; &quot;b&quot;&#xA;c"/>
+            <note><![CDATA[<not a tag>]]></note>
+            <sanctionsCheck checkType="enhanced">
+                lists
+                <list name="OFAC"/>
+                <list name="EU">consolidated</list>
+            </sanctionsCheck>
+            <useFragment ref="enter" status="init"/>
+            <finally>
+                <setState val="End"/>
+            </finally>
+        </step>
+        <step id="error"/>
+    </flow>
+    <flow id="child" extends="base">
+        <step id="init" override="true"><endRoute/></step>
+    </flow>
+</cdsl>`
+
+	doc, err := XmlDocumentParser{}.ParseDocument("round.xml", strings.NewReader(xmlDoc))
+	require.NoError(t, err)
+	doc = withoutPositions(doc)
+
+	for ext, writer := range writers {
+		t.Run(ext, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writer.WriteDocument(&buf, doc))
+
+			parsed, err := defaultParsers()[ext].ParseDocument("round"+ext, bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			parsed = withoutPositions(parsed)
+
+			if ext == ".xml" {
+				assert.Equal(t, doc, parsed)
+			} else {
+				// JSON keeps no comments and orders steps by ID
+				assert.Equal(t, doc.Imports, parsed.Imports)
+				assert.Equal(t, doc.Fragments["enter"].Elements, parsed.Fragments["enter"].Elements)
+				for id, flow := range doc.Flows {
+					assert.Equal(t, flow.Extends, parsed.Flows[id].Extends)
+					for stepID, step := range flow.Steps {
+						assert.Equal(t, step.Override, parsed.Flows[id].Steps[stepID].Override)
+						assert.Equal(t, stripComments(step.Elements), parsed.Flows[id].Steps[stepID].Elements)
+						assert.Equal(t, step.Finally, parsed.Flows[id].Steps[stepID].Finally)
+					}
+				}
+			}
+
+			// Writing is idempotent
+			var again bytes.Buffer
+			require.NoError(t, writer.WriteDocument(&again, parsed))
+			assert.Equal(t, buf.String(), again.String())
+		})
+	}
+}
+
+// stripComments returns a copy of elems without comments
+func stripComments(elems []ElementDefinition) []ElementDefinition {
+	result := append([]ElementDefinition{}, elems...)
+	for i := range result {
+		result[i].Comments = nil
+		result[i].TrailingComments = nil
+	}
+	return result
+}
+
+// trailingCommentsXml has comments before every kind of closing tag
+const trailingCommentsXml = `<?xml version="1.0" encoding="utf-8" ?>
+<cdsl>
+    <!-- shared checks -->
+    <import href="common.xml"/>
+
+    <fragment id="audit">
+        <setVar name="audited" val="true"/>
+        <!-- TODO audit the reviewer -->
+    </fragment>
+
+    <flow id="review" defaultStep="init">
+        <step id="empty">
+            <!-- nothing yet -->
+        </step>
+
+        <step id="init">
+            <sanctionsCheck checkType="enhanced">
+                <list name="EU"/>
+                <!-- add UN once licensed -->
+            </sanctionsCheck>
+            <routeTo target="empty"/>
+            <finally>
+                <setState val="Alive"/>
+                <!-- before finally ends -->
+            </finally>
+            <!-- TODO route on the result -->
+        </step>
+        <!-- more steps to come -->
+    </flow>
+
+    <!-- end of flows -->
+</cdsl>
+`
+
+// TestFormatKeepsCommentsBeforeClosingTags tests that comments before an end tag survive formatting
+func TestFormatKeepsCommentsBeforeClosingTags(t *testing.T) {
+	formatted, err := Format("review.xml", []byte(trailingCommentsXml))
+	require.NoError(t, err)
+	assert.Equal(t, trailingCommentsXml, string(formatted))
+
+	doc, err := XmlDocumentParser{}.ParseDocument("review.xml", strings.NewReader(trailingCommentsXml))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shared checks"}, doc.ImportComments["common.xml"])
+	assert.Equal(t, []string{"end of flows"}, doc.TrailingComments)
+	assert.Equal(t, []string{"TODO audit the reviewer"}, doc.Fragments["audit"].TrailingComments)
+	flow := doc.Flows["review"]
+	assert.Equal(t, []string{"more steps to come"}, flow.TrailingComments)
+	assert.Equal(t, []string{"nothing yet"}, flow.Steps["empty"].TrailingComments)
+	assert.Equal(t, []string{"before finally ends"}, flow.Steps["init"].FinallyComments)
+	assert.Equal(t, []string{"TODO route on the result"}, flow.Steps["init"].TrailingComments)
+	assert.Equal(t, []string{"add UN once licensed"}, flow.Steps["init"].Elements[0].TrailingComments)
+}
+
+// commentDroppingWriter writes XML without the comments of its flows
+type commentDroppingWriter struct{}
+
+// WriteDocument implements DocumentWriter
+func (commentDroppingWriter) WriteDocument(writer io.Writer, doc *DocumentDefinition) error {
+	for _, flow := range doc.Flows {
+		flow.TrailingComments = nil
+	}
+	return XmlDocumentWriter{}.WriteDocument(writer, doc)
+}
+
+// TestFormatRefusesToDropComments tests that Format fails rather than lose a comment
+func TestFormatRefusesToDropComments(t *testing.T) {
+	writers[".xml"] = commentDroppingWriter{}
+	defer func() { writers[".xml"] = XmlDocumentWriter{} }()
+
+	_, err := Format("review.xml", []byte(trailingCommentsXml))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "formatting review.xml would drop 1 of its 8 comments")
+}
+
+// TestXmlCommentsMustBeWritable tests that a comment ending in - round trips and one containing -- is refused
+func TestXmlCommentsMustBeWritable(t *testing.T) {
+	doc := &DocumentDefinition{
+		Comments: []string{"-leading and trailing-"},
+		Flows:    map[string]*FlowDefinition{"empty": {ID: "empty", Steps: map[string]*StepDefinition{}}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, XmlDocumentWriter{}.WriteDocument(&buf, doc))
+	reread, err := XmlDocumentParser{}.ParseDocument("comments.xml", &buf)
+	require.NoError(t, err)
+	assert.Equal(t, doc.Comments, reread.Comments)
+
+	doc.Flows["empty"].Comments = []string{"was --verbose"}
+	buf.Reset()
+	err = XmlDocumentWriter{}.WriteDocument(&buf, doc)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `comment "was --verbose" cannot be written as XML`)
+	assert.Zero(t, buf.Len())
+}
+
+// defaultNamespaceXml is canonical XML that declares a default namespace beside a prefixed one
+const defaultNamespaceXml = `<?xml version="1.0" encoding="utf-8" ?>
+<cdsl xmlns="urn:cdsl" xmlns:kyc="urn:cdsl:kyc">
+    <flow id="namespaced" defaultStep="init">
+        <step id="init">
+            <kyc:amlCheck checkLevel="standard"/>
+            <finally>
+                <setState val="End"/>
+            </finally>
+        </step>
+    </flow>
+</cdsl>
+`
+
+// TestFormatKeepsTheDefaultNamespace tests that a default namespace survives formatting
+func TestFormatKeepsTheDefaultNamespace(t *testing.T) {
+	formatted, err := Format("namespaced.xml", []byte(defaultNamespaceXml))
+	require.NoError(t, err)
+	assert.Equal(t, defaultNamespaceXml, string(formatted))
+}
+
+// TestFormatRefusesLossyMarkup tests that Format fails rather than drop markup the definitions do not hold
+func TestFormatRefusesLossyMarkup(t *testing.T) {
+	tests := []struct {
+		name     string
+		xml      string
+		expected string
+	}{
+		{
+			name:     "processing instruction",
+			xml:      `<?xml version="1.0"?><?xml-stylesheet href="flows.xsl"?><cdsl><flow id="a"/></cdsl>`,
+			expected: "would lose its processing instructions",
+		},
+		{
+			name:     "doctype",
+			xml:      `<!DOCTYPE cdsl><cdsl><flow id="a"/></cdsl>`,
+			expected: "would lose its DOCTYPE and other directives",
+		},
+		{
+			name:     "nested namespace",
+			xml:      `<cdsl><flow id="a"><step id="s"><kyc:amlCheck xmlns:kyc="urn:cdsl:kyc"/></step></flow></cdsl>`,
+			expected: "would lose its namespace declarations below <cdsl>",
+		},
+		{
+			name:     "prefixed attribute",
+			xml:      `<cdsl xmlns:kyc="urn:cdsl:kyc"><flow id="a"><step id="s"><setVar kyc:name="x" val="y"/></step></flow></cdsl>`,
+			expected: "would lose its attribute prefixes",
+		},
+		{
+			name:     "mixed content",
+			xml:      `<cdsl><flow id="a"><step id="s"><note>before<b/>after</note></step></flow></cdsl>`,
+			expected: "would lose its text after child elements",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Format("lossy.xml", []byte(tt.xml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+
+	_, err := Format("text.xml", []byte(`<cdsl><flow id="a"><step id="s"><note>before<b/></note></step></flow></cdsl>`))
+	assert.NoError(t, err)
+}
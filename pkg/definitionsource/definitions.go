@@ -1,30 +1,238 @@
 package definitionsource
 
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/types"
+)
+
 // ElementDefinition represents a DSL element definition
 type ElementDefinition struct {
-	Name       string                 `xml:",name" json:"name" yaml:"name"`
-	Attributes map[string]string      `xml:",attr" json:"attributes" yaml:"attributes"`
-	Elements   map[string]interface{} `xml:",any" json:"elements" yaml:"elements"`
-	Content    string                 `xml:",chardata" json:"content" yaml:"content"`
+	Name       string              `xml:",name" json:"name" yaml:"name"`
+	Attributes map[string]string   `xml:",attr" json:"attributes" yaml:"attributes"`
+	Elements   []ElementDefinition `xml:",any" json:"elements" yaml:"elements"`
+	Content    string              `xml:",chardata" json:"content" yaml:"content"`
+	Comments   []string            `xml:"-" json:"-" yaml:"-"`
+	// TrailingComments are the comments after the last child, before the closing tag
+	TrailingComments []string             `xml:"-" json:"-" yaml:"-"`
+	Position         types.SourcePosition `xml:"-" json:"-" yaml:"-"`
 }
 
 // StepDefinition represents a step definition
 type StepDefinition struct {
-	ID       string             `xml:"id,attr" json:"id" yaml:"id"`
+	ID       string              `xml:"id,attr" json:"id" yaml:"id"`
+	Override bool                `xml:"override,attr" json:"override,omitempty" yaml:"override,omitempty"`
 	Elements []ElementDefinition `xml:",any" json:"elements" yaml:"elements"`
 	Finally  []ElementDefinition `xml:"finally>*" json:"finally" yaml:"finally"`
+	Comments []string            `xml:"-" json:"-" yaml:"-"`
+	// FinallyComments are the comments before </finally> and TrailingComments those before </step>
+	FinallyComments  []string             `xml:"-" json:"-" yaml:"-"`
+	TrailingComments []string             `xml:"-" json:"-" yaml:"-"`
+	Position         types.SourcePosition `xml:"-" json:"-" yaml:"-"`
 }
 
 // FlowDefinition represents a flow definition
 type FlowDefinition struct {
-	ID          string                     `xml:"id,attr" json:"id" yaml:"id"`
-	DefaultStep string                     `xml:"defaultStep,attr" json:"defaultStep" yaml:"defaultStep"`
-	ErrorStep   string                     `xml:"errorStep,attr" json:"errorStep" yaml:"errorStep"`
-	Steps       map[string]*StepDefinition `xml:"-" json:"steps" yaml:"steps"`
-	StepsList   []StepDefinition          `xml:"step" json:"-" yaml:"-"`
+	ID          string `xml:"id,attr" json:"id" yaml:"id"`
+	Extends     string `xml:"extends,attr" json:"extends,omitempty" yaml:"extends,omitempty"`
+	DefaultStep string `xml:"defaultStep,attr" json:"defaultStep" yaml:"defaultStep"`
+	ErrorStep   string `xml:"errorStep,attr" json:"errorStep" yaml:"errorStep"`
+	// MaxTransitions, MaxElements and MaxDuration limit one execution of the flow. MaxDuration is a Go
+	// duration such as 30s, and zero or empty values fall back to the executor's limits.
+	MaxTransitions int                        `xml:"maxTransitions,attr" json:"maxTransitions,omitempty" yaml:"maxTransitions,omitempty"`
+	MaxElements    int                        `xml:"maxElements,attr" json:"maxElements,omitempty" yaml:"maxElements,omitempty"`
+	MaxDuration    string                     `xml:"maxDuration,attr" json:"maxDuration,omitempty" yaml:"maxDuration,omitempty"`
+	Steps          map[string]*StepDefinition `xml:"-" json:"steps" yaml:"steps"`
+	StepsList      []StepDefinition           `xml:"step" json:"-" yaml:"-"`
+	Comments       []string                   `xml:"-" json:"-" yaml:"-"`
+	// TrailingComments are the comments after the last step, before </flow>
+	TrailingComments []string             `xml:"-" json:"-" yaml:"-"`
+	Position         types.SourcePosition `xml:"-" json:"-" yaml:"-"`
+}
+
+// FragmentDefinition represents a named sequence of elements that steps expand with useFragment
+type FragmentDefinition struct {
+	ID       string              `xml:"id,attr" json:"id" yaml:"id"`
+	Elements []ElementDefinition `xml:",any" json:"elements" yaml:"elements"`
+	Comments []string            `xml:"-" json:"-" yaml:"-"`
+	// TrailingComments are the comments after the last element, before </fragment>
+	TrailingComments []string             `xml:"-" json:"-" yaml:"-"`
+	Position         types.SourcePosition `xml:"-" json:"-" yaml:"-"`
 }
 
 // DocumentDefinition represents a document containing flow definitions
 type DocumentDefinition struct {
-	Flows map[string]*FlowDefinition `xml:"flow" json:"flows" yaml:"flows"`
+	Source string `xml:"-" json:"-" yaml:"-"`
+	// Raw holds the bytes the document was parsed from and Checksum their hex encoded SHA-256
+	Raw      []byte `xml:"-" json:"-" yaml:"-"`
+	Checksum string `xml:"-" json:"-" yaml:"-"`
+	// Signature is the detached signature read from the .sig file beside the document, if any
+	Signature []byte                         `xml:"-" json:"-" yaml:"-"`
+	Imports   []string                       `xml:"-" json:"imports,omitempty" yaml:"imports,omitempty"`
+	Fragments map[string]*FragmentDefinition `xml:"-" json:"fragments,omitempty" yaml:"fragments,omitempty"`
+	Flows     map[string]*FlowDefinition     `xml:"flow" json:"flows" yaml:"flows"`
+	Comments  []string                       `xml:"-" json:"-" yaml:"-"`
+	// ImportComments holds the comments before each <import>, by href, and TrailingComments the comments
+	// before </cdsl> or after it
+	ImportComments   map[string][]string   `xml:"-" json:"-" yaml:"-"`
+	TrailingComments []string              `xml:"-" json:"-" yaml:"-"`
+	Imported         []*DocumentDefinition `xml:"-" json:"-" yaml:"-"`
+	// Namespaces maps the namespace prefixes declared on the root element to their URIs, the default namespace
+	// under the empty prefix
+	Namespaces map[string]string `xml:"-" json:"-" yaml:"-"`
+}
+
+// WithImported returns the document followed by every document it imports, directly or
+// transitively, with each document appearing once
+func (d *DocumentDefinition) WithImported() []*DocumentDefinition {
+	var result []*DocumentDefinition
+	seen := make(map[*DocumentDefinition]bool)
+
+	var visit func(doc *DocumentDefinition)
+	visit = func(doc *DocumentDefinition) {
+		if doc == nil || seen[doc] {
+			return
+		}
+		seen[doc] = true
+		result = append(result, doc)
+		for _, imported := range doc.Imported {
+			visit(imported)
+		}
+	}
+	visit(d)
+
+	return result
+}
+
+// MaxDurationValue parses MaxDuration, returning zero when it is not set
+func (f *FlowDefinition) MaxDurationValue() (time.Duration, error) {
+	if f.MaxDuration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(f.MaxDuration)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("maxDuration %q of flow %s is not a positive duration", f.MaxDuration, f.ID)
+	}
+	return d, nil
+}
+
+// validateLimits checks the execution limits of a flow
+func validateLimits(flow *FlowDefinition) error {
+	if flow.MaxTransitions < 0 {
+		return fmt.Errorf("maxTransitions of flow %s must not be negative", flow.ID)
+	}
+	if flow.MaxElements < 0 {
+		return fmt.Errorf("maxElements of flow %s must not be negative", flow.ID)
+	}
+	_, err := flow.MaxDurationValue()
+	return err
+}
+
+// normaliseDocument fills in fragment, flow and step IDs from their map keys and rebuilds
+// each flow's StepsList, for sources whose format stores steps as a map
+func normaliseDocument(doc *DocumentDefinition) error {
+	if doc.Flows == nil {
+		doc.Flows = make(map[string]*FlowDefinition)
+	}
+
+	for fragmentID, fragment := range doc.Fragments {
+		if fragment == nil {
+			return fmt.Errorf("fragment %s has no definition", fragmentID)
+		}
+		if fragment.ID == "" {
+			fragment.ID = fragmentID
+		} else if fragment.ID != fragmentID {
+			return fmt.Errorf("fragment id %s does not match key %s", fragment.ID, fragmentID)
+		}
+		normaliseElements(fragment.Elements)
+	}
+
+	for flowID, flow := range doc.Flows {
+		if flow == nil {
+			return fmt.Errorf("flow %s has no definition", flowID)
+		}
+		if flow.ID == "" {
+			flow.ID = flowID
+		} else if flow.ID != flowID {
+			return fmt.Errorf("flow id %s does not match key %s", flow.ID, flowID)
+		}
+		if err := validateLimits(flow); err != nil {
+			return err
+		}
+
+		stepIDs := make([]string, 0, len(flow.Steps))
+		for stepID, step := range flow.Steps {
+			if step == nil {
+				return fmt.Errorf("step %s in flow %s has no definition", stepID, flowID)
+			}
+			if step.ID == "" {
+				step.ID = stepID
+			} else if step.ID != stepID {
+				return fmt.Errorf("step id %s does not match key %s in flow %s", step.ID, stepID, flowID)
+			}
+			stepIDs = append(stepIDs, stepID)
+		}
+		sort.Strings(stepIDs)
+
+		flow.StepsList = make([]StepDefinition, 0, len(stepIDs))
+		for _, stepID := range stepIDs {
+			flow.StepsList = append(flow.StepsList, *flow.Steps[stepID])
+		}
+		flow.Steps = make(map[string]*StepDefinition, len(flow.StepsList))
+		for i := range flow.StepsList {
+			step := &flow.StepsList[i]
+			flow.Steps[step.ID] = step
+			normaliseElements(step.Elements)
+			normaliseElements(step.Finally)
+		}
+	}
+
+	return nil
+}
+
+// normaliseElements gives every element an attribute map, as the XML parser does
+func normaliseElements(elems []ElementDefinition) {
+	for i := range elems {
+		if elems[i].Attributes == nil {
+			elems[i].Attributes = make(map[string]string)
+		}
+		normaliseElements(elems[i].Elements)
+	}
+}
+
+// setPositionFile records file as the source file of every position in the document
+// that does not already name one
+func setPositionFile(doc *DocumentDefinition, file string) {
+	var visit func(elems []ElementDefinition)
+	visit = func(elems []ElementDefinition) {
+		for i := range elems {
+			if elems[i].Position.File == "" {
+				elems[i].Position.File = file
+			}
+			visit(elems[i].Elements)
+		}
+	}
+
+	for _, fragment := range doc.Fragments {
+		if fragment.Position.File == "" {
+			fragment.Position.File = file
+		}
+		visit(fragment.Elements)
+	}
+
+	for _, flow := range doc.Flows {
+		if flow.Position.File == "" {
+			flow.Position.File = file
+		}
+		for i := range flow.StepsList {
+			step := &flow.StepsList[i]
+			if step.Position.File == "" {
+				step.Position.File = file
+			}
+			visit(step.Elements)
+			visit(step.Finally)
+		}
+	}
 }
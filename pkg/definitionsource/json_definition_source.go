@@ -0,0 +1,93 @@
+package definitionsource
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+)
+
+// JsonDefinitionSource loads flow definitions from JSON files
+type JsonDefinitionSource struct {
+	*FSDefinitionSource
+}
+
+// NewJsonDefinitionSource creates a new JsonDefinitionSource rooted at basePath on disk
+func NewJsonDefinitionSource(basePath string) *JsonDefinitionSource {
+	return NewJsonDefinitionSourceFS(os.DirFS(basePath))
+}
+
+// NewJsonDefinitionSourceFS creates a new JsonDefinitionSource reading from fsys
+func NewJsonDefinitionSourceFS(fsys fs.FS) *JsonDefinitionSource {
+	return &JsonDefinitionSource{
+		FSDefinitionSource: NewFSDefinitionSourceWithParsers(fsys, map[string]DocumentParser{
+			".json": JsonDocumentParser{},
+		}),
+	}
+}
+
+// JsonDocumentParser parses JSON definition documents
+type JsonDocumentParser struct{}
+
+// ParseDocument implements DocumentParser, parsing a JSON document into a DocumentDefinition
+func (p JsonDocumentParser) ParseDocument(name string, reader io.Reader) (*DocumentDefinition, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	result := &DocumentDefinition{}
+	if err := decoder.Decode(result); err != nil {
+		return nil, p.parseError(name, data, decoder.InputOffset(), err)
+	}
+
+	if err := normaliseDocument(result); err != nil {
+		return nil, exceptions.NewCdslParseError(name, 1, 1, "Invalid document", err)
+	}
+
+	// JSON offers no element positions, so definitions are attributed to the file alone
+	setPositionFile(result, name)
+
+	return result, nil
+}
+
+// parseError converts a decoding error into a CdslParseError positioned within data
+func (p JsonDocumentParser) parseError(name string, data []byte, offset int64, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+
+	line, column := lineAndColumn(data, offset)
+	return exceptions.NewCdslParseError(name, line, column, "Invalid JSON document", err)
+}
+
+// lineAndColumn converts a byte offset into a 1-based line and column
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	line, column := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
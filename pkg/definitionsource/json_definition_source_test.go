@@ -0,0 +1,57 @@
+package definitionsource
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJsonDefinitionSource_ParsesNestedElements(t *testing.T) {
+	jsonDoc := `{
+  "flows": {
+    "screening": {
+      "defaultStep": "check",
+      "steps": {
+        "check": {
+          "elements": [
+            {"name": "sanctionsCheck", "attributes": {"checkType": "enhanced"},
+             "elements": [{"name": "list", "attributes": {"name": "OFAC"}}, {"name": "list", "content": "EU"}]}
+          ],
+          "finally": [{"name": "setState", "attributes": {"val": "End"}}]
+        }
+      }
+    }
+  }
+}`
+
+	doc, err := JsonDocumentParser{}.ParseDocument("inline.json", strings.NewReader(jsonDoc))
+	require.NoError(t, err)
+
+	flow := doc.Flows["screening"]
+	require.NotNil(t, flow)
+	assert.Equal(t, "screening", flow.ID)
+	require.Len(t, flow.StepsList, 1)
+
+	step := flow.Steps["check"]
+	assert.Equal(t, "check", step.ID)
+	require.Len(t, step.Elements[0].Elements, 2)
+	assert.Equal(t, "OFAC", step.Elements[0].Elements[0].Attributes["name"])
+	assert.Equal(t, "EU", step.Elements[0].Elements[1].Content)
+	assert.Equal(t, "End", step.Finally[0].Attributes["val"])
+}
+
+func TestJsonDefinitionSource_ReportsPosition(t *testing.T) {
+	jsonDoc := "{\n  \"flows\": {\n    \"broken\": {\"defaultStep\": 3}\n  }\n}"
+
+	_, err := JsonDocumentParser{}.ParseDocument("broken.json", strings.NewReader(jsonDoc))
+	require.Error(t, err)
+
+	var parseErr *exceptions.CdslParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, "broken.json", parseErr.File)
+	assert.Equal(t, 3, parseErr.Line)
+}
@@ -2,239 +2,546 @@ package definitionsource
 
 import (
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/types"
 )
 
 // XmlDomDefinitionSource loads flow definitions from XML files
 type XmlDomDefinitionSource struct {
-	basePath string
+	*FSDefinitionSource
 }
 
-// NewXmlDomDefinitionSource creates a new XmlDomDefinitionSource
+// NewXmlDomDefinitionSource creates a new XmlDomDefinitionSource rooted at basePath on disk
 func NewXmlDomDefinitionSource(basePath string) *XmlDomDefinitionSource {
+	return NewXmlDomDefinitionSourceFS(os.DirFS(basePath))
+}
+
+// NewXmlDomDefinitionSourceFS creates a new XmlDomDefinitionSource reading from fsys
+func NewXmlDomDefinitionSourceFS(fsys fs.FS) *XmlDomDefinitionSource {
 	return &XmlDomDefinitionSource{
-		basePath: basePath,
+		FSDefinitionSource: NewFSDefinitionSourceWithParsers(fsys, map[string]DocumentParser{
+			".xml": XmlDocumentParser{},
+		}),
 	}
 }
 
-// LoadDocument loads a document from a file
-func (s *XmlDomDefinitionSource) LoadDocument(path string) (*DocumentDefinition, error) {
-	fullPath := filepath.Join(s.basePath, path)
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return nil, err
+// XmlDocumentParser parses XML definition documents
+type XmlDocumentParser struct{}
+
+// ParseDocument implements DocumentParser, parsing an XML document into a DocumentDefinition
+func (XmlDocumentParser) ParseDocument(name string, reader io.Reader) (*DocumentDefinition, error) {
+	p := &xmlDocumentParser{
+		name:    name,
+		decoder: xml.NewDecoder(reader),
 	}
-	defer file.Close()
-	
-	return s.parseDocument(file)
+	return p.parse()
 }
 
-// parseDocument parses an XML document into a DocumentDefinition
-func (s *XmlDomDefinitionSource) parseDocument(reader io.Reader) (*DocumentDefinition, error) {
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, err
+// xmlDocumentParser walks the token stream of a single XML document
+type xmlDocumentParser struct {
+	name    string
+	decoder *xml.Decoder
+	// position of the start of the most recently read token
+	line   int
+	column int
+	// comments read since the last element, attached to the next definition
+	comments []string
+	// prefixes maps namespace URIs back to the prefix declared for them, one map per open element
+	prefixes []map[string]string
+}
+
+// errorf creates a parse error at the current decoder position
+func (p *xmlDocumentParser) errorf(cause error, format string, args ...interface{}) error {
+	line, column := p.decoder.InputPos()
+	return exceptions.NewCdslParseError(p.name, line, column, fmt.Sprintf(format, args...), cause)
+}
+
+// tokenPosition returns the source position of the most recently read token
+func (p *xmlDocumentParser) tokenPosition() types.SourcePosition {
+	return types.SourcePosition{
+		File:   p.name,
+		Line:   p.line,
+		Column: p.column,
 	}
-	
-	// First, parse the document to get the flow definitions
-	var doc struct {
-		XMLName xml.Name         `xml:"cdsl"`
-		Flows   []FlowDefinition `xml:"flow"`
+}
+
+// takeComments returns the comments preceding the current element and clears them
+func (p *xmlDocumentParser) takeComments() []string {
+	comments := p.comments
+	p.comments = nil
+	return comments
+}
+
+// next returns the next token of interest, skipping processing instructions and directives.
+// Comments are collected for the next definition, or for the enclosing one when an end tag follows them.
+func (p *xmlDocumentParser) next() (xml.Token, error) {
+	for {
+		p.line, p.column = p.decoder.InputPos()
+		tok, err := p.decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return nil, p.errorf(nil, "%s", syntaxErr.Msg)
+			}
+			return nil, p.errorf(err, "Failed to read XML")
+		}
+
+		switch t := tok.(type) {
+		case xml.Comment:
+			p.comments = append(p.comments, strings.TrimSpace(string(t)))
+			continue
+		case xml.ProcInst, xml.Directive:
+			continue
+		case xml.StartElement:
+			p.prefixes = append(p.prefixes, declaredPrefixes(t))
+		case xml.EndElement:
+			p.prefixes = p.prefixes[:len(p.prefixes)-1]
+		}
+		return tok, nil
 	}
-	
-	if err := xml.Unmarshal(data, &doc); err != nil {
-		return nil, err
+}
+
+// parse parses the whole document
+func (p *xmlDocumentParser) parse() (*DocumentDefinition, error) {
+	for {
+		tok, err := p.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, p.errorf(nil, "Document has no <cdsl> root element")
+			}
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "cdsl" {
+				return nil, p.errorf(nil, "Expected <cdsl> root element but found <%s>", t.Name.Local)
+			}
+			comments := p.takeComments()
+			doc, err := p.parseCdsl()
+			if err != nil {
+				return nil, err
+			}
+			doc.Comments = comments
+			if err := p.skipToEnd(); err != nil {
+				return nil, err
+			}
+			doc.TrailingComments = append(doc.TrailingComments, p.takeComments()...)
+			for _, attr := range t.Attr {
+				if !isNamespaceDeclaration(attr) {
+					continue
+				}
+				if doc.Namespaces == nil {
+					doc.Namespaces = make(map[string]string)
+				}
+				if attr.Name.Space == "xmlns" {
+					doc.Namespaces[attr.Name.Local] = attr.Value
+				} else {
+					doc.Namespaces[""] = attr.Value
+				}
+			}
+			return doc, nil
+		case xml.CharData:
+			if len(strings.TrimSpace(string(t))) > 0 {
+				return nil, p.errorf(nil, "Unexpected text before <cdsl> root element")
+			}
+		}
 	}
-	
+}
+
+// parseCdsl parses the <cdsl> root element
+func (p *xmlDocumentParser) parseCdsl() (*DocumentDefinition, error) {
 	result := &DocumentDefinition{
-		Flows: make(map[string]*FlowDefinition),
+		Fragments: make(map[string]*FragmentDefinition),
+		Flows:     make(map[string]*FlowDefinition),
 	}
-	
-	for i := range doc.Flows {
-		flow := &doc.Flows[i]
+
+	err := p.parseChildren("cdsl", func(child xml.StartElement) error {
+		if child.Name.Local == "import" {
+			comments := p.takeComments()
+			href, err := p.parseImport(child)
+			if err != nil {
+				return err
+			}
+			result.Imports = append(result.Imports, href)
+			if comments = append(comments, p.takeComments()...); len(comments) > 0 {
+				if result.ImportComments == nil {
+					result.ImportComments = make(map[string][]string)
+				}
+				result.ImportComments[href] = append(result.ImportComments[href], comments...)
+			}
+			return nil
+		}
+		if child.Name.Local == "fragment" {
+			fragment, err := p.parseFragment(child)
+			if err != nil {
+				return err
+			}
+			if _, exists := result.Fragments[fragment.ID]; exists {
+				return p.errorf(nil, "Duplicate fragment id %s", fragment.ID)
+			}
+			result.Fragments[fragment.ID] = fragment
+			return nil
+		}
+		if child.Name.Local != "flow" {
+			return p.errorf(nil, "Unexpected element <%s> in <cdsl>", child.Name.Local)
+		}
+
+		flow, err := p.parseFlow(child)
+		if err != nil {
+			return err
+		}
+		if _, exists := result.Flows[flow.ID]; exists {
+			return p.errorf(nil, "Duplicate flow id %s", flow.ID)
+		}
 		result.Flows[flow.ID] = flow
-		
-		// Initialize steps map
-		flow.Steps = make(map[string]*StepDefinition)
-		
-		// Convert StepsList to Steps map
-		for j := range flow.StepsList {
-			step := &flow.StepsList[j]
-			flow.Steps[step.ID] = step
-			
-			// We need to manually parse the step elements to get their names
-			// This is a workaround for the XML parsing limitation
-			stepXml := s.extractStepXml(data, step.ID)
-			if stepXml != "" {
-				s.parseStepElements(stepXml, step)
-			}
-		}
-	}
-	
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.TrailingComments = p.takeComments()
+
 	return result, nil
 }
 
-// extractStepXml extracts the XML for a specific step
-func (s *XmlDomDefinitionSource) extractStepXml(data []byte, stepID string) string {
-	// This is a simple string-based approach, not robust for all XML cases
-	stepStart := "<step id=\"" + stepID + "\">"
-	stepEnd := "</step>"
-	
-	startIdx := strings.Index(string(data), stepStart)
-	if startIdx == -1 {
-		return ""
-	}
-	
-	endIdx := strings.Index(string(data)[startIdx:], stepEnd)
-	if endIdx == -1 {
-		return ""
-	}
-	
-	return string(data)[startIdx : startIdx+endIdx+len(stepEnd)]
-}
-
-// parseStepElements parses the elements in a step
-func (s *XmlDomDefinitionSource) parseStepElements(stepXml string, step *StepDefinition) {
-	// Extract regular elements (not in finally)
-	finallyStart := "<finally>"
-	finallyIdx := strings.Index(stepXml, finallyStart)
-	
-	regularXml := stepXml
-	finallyXml := ""
-	
-	if finallyIdx != -1 {
-		regularXml = stepXml[:finallyIdx]
-		finallyXml = stepXml[finallyIdx:]
-	}
-	
-	// Parse regular elements
-	step.Elements = s.extractElements(regularXml)
-	
-	// Parse finally elements
-	if finallyXml != "" {
-		step.Finally = s.extractElements(finallyXml)
-	}
-}
-
-// extractElements extracts element definitions from XML
-func (s *XmlDomDefinitionSource) extractElements(xml string) []ElementDefinition {
-	var elements []ElementDefinition
-	
-	// Simple regex-like approach to find XML tags
-	// This is not a robust XML parser, just a demonstration
-	startIdx := 0
+// skipToEnd reads the rest of the document after the root element, collecting its comments
+func (p *xmlDocumentParser) skipToEnd() error {
 	for {
-		// Find opening tag
-		tagStart := strings.Index(xml[startIdx:], "<")
-		if tagStart == -1 || startIdx+tagStart >= len(xml) {
-			break
-		}
-		tagStart += startIdx
-		
-		// Skip if it's a closing tag or step/finally tag
-		if xml[tagStart+1] == '/' || 
-		   strings.HasPrefix(xml[tagStart:], "<step") ||
-		   strings.HasPrefix(xml[tagStart:], "<finally") ||
-		   strings.HasPrefix(xml[tagStart:], "</finally") {
-			startIdx = tagStart + 1
+		if _, err := p.next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// parseImport parses an <import href="..."/> element
+func (p *xmlDocumentParser) parseImport(start xml.StartElement) (string, error) {
+	href := ""
+	for _, attr := range start.Attr {
+		if isNamespaceDeclaration(attr) {
 			continue
 		}
-		
-		// Find end of tag name
-		nameEnd := strings.IndexAny(xml[tagStart+1:], " />")
-		if nameEnd == -1 {
-			startIdx = tagStart + 1
+		if attr.Name.Local != "href" {
+			return "", p.errorf(nil, "Unexpected attribute %s on <import>", attr.Name.Local)
+		}
+		href = attr.Value
+	}
+	if href == "" {
+		return "", p.errorf(nil, "<import> must have an href attribute")
+	}
+
+	err := p.parseChildren("import", func(child xml.StartElement) error {
+		return p.errorf(nil, "Unexpected element <%s> in <import>", child.Name.Local)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return href, nil
+}
+
+// parseFragment parses a <fragment> element
+func (p *xmlDocumentParser) parseFragment(start xml.StartElement) (*FragmentDefinition, error) {
+	fragment := &FragmentDefinition{
+		Comments: p.takeComments(),
+		Position: p.tokenPosition(),
+	}
+
+	for _, attr := range start.Attr {
+		if isNamespaceDeclaration(attr) {
+			continue
+		}
+		if attr.Name.Local != "id" {
+			return nil, p.errorf(nil, "Unexpected attribute %s on <fragment>", attr.Name.Local)
+		}
+		fragment.ID = attr.Value
+	}
+	if fragment.ID == "" {
+		return nil, p.errorf(nil, "<fragment> must have an id attribute")
+	}
+
+	err := p.parseChildren("fragment", func(child xml.StartElement) error {
+		elem, err := p.parseElement(child)
+		if err != nil {
+			return err
+		}
+		fragment.Elements = append(fragment.Elements, *elem)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fragment.TrailingComments = p.takeComments()
+
+	return fragment, nil
+}
+
+// parseFlow parses a <flow> element
+func (p *xmlDocumentParser) parseFlow(start xml.StartElement) (*FlowDefinition, error) {
+	flow := &FlowDefinition{
+		Steps:    make(map[string]*StepDefinition),
+		Comments: p.takeComments(),
+		Position: p.tokenPosition(),
+	}
+
+	for _, attr := range start.Attr {
+		if isNamespaceDeclaration(attr) {
 			continue
 		}
-		nameEnd += tagStart + 1
-		
-		// Extract tag name
-		tagName := xml[tagStart+1:nameEnd]
-		
-		// Find closing tag or self-closing
-		tagEnd := strings.Index(xml[tagStart:], ">")
-		if tagEnd == -1 {
-			startIdx = tagStart + 1
+		switch attr.Name.Local {
+		case "id":
+			flow.ID = attr.Value
+		case "defaultStep":
+			flow.DefaultStep = attr.Value
+		case "errorStep":
+			flow.ErrorStep = attr.Value
+		case "extends":
+			flow.Extends = attr.Value
+		case "maxTransitions", "maxElements":
+			limit, err := strconv.Atoi(attr.Value)
+			if err != nil {
+				return nil, p.errorf(err, "Invalid %s attribute on <flow>", attr.Name.Local)
+			}
+			if attr.Name.Local == "maxTransitions" {
+				flow.MaxTransitions = limit
+			} else {
+				flow.MaxElements = limit
+			}
+		case "maxDuration":
+			flow.MaxDuration = attr.Value
+		default:
+			return nil, p.errorf(nil, "Unexpected attribute %s on <flow>", attr.Name.Local)
+		}
+	}
+	if flow.ID == "" {
+		return nil, p.errorf(nil, "<flow> must have an id attribute")
+	}
+	if err := validateLimits(flow); err != nil {
+		return nil, p.errorf(err, "Invalid limits on flow %s", flow.ID)
+	}
+
+	err := p.parseChildren("flow", func(child xml.StartElement) error {
+		if child.Name.Local != "step" {
+			return p.errorf(nil, "Unexpected element <%s> in flow %s", child.Name.Local, flow.ID)
+		}
+
+		step, err := p.parseStep(child)
+		if err != nil {
+			return err
+		}
+		for _, existing := range flow.StepsList {
+			if existing.ID == step.ID {
+				return p.errorf(nil, "Duplicate step id %s in flow %s", step.ID, flow.ID)
+			}
+		}
+		flow.StepsList = append(flow.StepsList, *step)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	flow.TrailingComments = p.takeComments()
+
+	// Index the steps once the list has stopped growing
+	for i := range flow.StepsList {
+		step := &flow.StepsList[i]
+		flow.Steps[step.ID] = step
+	}
+
+	return flow, nil
+}
+
+// parseStep parses a <step> element including its <finally> block
+func (p *xmlDocumentParser) parseStep(start xml.StartElement) (*StepDefinition, error) {
+	step := &StepDefinition{
+		Comments: p.takeComments(),
+		Position: p.tokenPosition(),
+	}
+
+	for _, attr := range start.Attr {
+		if isNamespaceDeclaration(attr) {
 			continue
 		}
-		tagEnd += tagStart
-		
-		// Check if self-closing (not used currently but might be useful later)
-		_ = xml[tagEnd-1] == '/'
-		
-		// Create element definition
-		elem := ElementDefinition{
-			Name:       tagName,
-			Attributes: make(map[string]string),
-			Elements:   make(map[string]interface{}),
-		}
-		
-		// Extract attributes
-		attrStr := xml[nameEnd:tagEnd]
-		s.extractAttributes(attrStr, &elem)
-		
-		// Add to elements list
-		elements = append(elements, elem)
-		
-		// Move to next position
-		startIdx = tagEnd + 1
-	}
-	
-	return elements
-}
-
-// extractAttributes extracts attributes from an XML tag
-func (s *XmlDomDefinitionSource) extractAttributes(attrStr string, elem *ElementDefinition) {
-	// Simple attribute extraction
-	parts := strings.Fields(attrStr)
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" || part == "/" {
+		switch attr.Name.Local {
+		case "id":
+			step.ID = attr.Value
+		case "override":
+			override, err := strconv.ParseBool(attr.Value)
+			if err != nil {
+				return nil, p.errorf(err, "Invalid override attribute on <step>")
+			}
+			step.Override = override
+		default:
+			return nil, p.errorf(nil, "Unexpected attribute %s on <step>", attr.Name.Local)
+		}
+	}
+	if step.ID == "" {
+		return nil, p.errorf(nil, "<step> must have an id attribute")
+	}
+
+	seenFinally := false
+	err := p.parseChildren("step", func(child xml.StartElement) error {
+		if p.qualifiedName(child.Name) == "finally" {
+			if seenFinally {
+				return p.errorf(nil, "Step %s has more than one <finally> block", step.ID)
+			}
+			seenFinally = true
+
+			err := p.parseChildren("finally", func(finalChild xml.StartElement) error {
+				elem, err := p.parseElement(finalChild)
+				if err != nil {
+					return err
+				}
+				step.Finally = append(step.Finally, *elem)
+				return nil
+			})
+			step.FinallyComments = p.takeComments()
+			return err
+		}
+
+		if seenFinally {
+			return p.errorf(nil, "Element <%s> in step %s must not follow the <finally> block", child.Name.Local, step.ID)
+		}
+
+		elem, err := p.parseElement(child)
+		if err != nil {
+			return err
+		}
+		step.Elements = append(step.Elements, *elem)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	step.TrailingComments = p.takeComments()
+
+	return step, nil
+}
+
+// parseElement parses a DSL element along with its attributes, nested children and text content
+func (p *xmlDocumentParser) parseElement(start xml.StartElement) (*ElementDefinition, error) {
+	elem := &ElementDefinition{
+		Name:       p.qualifiedName(start.Name),
+		Attributes: make(map[string]string),
+		Comments:   p.takeComments(),
+		Position:   p.tokenPosition(),
+	}
+
+	for _, attr := range start.Attr {
+		if isNamespaceDeclaration(attr) {
 			continue
 		}
-		
-		eqIdx := strings.Index(part, "=")
-		if eqIdx == -1 {
+		elem.Attributes[attr.Name.Local] = attr.Value
+	}
+
+	var content strings.Builder
+	for {
+		tok, err := p.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, p.errorf(nil, "Unexpected end of document in <%s>", elem.Name)
+			}
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := p.parseElement(t)
+			if err != nil {
+				return nil, err
+			}
+			elem.Elements = append(elem.Elements, *child)
+		case xml.CharData:
+			content.Write(t)
+		case xml.EndElement:
+			elem.Content = strings.TrimSpace(content.String())
+			elem.TrailingComments = p.takeComments()
+			return elem, nil
+		}
+	}
+}
+
+// parseChildren invokes handle for each child element until the enclosing element ends.
+// Non-whitespace text is rejected since structural elements carry no content.
+func (p *xmlDocumentParser) parseChildren(parent string, handle func(child xml.StartElement) error) error {
+	for {
+		tok, err := p.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return p.errorf(nil, "Unexpected end of document in <%s>", parent)
+			}
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := handle(t); err != nil {
+				return err
+			}
+		case xml.CharData:
+			if len(strings.TrimSpace(string(t))) > 0 {
+				return p.errorf(nil, "Unexpected text in <%s>", parent)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// qualifiedName returns the name of a DSL element with the prefix it was written with, so <kyc:amlCheck/>
+// is named kyc:amlCheck whatever namespace URI the prefix is declared with. Elements in the default namespace
+// are not prefixed.
+func (p *xmlDocumentParser) qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	for i := len(p.prefixes) - 1; i >= 0; i-- {
+		if prefix, declared := p.prefixes[i][name.Space]; declared {
+			if prefix == "" {
+				return name.Local
+			}
+			return prefix + ":" + name.Local
+		}
+	}
+	// The decoder leaves undeclared prefixes in place of the namespace URI
+	return name.Space + ":" + name.Local
+}
+
+// declaredPrefixes returns the namespace declarations of an element, mapping each URI to its prefix and the
+// default namespace to ""
+func declaredPrefixes(start xml.StartElement) map[string]string {
+	var prefixes map[string]string
+	for _, attr := range start.Attr {
+		if !isNamespaceDeclaration(attr) {
 			continue
 		}
-		
-		name := strings.TrimSpace(part[:eqIdx])
-		value := strings.TrimSpace(part[eqIdx+1:])
-		
-		// Remove quotes
-		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
-			value = value[1 : len(value)-1]
-		}
-		
-		elem.Attributes[name] = value
-		log.Printf("Extracted attribute: %s = %s for element %s", name, value, elem.Name)
-	}
-}
-
-// processElementAttributes processes element attributes
-func (s *XmlDomDefinitionSource) processElementAttributes(elem *ElementDefinition) {
-	// Initialize attributes map if needed
-	if elem.Attributes == nil {
-		elem.Attributes = make(map[string]string)
-	}
-	
-	// Initialize elements map if needed
-	if elem.Elements == nil {
-		elem.Elements = make(map[string]interface{})
-	}
-	
-	// Clean up content
-	elem.Content = strings.TrimSpace(elem.Content)
-	
-	// Set the name from the XML tag if it's not already set
-	if elem.Name == "" {
-		// This is a workaround since we can't directly access the XML tag name
-		// In a real implementation, you would need to modify the XML unmarshaling
-		// to capture the tag name properly
-		log.Printf("Warning: Element name is empty, this may cause issues with DSL resolution")
+		if prefixes == nil {
+			prefixes = make(map[string]string)
+		}
+		if attr.Name.Space == "xmlns" {
+			prefixes[attr.Value] = attr.Name.Local
+		} else {
+			prefixes[attr.Value] = ""
+		}
 	}
+	return prefixes
+}
+
+// isNamespaceDeclaration reports whether an attribute is an xmlns declaration
+func isNamespaceDeclaration(attr xml.Attr) bool {
+	return attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns")
 }
@@ -0,0 +1,98 @@
+package definitionsource
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXmlDomDefinitionSource_ParsesAwkwardMarkup(t *testing.T) {
+	xmlDoc := `<?xml version="1.0" encoding="utf-8" ?>
+<cdsl>
+    <!-- <step id="commented"><setVar name="x" val="y"/></step> -->
+    <flow id='quoted' defaultStep="init" errorStep="error">
+        <step id='init' >
+            <setVar name="greeting" val="hello world"/>
+            <setVar name="escaped" val="a &amp; b &lt;c&gt;"/>
+            <note><![CDATA[<not a tag>]]></note>
+            <sanctionsCheck checkType="enhanced">
+                <list name="OFAC"/>
+                <list name="EU">consolidated</list>
+            </sanctionsCheck>
+            <finally>
+                <setState val="End"/>
+            </finally>
+        </step>
+        <step id="error">
+            <endRoute/>
+        </step>
+    </flow>
+</cdsl>`
+
+	doc, err := XmlDocumentParser{}.ParseDocument("inline.xml", strings.NewReader(xmlDoc))
+	require.NoError(t, err)
+
+	flow := doc.Flows["quoted"]
+	require.NotNil(t, flow)
+	assert.Equal(t, "init", flow.DefaultStep)
+	assert.Equal(t, "error", flow.ErrorStep)
+	assert.Len(t, flow.Steps, 2)
+	assert.Nil(t, flow.Steps["commented"])
+
+	step := flow.Steps["init"]
+	require.Len(t, step.Elements, 4)
+	assert.Equal(t, "hello world", step.Elements[0].Attributes["val"])
+	assert.Equal(t, "a & b <c>", step.Elements[1].Attributes["val"])
+	assert.Equal(t, "<not a tag>", step.Elements[2].Content)
+
+	sanctions := step.Elements[3]
+	assert.Equal(t, "sanctionsCheck", sanctions.Name)
+	require.Len(t, sanctions.Elements, 2)
+	assert.Equal(t, "OFAC", sanctions.Elements[0].Attributes["name"])
+	assert.Equal(t, "consolidated", sanctions.Elements[1].Content)
+
+	require.Len(t, step.Finally, 1)
+	assert.Equal(t, "setState", step.Finally[0].Name)
+	assert.Equal(t, "End", step.Finally[0].Attributes["val"])
+}
+
+func TestXmlDomDefinitionSource_FindsFinallyInDefaultNamespace(t *testing.T) {
+	xmlDoc := `<cdsl xmlns="urn:cdsl" xmlns:kyc="urn:cdsl:kyc">
+    <flow id="namespaced" defaultStep="init">
+        <step id="init">
+            <setVar name="x" val="y"/>
+            <kyc:finally/>
+            <finally>
+                <setState val="End"/>
+            </finally>
+        </step>
+    </flow>
+</cdsl>`
+
+	doc, err := XmlDocumentParser{}.ParseDocument("namespaced.xml", strings.NewReader(xmlDoc))
+	require.NoError(t, err)
+
+	step := doc.Flows["namespaced"].Steps["init"]
+	require.Len(t, step.Elements, 2)
+	assert.Equal(t, "kyc:finally", step.Elements[1].Name)
+	require.Len(t, step.Finally, 1)
+	assert.Equal(t, "setState", step.Finally[0].Name)
+}
+
+func TestXmlDomDefinitionSource_ReportsPosition(t *testing.T) {
+	xmlDoc := "<cdsl>\n  <flow id=\"broken\" defaultStep=\"init\">\n    <step id=\"init\">\n      <setVar name=\"x\">\n    </step>\n  </flow>\n</cdsl>"
+
+	_, err := XmlDocumentParser{}.ParseDocument("broken.xml", strings.NewReader(xmlDoc))
+	require.Error(t, err)
+
+	var parseErr *exceptions.CdslParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, "broken.xml", parseErr.File)
+	assert.Equal(t, 5, parseErr.Line)
+	assert.Greater(t, parseErr.Column, 0)
+	assert.Contains(t, err.Error(), "broken.xml:5:")
+}
@@ -0,0 +1,164 @@
+package definitionsource
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlLinePattern extracts the line number yaml.v3 embeds in its error messages
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// YamlDefinitionSource loads flow definitions from YAML files
+type YamlDefinitionSource struct {
+	*FSDefinitionSource
+}
+
+// NewYamlDefinitionSource creates a new YamlDefinitionSource rooted at basePath on disk
+func NewYamlDefinitionSource(basePath string) *YamlDefinitionSource {
+	return NewYamlDefinitionSourceFS(os.DirFS(basePath))
+}
+
+// NewYamlDefinitionSourceFS creates a new YamlDefinitionSource reading from fsys
+func NewYamlDefinitionSourceFS(fsys fs.FS) *YamlDefinitionSource {
+	return &YamlDefinitionSource{
+		FSDefinitionSource: NewFSDefinitionSourceWithParsers(fsys, map[string]DocumentParser{
+			".yaml": YamlDocumentParser{},
+			".yml":  YamlDocumentParser{},
+		}),
+	}
+}
+
+// YamlDocumentParser parses YAML definition documents
+type YamlDocumentParser struct{}
+
+// ParseDocument implements DocumentParser, parsing a YAML document into a DocumentDefinition
+func (YamlDocumentParser) ParseDocument(name string, reader io.Reader) (*DocumentDefinition, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	result := &DocumentDefinition{}
+	if err := decoder.Decode(result); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, exceptions.NewCdslParseError(name, 1, 0, "Document is empty", nil)
+		}
+		return nil, exceptions.NewCdslParseError(name, yamlErrorLine(err), 0, "Invalid YAML document", err)
+	}
+
+	if err := normaliseDocument(result); err != nil {
+		return nil, exceptions.NewCdslParseError(name, 1, 0, "Invalid document", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err == nil && len(root.Content) > 0 {
+		applyYamlPositions(result, root.Content[0])
+	}
+	setPositionFile(result, name)
+
+	return result, nil
+}
+
+// yamlErrorLine returns the first line number reported by a yaml.v3 error
+func yamlErrorLine(err error) int {
+	match := yamlLinePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 1
+	}
+
+	line, convErr := strconv.Atoi(match[1])
+	if convErr != nil {
+		return 1
+	}
+	return line
+}
+
+// applyYamlPositions copies node positions onto the fragments, flows, steps and elements decoded from them
+func applyYamlPositions(doc *DocumentDefinition, root *yaml.Node) {
+	if fragments := yamlMappingValue(root, "fragments"); fragments != nil && fragments.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(fragments.Content); i += 2 {
+			fragment := doc.Fragments[fragments.Content[i].Value]
+			if fragment == nil {
+				continue
+			}
+			fragment.Position = yamlPosition(fragments.Content[i])
+			applyYamlElementPositions(fragment.Elements, yamlMappingValue(fragments.Content[i+1], "elements"))
+		}
+	}
+
+	flows := yamlMappingValue(root, "flows")
+	if flows == nil || flows.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(flows.Content); i += 2 {
+		flow := doc.Flows[flows.Content[i].Value]
+		if flow == nil {
+			continue
+		}
+		flow.Position = yamlPosition(flows.Content[i])
+
+		steps := yamlMappingValue(flows.Content[i+1], "steps")
+		if steps == nil || steps.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(steps.Content); j += 2 {
+			step := flow.Steps[steps.Content[j].Value]
+			if step == nil {
+				continue
+			}
+			step.Position = yamlPosition(steps.Content[j])
+			applyYamlElementPositions(step.Elements, yamlMappingValue(steps.Content[j+1], "elements"))
+			applyYamlElementPositions(step.Finally, yamlMappingValue(steps.Content[j+1], "finally"))
+		}
+	}
+}
+
+// applyYamlElementPositions copies the positions of a sequence of element nodes onto elems
+func applyYamlElementPositions(elems []ElementDefinition, seq *yaml.Node) {
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return
+	}
+
+	for i, node := range seq.Content {
+		if i >= len(elems) {
+			return
+		}
+		elems[i].Position = yamlPosition(node)
+		applyYamlElementPositions(elems[i].Elements, yamlMappingValue(node, "elements"))
+	}
+}
+
+// yamlMappingValue returns the value node for key in a mapping node
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlPosition returns the position of a node
+func yamlPosition(node *yaml.Node) types.SourcePosition {
+	return types.SourcePosition{
+		Line:   node.Line,
+		Column: node.Column,
+	}
+}
@@ -0,0 +1,56 @@
+package definitionsource
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYamlDefinitionSource_ParsesOrderedElements(t *testing.T) {
+	yamlDoc := `
+flows:
+  screening:
+    defaultStep: check
+    steps:
+      check:
+        elements:
+          - name: setVar
+            attributes: {name: approved, val: true}
+          - name: sanctionsCheck
+            attributes: {checkType: enhanced}
+            elements:
+              - {name: list, attributes: {name: OFAC}}
+              - {name: list, content: EU}
+        finally:
+          - {name: setState, attributes: {val: End}}
+`
+
+	doc, err := YamlDocumentParser{}.ParseDocument("inline.yaml", strings.NewReader(yamlDoc))
+	require.NoError(t, err)
+
+	step := doc.Flows["screening"].Steps["check"]
+	require.NotNil(t, step)
+	require.Len(t, step.Elements, 2)
+	assert.Equal(t, "setVar", step.Elements[0].Name)
+	assert.Equal(t, "true", step.Elements[0].Attributes["val"])
+	assert.Equal(t, "sanctionsCheck", step.Elements[1].Name)
+	assert.Equal(t, "EU", step.Elements[1].Elements[1].Content)
+	assert.Equal(t, "setState", step.Finally[0].Name)
+}
+
+func TestYamlDefinitionSource_ReportsLine(t *testing.T) {
+	yamlDoc := "flows:\n  broken:\n    defaultStep: init\n    stepz: {}\n"
+
+	_, err := YamlDocumentParser{}.ParseDocument("broken.yaml", strings.NewReader(yamlDoc))
+	require.Error(t, err)
+
+	var parseErr *exceptions.CdslParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, "broken.yaml", parseErr.File)
+	assert.Equal(t, 4, parseErr.Line)
+	assert.Contains(t, err.Error(), "broken.yaml:4:")
+}
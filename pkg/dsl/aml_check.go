@@ -10,7 +10,7 @@ import (
 
 // AmlCheckModel represents the model for the AmlCheck DSL
 type AmlCheckModel struct {
-	CheckLevel string `json:"checkLevel"`
+	CheckLevel string `cdsl:"checkLevel,default=standard,enum=standard|enhanced" doc:"Depth of the check"`
 }
 
 // AmlCheck is a DSL that performs Anti-Money Laundering checks
@@ -18,37 +18,21 @@ type AmlCheck struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *AmlCheck) NewModel() interface{} {
+	return &AmlCheckModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *AmlCheck) Describe() DslDescriptor {
+	return describeModelDsl(d, "Performs Anti-Money Laundering checks")
+}
+
 // Execute implements Dsl
 func (d *AmlCheck) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var checkLevel string
-	
-	// Try to extract attributes from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if cl, ok := m.Get("checkLevel").(string); ok {
-			checkLevel = cl
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if cl, ok := props["checkLevel"].(string); ok {
-				checkLevel = cl
-			}
-		} else {
-			if cl, ok := m["checkLevel"].(string); ok {
-				checkLevel = cl
-			}
-		}
-	}
-	
-	// Remove any trailing quotes
-	if len(checkLevel) >= 2 && checkLevel[len(checkLevel)-1] == '"' {
-		checkLevel = checkLevel[:len(checkLevel)-1]
-	}
-	
-	// Default value if not provided
-	if checkLevel == "" {
-		checkLevel = "standard"
+	m, err := boundModel[AmlCheckModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
 	// Get customer information from context
@@ -63,7 +47,7 @@ func (d *AmlCheck) Execute(runtime *context.CdslRuntime, ctx *context.CdslContex
 	passed := true
 	
 	// For high-risk customers or large transactions, we might want to perform additional checks
-	if riskLevel == "high" || checkLevel == "enhanced" {
+	if riskLevel == "high" || m.CheckLevel == "enhanced" {
 		log.Printf("AmlCheck: Performing enhanced AML check for high-risk customer %s", customerName)
 		// In a real implementation, this would perform additional checks
 	}
@@ -74,7 +58,7 @@ func (d *AmlCheck) Execute(runtime *context.CdslRuntime, ctx *context.CdslContex
 	}
 	
 	// Store check level in context
-	if err := ctx.PutVar("amlCheckLevel", checkLevel); err != nil {
+	if err := ctx.PutVar("amlCheckLevel", m.CheckLevel); err != nil {
 		return nil, err
 	}
 	
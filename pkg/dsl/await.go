@@ -7,7 +7,7 @@ import (
 
 // AwaitModel represents the model for the Await DSL
 type AwaitModel struct {
-	At string `json:"at"`
+	At string `cdsl:"at,required" doc:"ID of the step to resume at"`
 }
 
 // Await is a DSL that pauses execution and waits for an event
@@ -15,20 +15,36 @@ type Await struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *Await) NewModel() interface{} {
+	return &AwaitModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *Await) Describe() DslDescriptor {
+	descriptor := describeModelDsl(d, "Pauses the flow until the next event, resuming at a step")
+	descriptor.Awaits = true
+	return descriptor
+}
+
 // Execute implements Dsl
 func (d *Await) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	m, ok := model.(*MapModel)
-	if !ok {
-		return nil, nil
-	}
-	
-	at, ok := m.Get("at").(string)
-	if !ok {
-		return nil, nil
+	m, err := boundModel[AwaitModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
 	output := types.NewCdslOutputEvent()
 	output.Action = types.ActionAwait
-	output.NextRoute = at
+	output.NextRoute = m.At
 	return output, nil
 }
+
+// Routes implements RoutingDsl
+func (d *Await) Routes(model interface{}) []Route {
+	m, err := boundModel[AwaitModel](model)
+	if err != nil {
+		return nil
+	}
+	return []Route{{Action: types.ActionAwait, Target: m.At}}
+}
@@ -2,6 +2,7 @@ package dsl
 
 import (
 	"log"
+	"strconv"
 	
 	"github.com/rsqn/go-cdsl/pkg/context"
 	"github.com/rsqn/go-cdsl/pkg/types"
@@ -9,10 +10,10 @@ import (
 
 // CollectCustomerInfoModel represents the model for the CollectCustomerInfo DSL
 type CollectCustomerInfoModel struct {
-	Name            string `json:"name"`
-	Age             string `json:"age"`
-	TransactionValue string `json:"transactionValue"`
-	CountryCode     string `json:"countryCode"`
+	Name             string `cdsl:"name,default=John Doe" doc:"Full name of the customer"`
+	Age              int    `cdsl:"age,default=30" doc:"Age of the customer in years"`
+	TransactionValue int    `cdsl:"transactionValue,default=1000" doc:"Value of the transaction"`
+	CountryCode      string `cdsl:"countryCode,default=US" doc:"ISO 3166 country code of the customer"`
 }
 
 // CollectCustomerInfo is a DSL that collects customer information
@@ -20,98 +21,37 @@ type CollectCustomerInfo struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *CollectCustomerInfo) NewModel() interface{} {
+	return &CollectCustomerInfoModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *CollectCustomerInfo) Describe() DslDescriptor {
+	return describeModelDsl(d, "Collects customer information into the context")
+}
+
 // Execute implements Dsl
 func (d *CollectCustomerInfo) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var name, age, transactionValue, countryCode string
-	
-	// Try to extract attributes from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if n, ok := m.Get("name").(string); ok {
-			name = n
-		}
-		if a, ok := m.Get("age").(string); ok {
-			age = a
-		}
-		if tv, ok := m.Get("transactionValue").(string); ok {
-			transactionValue = tv
-		}
-		if cc, ok := m.Get("countryCode").(string); ok {
-			countryCode = cc
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if n, ok := props["name"].(string); ok {
-				name = n
-			}
-			if a, ok := props["age"].(string); ok {
-				age = a
-			}
-			if tv, ok := props["transactionValue"].(string); ok {
-				transactionValue = tv
-			}
-			if cc, ok := props["countryCode"].(string); ok {
-				countryCode = cc
-			}
-		} else {
-			if n, ok := m["name"].(string); ok {
-				name = n
-			}
-			if a, ok := m["age"].(string); ok {
-				age = a
-			}
-			if tv, ok := m["transactionValue"].(string); ok {
-				transactionValue = tv
-			}
-			if cc, ok := m["countryCode"].(string); ok {
-				countryCode = cc
-			}
-		}
-	}
-	
-	// Remove any trailing quotes
-	if len(name) >= 2 && name[len(name)-1] == '"' {
-		name = name[:len(name)-1]
-	}
-	if len(age) >= 2 && age[len(age)-1] == '"' {
-		age = age[:len(age)-1]
-	}
-	if len(transactionValue) >= 2 && transactionValue[len(transactionValue)-1] == '"' {
-		transactionValue = transactionValue[:len(transactionValue)-1]
-	}
-	if len(countryCode) >= 2 && countryCode[len(countryCode)-1] == '"' {
-		countryCode = countryCode[:len(countryCode)-1]
-	}
-	
-	// Default values if not provided
-	if name == "" {
-		name = "John Doe"
-	}
-	if age == "" {
-		age = "30"
-	}
-	if transactionValue == "" {
-		transactionValue = "1000"
-	}
-	if countryCode == "" {
-		countryCode = "US"
+	m, err := boundModel[CollectCustomerInfoModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
-	log.Printf("CollectCustomerInfo: Collecting information for customer %s, age %s, transaction value %s, country code %s", 
-		name, age, transactionValue, countryCode)
+	log.Printf("CollectCustomerInfo: Collecting information for customer %s, age %d, transaction value %d, country code %s", 
+		m.Name, m.Age, m.TransactionValue, m.CountryCode)
 	
 	// Store the customer information in the context
-	if err := ctx.PutVar("customerName", name); err != nil {
+	if err := ctx.PutVar("customerName", m.Name); err != nil {
 		return nil, err
 	}
-	if err := ctx.PutVar("customerAge", age); err != nil {
+	if err := ctx.PutVar("customerAge", strconv.Itoa(m.Age)); err != nil {
 		return nil, err
 	}
-	if err := ctx.PutVar("transactionValue", transactionValue); err != nil {
+	if err := ctx.PutVar("transactionValue", strconv.Itoa(m.TransactionValue)); err != nil {
 		return nil, err
 	}
-	if err := ctx.PutVar("countryCode", countryCode); err != nil {
+	if err := ctx.PutVar("countryCode", m.CountryCode); err != nil {
 		return nil, err
 	}
 	
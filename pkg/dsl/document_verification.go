@@ -10,64 +10,34 @@ import (
 
 // DocumentVerificationModel represents the model for the DocumentVerification DSL
 type DocumentVerificationModel struct {
-	DocumentType string `json:"documentType"`
-	DocumentID   string `json:"documentId"`
+	DocumentType string `cdsl:"documentType,default=passport" doc:"Type of document, such as passport"`
+	DocumentID   string `cdsl:"documentId,default=123456789" doc:"Number of the document"`
 }
 
 // DocumentVerification is a DSL that verifies customer documents
 type DocumentVerification struct {
 	DslSupport
+	Verifier DocumentVerifier `inject:",optional"`
+}
+
+// NewModel implements ModelDsl
+func (d *DocumentVerification) NewModel() interface{} {
+	return &DocumentVerificationModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *DocumentVerification) Describe() DslDescriptor {
+	return describeModelDsl(d, "Verifies a customer identity document")
 }
 
 // Execute implements Dsl
 func (d *DocumentVerification) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var documentType, documentID string
-	
-	// Try to extract attributes from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if dt, ok := m.Get("documentType").(string); ok {
-			documentType = dt
-		}
-		if did, ok := m.Get("documentId").(string); ok {
-			documentID = did
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if dt, ok := props["documentType"].(string); ok {
-				documentType = dt
-			}
-			if did, ok := props["documentId"].(string); ok {
-				documentID = did
-			}
-		} else {
-			if dt, ok := m["documentType"].(string); ok {
-				documentType = dt
-			}
-			if did, ok := m["documentId"].(string); ok {
-				documentID = did
-			}
-		}
-	}
-	
-	// Remove any trailing quotes
-	if len(documentType) >= 2 && documentType[len(documentType)-1] == '"' {
-		documentType = documentType[:len(documentType)-1]
-	}
-	if len(documentID) >= 2 && documentID[len(documentID)-1] == '"' {
-		documentID = documentID[:len(documentID)-1]
-	}
-	
-	// Default values if not provided
-	if documentType == "" {
-		documentType = "passport"
-	}
-	if documentID == "" {
-		documentID = "123456789"
+	m, err := boundModel[DocumentVerificationModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
-	log.Printf("DocumentVerification: Verifying document type %s with ID %s", documentType, documentID)
+	log.Printf("DocumentVerification: Verifying document type %s with ID %s", m.DocumentType, m.DocumentID)
 	
 	// Get customer information from context
 	customerName := ctx.GetVar("customerName")
@@ -75,6 +45,12 @@ func (d *DocumentVerification) Execute(runtime *context.CdslRuntime, ctx *contex
 	
 	// Perform document verification
 	verified := true
+	if d.Verifier != nil {
+		verified, err = d.Verifier.Verify(m.DocumentType, m.DocumentID, customerName)
+		if err != nil {
+			return nil, err
+		}
+	}
 	
 	// For high-risk customers, we might want to perform additional verification
 	if riskLevel == "high" {
@@ -88,10 +64,10 @@ func (d *DocumentVerification) Execute(runtime *context.CdslRuntime, ctx *contex
 	}
 	
 	// Store document information in context
-	if err := ctx.PutVar("documentType", documentType); err != nil {
+	if err := ctx.PutVar("documentType", m.DocumentType); err != nil {
 		return nil, err
 	}
-	if err := ctx.PutVar("documentID", documentID); err != nil {
+	if err := ctx.PutVar("documentID", m.DocumentID); err != nil {
 		return nil, err
 	}
 	
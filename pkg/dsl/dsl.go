@@ -1,6 +1,9 @@
 package dsl
 
 import (
+	gocontext "context"
+	"encoding/json"
+
 	"github.com/rsqn/go-cdsl/pkg/context"
 	"github.com/rsqn/go-cdsl/pkg/types"
 )
@@ -8,10 +11,43 @@ import (
 // Dsl is the interface that all DSL implementations must satisfy
 // If a DSL returns an Output, execution will stop at that point and an action will be taken based on the output.
 // If you wish to return a value, put it in the context
+//
+// The executor compiles each flow once and shares the DSL instance of every element, and its typed model, between
+// all executions of the flow, which may run concurrently. A DSL must keep execution state in the context rather
+// than its fields, and must not modify its model.
 type Dsl interface {
 	Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error)
 }
 
+// ContextDsl is a DSL that is given the context.Context of the execution, so work such as calls to external
+// services can be canceled and honour request deadlines. The executor calls ExecuteContext instead of Execute.
+type ContextDsl interface {
+	Dsl
+	ExecuteContext(goCtx gocontext.Context, runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error)
+}
+
+// WithContext returns d as a ContextDsl. A DSL that only implements Dsl is adapted to check the context.Context
+// before it executes, and cannot be interrupted once it has started.
+func WithContext(d Dsl) ContextDsl {
+	if contextDsl, ok := d.(ContextDsl); ok {
+		return contextDsl
+	}
+	return contextAdapter{d}
+}
+
+// contextAdapter adapts a Dsl to ContextDsl
+type contextAdapter struct {
+	Dsl
+}
+
+// ExecuteContext implements ContextDsl
+func (a contextAdapter) ExecuteContext(goCtx gocontext.Context, runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	if err := goCtx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Execute(runtime, ctx, model, input)
+}
+
 // DslSupport provides common functionality for DSL implementations
 type DslSupport struct{}
 
@@ -21,11 +57,34 @@ type ValidatingDsl interface {
 	Validate() error
 }
 
-// MapModel represents a model that can be populated from a map
+// Route is an exit a DSL can take out of its step: routing to, or awaiting at, Target, or ending the flow.
+// Conditional marks an exit the DSL only takes depending on the context or input.
+type Route struct {
+	Action      types.Action
+	Target      string
+	Conditional bool
+}
+
+// RoutingDsl is a DSL whose exits can be read from its model without executing it, which lets the
+// registry validator build the step graph of a flow when it is loaded
+type RoutingDsl interface {
+	Dsl
+	Routes(model interface{}) []Route
+}
+
+// MapModel represents a model that can be populated from a map.
+// Attributes are held in Properties, text content in Content and nested elements in Children in document order.
 type MapModel struct {
+	Name       string
 	Properties map[string]interface{}
+	Content    string
+	Children   []*MapModel
 }
 
+// contentKey is the key of the text content of an element in the tree that Decode reads, which cannot
+// collide with an attribute name
+const contentKey = "#text"
+
 // NewMapModel creates a new MapModel
 func NewMapModel() *MapModel {
 	return &MapModel{
@@ -33,12 +92,92 @@ func NewMapModel() *MapModel {
 	}
 }
 
+// NewNamedMapModel creates a new MapModel for an element with the given name
+func NewNamedMapModel(name string) *MapModel {
+	m := NewMapModel()
+	m.Name = name
+	return m
+}
+
 // Get retrieves a property from the model
 func (m *MapModel) Get(key string) interface{} {
 	return m.Properties[key]
 }
 
+// GetString retrieves a string property from the model, returning "" if it is absent
+func (m *MapModel) GetString(key string) string {
+	s, _ := m.Properties[key].(string)
+	return s
+}
+
 // Set sets a property in the model
 func (m *MapModel) Set(key string, value interface{}) {
 	m.Properties[key] = value
 }
+
+// AddChild appends a nested element model
+func (m *MapModel) AddChild(child *MapModel) {
+	m.Children = append(m.Children, child)
+}
+
+// ChildrenNamed returns the nested element models with the given name, in document order
+func (m *MapModel) ChildrenNamed(name string) []*MapModel {
+	var result []*MapModel
+	for _, child := range m.Children {
+		if child.Name == name {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// Child returns the first nested element model with the given name, or nil
+func (m *MapModel) Child(name string) *MapModel {
+	for _, child := range m.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the model
+func (m *MapModel) Copy() *MapModel {
+	result := NewNamedMapModel(m.Name)
+	result.Content = m.Content
+	for k, v := range m.Properties {
+		result.Properties[k] = v
+	}
+	for _, child := range m.Children {
+		result.AddChild(child.Copy())
+	}
+	return result
+}
+
+// Decode decodes the model into a typed struct using its json tags. Properties map to fields by
+// name and repeated children map to slice fields named after the child element, so
+// <sanctionsCheck><list name="EU"/></sanctionsCheck> decodes into a field tagged `json:"list"`
+// of type []struct{ Name string `json:"name"` }. Text content maps to a field tagged `json:"#text"`.
+func (m *MapModel) Decode(target interface{}) error {
+	data, err := json.Marshal(m.toTree())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// toTree converts the model into plain maps and slices, grouping children by name
+func (m *MapModel) toTree() map[string]interface{} {
+	tree := make(map[string]interface{}, len(m.Properties)+len(m.Children))
+	for k, v := range m.Properties {
+		tree[k] = v
+	}
+	if m.Content != "" {
+		tree[contentKey] = m.Content
+	}
+	for _, child := range m.Children {
+		children, _ := tree[child.Name].([]interface{})
+		tree[child.Name] = append(children, child.toTree())
+	}
+	return tree
+}
@@ -0,0 +1,281 @@
+package dsl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+)
+
+// AttributeType is the type of value an attribute holds
+type AttributeType string
+
+const (
+	// AttributeString is free text
+	AttributeString AttributeType = "string"
+	// AttributeInt is a whole number
+	AttributeInt AttributeType = "int"
+	// AttributeFloat is a decimal number
+	AttributeFloat AttributeType = "float"
+	// AttributeBool is true or false
+	AttributeBool AttributeType = "bool"
+	// AttributeDuration is a Go duration such as 30s or 1h30m
+	AttributeDuration AttributeType = "duration"
+)
+
+// AttributeDescriptor describes an attribute an element accepts
+type AttributeDescriptor struct {
+	Name          string
+	Description   string
+	Type          AttributeType
+	Required      bool
+	Default       string
+	AllowedValues []string
+}
+
+// ElementDescriptor describes an element: its attributes, the child elements it accepts and whether it holds
+// text content. Required and Multiple apply when the element is a child of another.
+type ElementDescriptor struct {
+	Name        string
+	Description string
+	Attributes  []AttributeDescriptor
+	Children    []ElementDescriptor
+	Content     bool
+	Required    bool
+	Multiple    bool
+}
+
+// Attribute returns the descriptor of the named attribute
+func (d ElementDescriptor) Attribute(name string) (AttributeDescriptor, bool) {
+	for _, attr := range d.Attributes {
+		if attr.Name == name {
+			return attr, true
+		}
+	}
+	return AttributeDescriptor{}, false
+}
+
+// Child returns the descriptor of the named child element
+func (d ElementDescriptor) Child(name string) (ElementDescriptor, bool) {
+	for _, child := range d.Children {
+		if child.Name == name {
+			return child, true
+		}
+	}
+	return ElementDescriptor{}, false
+}
+
+// DslDescriptor describes a DSL element for the catalogue: what it accepts and how it can leave its step.
+// Namespace is set for DSLs registered from a library.
+type DslDescriptor struct {
+	ElementDescriptor
+	Namespace string
+	Routes    bool
+	Awaits    bool
+	Ends      bool
+}
+
+// QualifiedName returns the name of the DSL prefixed by its namespace, if it has one
+func (d DslDescriptor) QualifiedName() string {
+	if d.Namespace == "" {
+		return d.Name
+	}
+	return d.Namespace + NamespaceSeparator + d.Name
+}
+
+// DescribingDsl is a DSL that describes itself for the catalogue. The name is filled in from the name
+// the DSL is registered under.
+type DescribingDsl interface {
+	Dsl
+	Describe() DslDescriptor
+}
+
+// DescribeModel derives an element descriptor from the `cdsl` tags of a model struct, as accepted by BindModel.
+// Attribute descriptions are read from `doc` tags.
+func DescribeModel(model interface{}) ElementDescriptor {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return describeStruct(t)
+}
+
+// describeStruct describes the tagged fields of a struct type
+func describeStruct(t reflect.Type) ElementDescriptor {
+	var descriptor ElementDescriptor
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := parseModelTag(field)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case isChildStruct(field.Type):
+			child := describeStruct(indirect(field.Type))
+			child.Name = tag.name
+			child.Description = field.Tag.Get("doc")
+			child.Required = tag.required
+			descriptor.Children = append(descriptor.Children, child)
+		case field.Type.Kind() == reflect.Slice && isChildStruct(field.Type.Elem()):
+			child := describeStruct(indirect(field.Type.Elem()))
+			child.Name = tag.name
+			child.Description = field.Tag.Get("doc")
+			child.Required = tag.required
+			child.Multiple = true
+			descriptor.Children = append(descriptor.Children, child)
+		case tag.content:
+			descriptor.Content = true
+		default:
+			descriptor.Attributes = append(descriptor.Attributes, AttributeDescriptor{
+				Name:          tag.name,
+				Description:   field.Tag.Get("doc"),
+				Type:          attributeType(field.Type),
+				Required:      tag.required,
+				Default:       tag.def,
+				AllowedValues: tag.enum,
+			})
+		}
+	}
+	return descriptor
+}
+
+// indirect returns the type a pointer type points to
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// attributeType returns the attribute type a field binds as
+func attributeType(t reflect.Type) AttributeType {
+	if t == durationType {
+		return AttributeDuration
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return AttributeBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return AttributeInt
+	case reflect.Float32, reflect.Float64:
+		return AttributeFloat
+	}
+	return AttributeString
+}
+
+// describeModelDsl describes a ModelDsl from its model
+func describeModelDsl(d ModelDsl, description string) DslDescriptor {
+	descriptor := DslDescriptor{ElementDescriptor: DescribeModel(d.NewModel())}
+	descriptor.Description = description
+	return descriptor
+}
+
+// Describe returns the catalogue descriptor of a DSL: its own description if it is a DescribingDsl, one derived
+// from its model if it is a ModelDsl, otherwise false
+func Describe(d Dsl) (DslDescriptor, bool) {
+	if describing, ok := d.(DescribingDsl); ok {
+		return describing.Describe(), true
+	}
+	if modelDsl, ok := d.(ModelDsl); ok {
+		return DslDescriptor{ElementDescriptor: DescribeModel(modelDsl.NewModel())}, true
+	}
+	return DslDescriptor{}, false
+}
+
+// ValidateElement checks the model of an element against its descriptor: every attribute and child element must
+// be declared, required ones must be present and values must match their type and allowed values
+func ValidateElement(descriptor ElementDescriptor, m *MapModel) error {
+	names := make([]string, 0, len(m.Properties))
+	for name := range m.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if m.Content != "" && !descriptor.Content {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("Element %s does not take text content", m.Name), nil)
+	}
+	for _, name := range names {
+		attr, declared := descriptor.Attribute(name)
+		if !declared {
+			return exceptions.NewCdslValidationError(
+				fmt.Sprintf("Element %s does not accept attribute %s, expected %s", m.Name, name, attributeNames(descriptor)),
+				nil,
+			)
+		}
+		if err := checkAttributeValue(m.Name, attr, fmt.Sprint(m.Properties[name])); err != nil {
+			return err
+		}
+	}
+
+	for _, attr := range descriptor.Attributes {
+		if value, present := m.Properties[attr.Name]; attr.Required && (!present || fmt.Sprint(value) == "") {
+			return missingAttributeError(m.Name, attr.Name)
+		}
+	}
+
+	for _, child := range m.Children {
+		childDescriptor, declared := descriptor.Child(child.Name)
+		if !declared {
+			return exceptions.NewCdslValidationError(fmt.Sprintf("Element %s does not accept a <%s> element", m.Name, child.Name), nil)
+		}
+		if err := ValidateElement(childDescriptor, child); err != nil {
+			return err
+		}
+	}
+	for _, childDescriptor := range descriptor.Children {
+		if childDescriptor.Required && m.Child(childDescriptor.Name) == nil {
+			return exceptions.NewCdslValidationError(fmt.Sprintf("Element %s must have a <%s> element", m.Name, childDescriptor.Name), nil)
+		}
+	}
+	return nil
+}
+
+// attributeNames lists the attributes an element accepts, for error messages
+func attributeNames(descriptor ElementDescriptor) string {
+	if len(descriptor.Attributes) == 0 {
+		return "no attributes"
+	}
+	names := make([]string, len(descriptor.Attributes))
+	for i, attr := range descriptor.Attributes {
+		names[i] = attr.Name
+	}
+	sort.Strings(names)
+	return "one of " + strings.Join(names, ", ")
+}
+
+// checkAttributeValue checks a value against the type and allowed values of an attribute
+func checkAttributeValue(element string, attr AttributeDescriptor, value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(attr.AllowedValues) > 0 && !contains(attr.AllowedValues, value) {
+		return valueError(element, attr.Name, value, "one of "+strings.Join(attr.AllowedValues, ", "), nil)
+	}
+
+	var err error
+	var expected string
+	switch attr.Type {
+	case AttributeInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+		expected = "an integer"
+	case AttributeFloat:
+		_, err = strconv.ParseFloat(value, 64)
+		expected = "a number"
+	case AttributeBool:
+		_, err = strconv.ParseBool(value)
+		expected = "true or false"
+	case AttributeDuration:
+		_, err = time.ParseDuration(value)
+		expected = "a duration"
+	}
+	if err != nil {
+		return valueError(element, attr.Name, value, expected, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package dsl
+
+// NamespaceSeparator separates the namespace of a library from the name of its DSL, as in kyc:amlCheck
+const NamespaceSeparator = ":"
+
+// DslLibraryEntry is a DSL in a library
+type DslLibraryEntry struct {
+	Name       string
+	Factory    func() Dsl
+	Descriptor *DslDescriptor
+}
+
+// DslLibrary is a set of DSLs registered together under a namespace. Elements can name a DSL with the namespace
+// as a prefix (kyc:amlCheck) or, when no other library has a DSL of the same name, by its name alone.
+type DslLibrary struct {
+	Namespace string
+	entries   []DslLibraryEntry
+}
+
+// NewDslLibrary creates an empty library for a namespace
+func NewDslLibrary(namespace string) *DslLibrary {
+	return &DslLibrary{Namespace: namespace}
+}
+
+// Add adds a DSL to the library
+func (l *DslLibrary) Add(name string, factory func() Dsl) *DslLibrary {
+	l.entries = append(l.entries, DslLibraryEntry{Name: name, Factory: factory})
+	return l
+}
+
+// AddDescribed adds a DSL to the library with a descriptor for the catalogue
+func (l *DslLibrary) AddDescribed(name string, factory func() Dsl, descriptor DslDescriptor) *DslLibrary {
+	l.entries = append(l.entries, DslLibraryEntry{Name: name, Factory: factory, Descriptor: &descriptor})
+	return l
+}
+
+// Entries returns the DSLs of the library in the order they were added
+func (l *DslLibrary) Entries() []DslLibraryEntry {
+	return append([]DslLibraryEntry(nil), l.entries...)
+}
+
+// CoreLibrary returns the library of DSLs every flow needs: variables, state and routing
+func CoreLibrary() *DslLibrary {
+	return NewDslLibrary("core").
+		Add("setVar", func() Dsl { return &SetVar{} }).
+		Add("setState", func() Dsl { return &SetState{} }).
+		Add("routeTo", func() Dsl { return &RouteTo{} }).
+		Add("await", func() Dsl { return &Await{} }).
+		Add("endRoute", func() Dsl { return &EndRoute{} })
+}
+
+// KycLibrary returns the library of Know Your Customer checks used by the example KYC flow
+func KycLibrary() *DslLibrary {
+	return NewDslLibrary("kyc").
+		Add("collectCustomerInfo", func() Dsl { return &CollectCustomerInfo{} }).
+		Add("validateCustomerInfo", func() Dsl { return &ValidateCustomerInfo{} }).
+		Add("riskAssessment", func() Dsl { return &RiskAssessment{} }).
+		Add("documentVerification", func() Dsl { return &DocumentVerification{} }).
+		Add("sanctionsCheck", func() Dsl { return &SanctionsCheck{} }).
+		Add("amlCheck", func() Dsl { return &AmlCheck{} }).
+		Add("finalDecision", func() Dsl { return &FinalDecision{} })
+}
@@ -5,14 +5,34 @@ import (
 	"github.com/rsqn/go-cdsl/pkg/types"
 )
 
+// EndRouteModel represents the model for the EndRoute DSL, which takes no attributes
+type EndRouteModel struct{}
+
 // EndRoute is a DSL that ends the flow
 type EndRoute struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *EndRoute) NewModel() interface{} {
+	return &EndRouteModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *EndRoute) Describe() DslDescriptor {
+	descriptor := describeModelDsl(d, "Ends the flow")
+	descriptor.Ends = true
+	return descriptor
+}
+
 // Execute implements Dsl
 func (d *EndRoute) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
 	output := types.NewCdslOutputEvent()
 	output.Action = types.ActionEnd
 	return output, nil
 }
+
+// Routes implements RoutingDsl
+func (d *EndRoute) Routes(model interface{}) []Route {
+	return []Route{{Action: types.ActionEnd}}
+}
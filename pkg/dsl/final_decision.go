@@ -10,7 +10,7 @@ import (
 
 // FinalDecisionModel represents the model for the FinalDecision DSL
 type FinalDecisionModel struct {
-	AutoApprove string `json:"autoApprove"`
+	AutoApprove bool `cdsl:"autoApprove" doc:"Approve without review when every check passed"`
 }
 
 // FinalDecision is a DSL that makes the final KYC decision
@@ -18,27 +18,21 @@ type FinalDecision struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *FinalDecision) NewModel() interface{} {
+	return &FinalDecisionModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *FinalDecision) Describe() DslDescriptor {
+	return describeModelDsl(d, "Makes the final KYC decision")
+}
+
 // Execute implements Dsl
 func (d *FinalDecision) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var autoApprove bool
-	
-	// Try to extract attributes from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if aa, ok := m.Get("autoApprove").(string); ok {
-			autoApprove = aa == "true"
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if aa, ok := props["autoApprove"].(string); ok {
-				autoApprove = aa == "true"
-			}
-		} else {
-			if aa, ok := m["autoApprove"].(string); ok {
-				autoApprove = aa == "true"
-			}
-		}
+	m, err := boundModel[FinalDecisionModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
 	// Get customer information and check results from context
@@ -58,7 +52,7 @@ func (d *FinalDecision) Execute(runtime *context.CdslRuntime, ctx *context.CdslC
 	
 	// Auto-approve if all checks passed and either auto-approve is enabled or risk level is low
 	if infoValid && documentsVerified && sanctionsCheckPassed && amlCheckPassed {
-		if autoApprove || riskLevel == "low" {
+		if m.AutoApprove || riskLevel == "low" {
 			approved = true
 		} else if riskLevel == "medium" {
 			// For medium risk, we might want to perform additional checks
@@ -0,0 +1,125 @@
+package dsl
+
+import (
+	gocontext "context"
+	"fmt"
+	"reflect"
+
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/types"
+)
+
+// ExecContext is the part of an execution a function DSL works with: the variables of the context, the
+// payload of the input event, the runtime and the context.Context of the execution
+type ExecContext struct {
+	goCtx   gocontext.Context
+	runtime *context.CdslRuntime
+	ctx     *context.CdslContext
+	input   *types.CdslInputEvent
+}
+
+// NewExecContext creates an ExecContext for one execution of a DSL
+func NewExecContext(runtime *context.CdslRuntime, ctx *context.CdslContext, input *types.CdslInputEvent) *ExecContext {
+	return &ExecContext{goCtx: gocontext.Background(), runtime: runtime, ctx: ctx, input: input}
+}
+
+// Context returns the context.Context of the execution, to pass to calls that should be canceled with it
+func (ec *ExecContext) Context() gocontext.Context {
+	return ec.goCtx
+}
+
+// ContextID returns the ID of the context being executed
+func (ec *ExecContext) ContextID() string {
+	return ec.ctx.ID
+}
+
+// Var returns a variable of the context, or "" if it is not set
+func (ec *ExecContext) Var(name string) string {
+	return ec.ctx.GetVar(name)
+}
+
+// SetVar sets a variable of the context
+func (ec *ExecContext) SetVar(name string, value string) error {
+	return ec.ctx.PutVar(name, value)
+}
+
+// Payload returns a value from the payload of the input event
+func (ec *ExecContext) Payload(key string) (interface{}, bool) {
+	if ec.input == nil {
+		return nil, false
+	}
+	value, ok := ec.input.Payload[key]
+	return value, ok
+}
+
+// Output adds a value to the output of the flow execution
+func (ec *ExecContext) Output(key string, value interface{}) {
+	ec.runtime.AddOutputValue(key, types.NewCdslOutputValue(value))
+}
+
+// Runtime returns the runtime of the execution, for post step and post commit tasks
+func (ec *ExecContext) Runtime() *context.CdslRuntime {
+	return ec.runtime
+}
+
+// FuncDsl adapts a function to a ModelDsl. The element is bound into a new M, which is a struct or pointer to a
+// struct with `cdsl` tags as described on ModelDsl, so function DSLs are validated and catalogued like any other.
+type FuncDsl[M any] struct {
+	DslSupport
+	Description string
+	fn          func(ec *ExecContext, m M) (*types.CdslOutputEvent, error)
+}
+
+// NewFuncDsl creates a DSL that calls fn with each element's bound model
+func NewFuncDsl[M any](fn func(ec *ExecContext, m M) (*types.CdslOutputEvent, error)) *FuncDsl[M] {
+	return &FuncDsl[M]{fn: fn}
+}
+
+// NewModel implements ModelDsl
+func (d *FuncDsl[M]) NewModel() interface{} {
+	t := reflect.TypeOf((*M)(nil)).Elem()
+	if t.Kind() == reflect.Ptr {
+		return reflect.New(t.Elem()).Interface()
+	}
+	return new(M)
+}
+
+// Describe implements DescribingDsl
+func (d *FuncDsl[M]) Describe() DslDescriptor {
+	return describeModelDsl(d, d.Description)
+}
+
+// Execute implements Dsl
+func (d *FuncDsl[M]) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	return d.ExecuteContext(gocontext.Background(), runtime, ctx, model, input)
+}
+
+// ExecuteContext implements ContextDsl
+func (d *FuncDsl[M]) ExecuteContext(goCtx gocontext.Context, runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	m, err := d.model(model)
+	if err != nil {
+		return nil, err
+	}
+	ec := NewExecContext(runtime, ctx, input)
+	ec.goCtx = goCtx
+	return d.fn(ec, m)
+}
+
+// model returns the M a function is called with, binding it first if the DSL was called with a MapModel
+func (d *FuncDsl[M]) model(model interface{}) (M, error) {
+	var zero M
+	switch m := model.(type) {
+	case M:
+		return m, nil
+	case *M:
+		return *m, nil
+	case *MapModel:
+		target := d.NewModel()
+		if err := BindModel(m, target); err != nil {
+			return zero, err
+		}
+		return d.model(target)
+	}
+	return zero, exceptions.NewCdslValidationError(fmt.Sprintf("Expected a model of type %T but got %T", zero, model), nil)
+}
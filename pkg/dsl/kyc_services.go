@@ -0,0 +1,32 @@
+package dsl
+
+import (
+	gocontext "context"
+)
+
+// SanctionsScreeningRequest describes a customer to screen against sanctions lists
+type SanctionsScreeningRequest struct {
+	CustomerName string
+	CountryCode  string
+	CheckType    string
+	Lists        []string
+}
+
+// SanctionsScreener screens customers against sanctions lists. SanctionsCheck uses the screener registered
+// as a service, if there is one.
+type SanctionsScreener interface {
+	Screen(request SanctionsScreeningRequest) (bool, error)
+}
+
+// ContextSanctionsScreener is a SanctionsScreener whose screening can be canceled. SanctionsCheck calls
+// ScreenContext with the context.Context of the execution when the screener implements it.
+type ContextSanctionsScreener interface {
+	SanctionsScreener
+	ScreenContext(goCtx gocontext.Context, request SanctionsScreeningRequest) (bool, error)
+}
+
+// DocumentVerifier verifies customer identity documents. DocumentVerification uses the verifier registered
+// as a service, if there is one.
+type DocumentVerifier interface {
+	Verify(documentType string, documentID string, customerName string) (bool, error)
+}
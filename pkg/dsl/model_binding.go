@@ -0,0 +1,334 @@
+package dsl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+)
+
+// ModelDsl is a DSL that declares a typed model. NewModel returns a pointer to a new, empty model struct whose
+// fields carry `cdsl` tags; the executor binds each element's attributes and children into it before calling
+// Execute, and RegistryValidator binds it when a flow is loaded so binding errors are reported up front.
+//
+// The tag holds the attribute or child element name followed by options:
+//
+//	Level    string        `cdsl:"level,default=standard,enum=standard|enhanced"`
+//	Limit    int           `cdsl:"limit,required"`
+//	Timeout  time.Duration `cdsl:"timeout,default=30s"`
+//	Lists    []ListModel   `cdsl:"list"`
+//	Text     string        `cdsl:",content"`
+//
+// Attributes bind to string, bool, integer, float and time.Duration fields. Struct fields bind to the first child
+// element with the name and slices of structs to every child with the name, in document order. The text content
+// of an element binds to the field with the content option, which takes no name so it never collides with an
+// attribute.
+type ModelDsl interface {
+	Dsl
+	NewModel() interface{}
+}
+
+// modelTag is the parsed form of a `cdsl` struct tag
+type modelTag struct {
+	name       string
+	required   bool
+	def        string
+	hasDefault bool
+	enum       []string
+	content    bool
+}
+
+// parseModelTag parses a `cdsl` struct tag, returning false if the field is not bound
+func parseModelTag(field reflect.StructField) (modelTag, bool) {
+	value, ok := field.Tag.Lookup("cdsl")
+	if !ok || value == "-" || !field.IsExported() {
+		return modelTag{}, false
+	}
+
+	parts := strings.Split(value, ",")
+	tag := modelTag{name: parts[0]}
+	for _, option := range parts[1:] {
+		switch {
+		case option == "required":
+			tag.required = true
+		case option == "content":
+			tag.content = true
+		case strings.HasPrefix(option, "default="):
+			tag.def = strings.TrimPrefix(option, "default=")
+			tag.hasDefault = true
+		case strings.HasPrefix(option, "enum="):
+			tag.enum = strings.Split(strings.TrimPrefix(option, "enum="), "|")
+		}
+	}
+	return tag, true
+}
+
+// durationType is bound from duration strings such as 30s rather than as an integer
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// BindModel binds the properties and children of a MapModel into target, which must be a pointer to a struct
+// with `cdsl` tags as described on ModelDsl
+func BindModel(m *MapModel, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("Cannot bind element %s into %T, it must be a pointer to a struct", m.Name, target), nil)
+	}
+	return bindStruct(m, value.Elem())
+}
+
+// Bind returns the model a DSL should be executed with: a new typed model bound from model for a ModelDsl,
+// or model unchanged for any other DSL
+func Bind(d Dsl, model interface{}) (interface{}, error) {
+	modelDsl, ok := d.(ModelDsl)
+	if !ok {
+		return model, nil
+	}
+	m, ok := model.(*MapModel)
+	if !ok {
+		return model, nil
+	}
+
+	target := modelDsl.NewModel()
+	if err := BindModel(m, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// boundModel returns the typed model a ModelDsl was executed with, binding it first if the DSL was called
+// directly with a MapModel
+func boundModel[T any](model interface{}) (*T, error) {
+	switch m := model.(type) {
+	case *T:
+		return m, nil
+	case *MapModel:
+		target := new(T)
+		if err := BindModel(m, target); err != nil {
+			return nil, err
+		}
+		return target, nil
+	}
+	return nil, exceptions.NewCdslValidationError(fmt.Sprintf("Expected a model of type %T but got %T", new(T), model), nil)
+}
+
+// bindStruct binds each tagged field of a struct value
+func bindStruct(m *MapModel, value reflect.Value) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := parseModelTag(field)
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch {
+		case isChildStruct(field.Type):
+			err = bindChild(m, tag, value.Field(i))
+		case field.Type.Kind() == reflect.Slice && isChildStruct(field.Type.Elem()):
+			err = bindChildren(m, tag, value.Field(i))
+		case tag.content:
+			err = bindContent(m, tag, value.Field(i))
+		default:
+			err = bindAttribute(m, tag, value.Field(i))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isChildStruct returns true for struct and pointer to struct types, which bind from child elements
+func isChildStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// bindChild binds the first child element with the tag name into a struct or pointer to struct field
+func bindChild(m *MapModel, tag modelTag, field reflect.Value) error {
+	child := m.Child(tag.name)
+	if child == nil {
+		if tag.required {
+			return exceptions.NewCdslValidationError(fmt.Sprintf("Element %s must have a <%s> element", m.Name, tag.name), nil)
+		}
+		return nil
+	}
+
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	return bindStruct(child, field)
+}
+
+// bindChildren binds every child element with the tag name into a slice field
+func bindChildren(m *MapModel, tag modelTag, field reflect.Value) error {
+	children := m.ChildrenNamed(tag.name)
+	if len(children) == 0 && tag.required {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("Element %s must have at least one <%s> element", m.Name, tag.name), nil)
+	}
+
+	items := reflect.MakeSlice(field.Type(), len(children), len(children))
+	for i, child := range children {
+		item := items.Index(i)
+		if item.Kind() == reflect.Ptr {
+			item.Set(reflect.New(item.Type().Elem()))
+			item = item.Elem()
+		}
+		if err := bindStruct(child, item); err != nil {
+			return err
+		}
+	}
+	field.Set(items)
+	return nil
+}
+
+// bindAttribute binds a property of the model into a field, applying the default, required and enum options
+func bindAttribute(m *MapModel, tag modelTag, field reflect.Value) error {
+	raw, present := m.Properties[tag.name]
+	text := ""
+	if present {
+		text = fmt.Sprint(raw)
+	}
+
+	if text == "" {
+		switch {
+		case tag.hasDefault:
+			text = tag.def
+		case tag.required:
+			return missingAttributeError(m.Name, tag.name)
+		default:
+			return nil
+		}
+	}
+
+	if len(tag.enum) > 0 && !contains(tag.enum, text) {
+		return valueError(m.Name, tag.name, text, "one of "+strings.Join(tag.enum, ", "), nil)
+	}
+
+	if err := setField(field, text); err != nil {
+		return valueError(m.Name, tag.name, text, describeType(field.Type()), err)
+	}
+	return nil
+}
+
+// bindContent binds the text content of the element into a field, applying the default, required and enum options
+func bindContent(m *MapModel, tag modelTag, field reflect.Value) error {
+	text := m.Content
+	if text == "" {
+		switch {
+		case tag.hasDefault:
+			text = tag.def
+		case tag.required:
+			return exceptions.NewCdslValidationError(fmt.Sprintf("Element %s requires text content", m.Name), nil)
+		default:
+			return nil
+		}
+	}
+
+	if len(tag.enum) > 0 && !contains(tag.enum, text) {
+		return contentError(m.Name, text, "one of "+strings.Join(tag.enum, ", "), nil)
+	}
+
+	if err := setField(field, text); err != nil {
+		return contentError(m.Name, text, describeType(field.Type()), err)
+	}
+	return nil
+}
+
+// missingAttributeError reports a required attribute that is absent or empty
+func missingAttributeError(element string, attribute string) error {
+	return exceptions.NewCdslValidationError(fmt.Sprintf("Element %s requires attribute %s", element, attribute), nil)
+}
+
+// valueError reports an attribute value that is not what the element expects
+func valueError(element string, attribute string, value string, expected string, cause error) error {
+	return exceptions.NewCdslValidationError(
+		fmt.Sprintf("Attribute %s of element %s is %q, expected %s", attribute, element, value, expected),
+		cause,
+	)
+}
+
+// contentError reports text content that is not what the element expects
+func contentError(element string, value string, expected string, cause error) error {
+	return exceptions.NewCdslValidationError(
+		fmt.Sprintf("Text content of element %s is %q, expected %s", element, value, expected),
+		cause,
+	)
+}
+
+// setField converts text to the type of field and sets it
+func setField(field reflect.Value, text string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot bind into a field of type %s", field.Type())
+	}
+	return nil
+}
+
+// describeType names the kind of value a field expects, for error messages
+func describeType(t reflect.Type) string {
+	if t == durationType {
+		return "a duration"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return t.String()
+}
+
+// contains returns true if values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
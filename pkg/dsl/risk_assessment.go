@@ -1,8 +1,8 @@
 package dsl
 
 import (
+	"fmt"
 	"log"
-	"strconv"
 	
 	"github.com/rsqn/go-cdsl/pkg/context"
 	"github.com/rsqn/go-cdsl/pkg/types"
@@ -10,9 +10,9 @@ import (
 
 // RiskAssessmentModel represents the model for the RiskAssessment DSL
 type RiskAssessmentModel struct {
-	CustomerAge      string `json:"customerAge"`
-	TransactionValue string `json:"transactionValue"`
-	CountryCode      string `json:"countryCode"`
+	CustomerAge      int    `cdsl:"customerAge,default=30" doc:"Age of the customer in years"`
+	TransactionValue int    `cdsl:"transactionValue,default=1000" doc:"Value of the transaction"`
+	CountryCode      string `cdsl:"countryCode,default=US" doc:"ISO 3166 country code of the customer"`
 }
 
 // RiskAssessment is a DSL that performs risk assessment
@@ -20,75 +20,25 @@ type RiskAssessment struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *RiskAssessment) NewModel() interface{} {
+	return &RiskAssessmentModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *RiskAssessment) Describe() DslDescriptor {
+	return describeModelDsl(d, "Assesses the risk level of a customer")
+}
+
 // Execute implements Dsl
 func (d *RiskAssessment) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var customerAge, transactionValue, countryCode string
-	
-	// Try to extract attributes from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if ca, ok := m.Get("customerAge").(string); ok {
-			customerAge = ca
-		}
-		if tv, ok := m.Get("transactionValue").(string); ok {
-			transactionValue = tv
-		}
-		if cc, ok := m.Get("countryCode").(string); ok {
-			countryCode = cc
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if ca, ok := props["customerAge"].(string); ok {
-				customerAge = ca
-			}
-			if tv, ok := props["transactionValue"].(string); ok {
-				transactionValue = tv
-			}
-			if cc, ok := props["countryCode"].(string); ok {
-				countryCode = cc
-			}
-		} else {
-			if ca, ok := m["customerAge"].(string); ok {
-				customerAge = ca
-			}
-			if tv, ok := m["transactionValue"].(string); ok {
-				transactionValue = tv
-			}
-			if cc, ok := m["countryCode"].(string); ok {
-				countryCode = cc
-			}
-		}
-	}
-	
-	// Remove any trailing quotes
-	if len(customerAge) >= 2 && customerAge[len(customerAge)-1] == '"' {
-		customerAge = customerAge[:len(customerAge)-1]
-	}
-	if len(transactionValue) >= 2 && transactionValue[len(transactionValue)-1] == '"' {
-		transactionValue = transactionValue[:len(transactionValue)-1]
-	}
-	if len(countryCode) >= 2 && countryCode[len(countryCode)-1] == '"' {
-		countryCode = countryCode[:len(countryCode)-1]
-	}
-	
-	// Default values if not provided
-	if customerAge == "" {
-		customerAge = "30"
-	}
-	if transactionValue == "" {
-		transactionValue = "1000"
-	}
-	if countryCode == "" {
-		countryCode = "US"
+	m, err := boundModel[RiskAssessmentModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
-	log.Printf("RiskAssessment: Assessing risk for customer age %s, transaction value %s, country code %s", 
-		customerAge, transactionValue, countryCode)
-	
-	// Convert values
-	age, _ := strconv.Atoi(customerAge)
-	value, _ := strconv.Atoi(transactionValue)
+	log.Printf("RiskAssessment: Assessing risk for customer age %d, transaction value %d, country code %s", 
+		m.CustomerAge, m.TransactionValue, m.CountryCode)
 	
 	// Simple risk assessment logic
 	var riskLevel string
@@ -101,11 +51,11 @@ func (d *RiskAssessment) Execute(runtime *context.CdslRuntime, ctx *context.Cdsl
 		"SY": true, // Syria
 	}
 	
-	if highRiskCountries[countryCode] {
+	if highRiskCountries[m.CountryCode] {
 		riskLevel = "high"
-	} else if value > 10000 {
+	} else if m.TransactionValue > 10000 {
 		riskLevel = "high"
-	} else if value > 5000 || age < 25 {
+	} else if m.TransactionValue > 5000 || m.CustomerAge < 25 {
 		riskLevel = "medium"
 	} else {
 		riskLevel = "low"
@@ -119,7 +69,7 @@ func (d *RiskAssessment) Execute(runtime *context.CdslRuntime, ctx *context.Cdsl
 	}
 	
 	// Store additional risk factors
-	if err := ctx.PutVar("riskFactors", "age="+customerAge+",value="+transactionValue+",country="+countryCode); err != nil {
+	if err := ctx.PutVar("riskFactors", fmt.Sprintf("age=%d,value=%d,country=%s", m.CustomerAge, m.TransactionValue, m.CountryCode)); err != nil {
 		return nil, err
 	}
 	
@@ -1,8 +1,10 @@
 package dsl
 
 import (
+	gocontext "context"
 	"log"
 	"strconv"
+	"strings"
 	
 	"github.com/rsqn/go-cdsl/pkg/context"
 	"github.com/rsqn/go-cdsl/pkg/types"
@@ -10,45 +12,41 @@ import (
 
 // SanctionsCheckModel represents the model for the SanctionsCheck DSL
 type SanctionsCheckModel struct {
-	CheckType string `json:"checkType"`
+	CheckType string               `cdsl:"checkType,default=standard,enum=standard|enhanced" doc:"Depth of the screening"`
+	Lists     []SanctionsListModel `cdsl:"list" doc:"Sanctions list to screen against"`
+}
+
+// SanctionsListModel represents a <list name="..."/> child of the SanctionsCheck DSL
+type SanctionsListModel struct {
+	Name string `cdsl:"name,required" doc:"Name of the list, such as OFAC"`
 }
 
 // SanctionsCheck is a DSL that checks customer against sanctions lists
 type SanctionsCheck struct {
 	DslSupport
+	Screener SanctionsScreener `inject:",optional"`
+}
+
+// NewModel implements ModelDsl
+func (d *SanctionsCheck) NewModel() interface{} {
+	return &SanctionsCheckModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *SanctionsCheck) Describe() DslDescriptor {
+	return describeModelDsl(d, "Screens a customer against sanctions lists")
 }
 
 // Execute implements Dsl
 func (d *SanctionsCheck) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var checkType string
-	
-	// Try to extract attributes from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if ct, ok := m.Get("checkType").(string); ok {
-			checkType = ct
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if ct, ok := props["checkType"].(string); ok {
-				checkType = ct
-			}
-		} else {
-			if ct, ok := m["checkType"].(string); ok {
-				checkType = ct
-			}
-		}
-	}
-	
-	// Remove any trailing quotes
-	if len(checkType) >= 2 && checkType[len(checkType)-1] == '"' {
-		checkType = checkType[:len(checkType)-1]
-	}
-	
-	// Default value if not provided
-	if checkType == "" {
-		checkType = "standard"
+	return d.ExecuteContext(gocontext.Background(), runtime, ctx, model, input)
+}
+
+// ExecuteContext implements ContextDsl
+func (d *SanctionsCheck) ExecuteContext(goCtx gocontext.Context, runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	m, err := boundModel[SanctionsCheckModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
 	// Get customer information from context
@@ -61,6 +59,26 @@ func (d *SanctionsCheck) Execute(runtime *context.CdslRuntime, ctx *context.Cdsl
 	
 	// Perform sanctions check
 	passed := true
+	lists := make([]string, len(m.Lists))
+	for i, list := range m.Lists {
+		lists[i] = list.Name
+	}
+	if d.Screener != nil {
+		request := SanctionsScreeningRequest{
+			CustomerName: customerName,
+			CountryCode:  countryCode,
+			CheckType:    m.CheckType,
+			Lists:        lists,
+		}
+		if screener, ok := d.Screener.(ContextSanctionsScreener); ok {
+			passed, err = screener.ScreenContext(goCtx, request)
+		} else {
+			passed, err = d.Screener.Screen(request)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 	
 	// High-risk countries might require additional checks
 	highRiskCountries := map[string]bool{
@@ -70,12 +88,12 @@ func (d *SanctionsCheck) Execute(runtime *context.CdslRuntime, ctx *context.Cdsl
 		"SY": true, // Syria
 	}
 	
-	if highRiskCountries[countryCode] && checkType != "enhanced" {
+	if highRiskCountries[countryCode] && m.CheckType != "enhanced" {
 		log.Printf("SanctionsCheck: Warning - Customer from high-risk country %s but not using enhanced checks", countryCode)
 	}
 	
 	// For enhanced checks, we might want to perform additional verification
-	if checkType == "enhanced" {
+	if m.CheckType == "enhanced" {
 		log.Printf("SanctionsCheck: Performing enhanced sanctions check for customer %s", customerName)
 		// In a real implementation, this would perform additional checks
 	}
@@ -86,9 +104,17 @@ func (d *SanctionsCheck) Execute(runtime *context.CdslRuntime, ctx *context.Cdsl
 	}
 	
 	// Store check type in context
-	if err := ctx.PutVar("sanctionsCheckType", checkType); err != nil {
+	if err := ctx.PutVar("sanctionsCheckType", m.CheckType); err != nil {
 		return nil, err
 	}
 	
+	// Store the lists that were screened against, when configured
+	if len(lists) > 0 {
+		log.Printf("SanctionsCheck: Screened customer %s against lists %v", customerName, lists)
+		if err := ctx.PutVar("sanctionsListsChecked", strings.Join(lists, ",")); err != nil {
+			return nil, err
+		}
+	}
+	
 	return nil, nil
 }
@@ -10,7 +10,7 @@ import (
 
 // SayHelloModel represents the model for the SayHello DSL
 type SayHelloModel struct {
-	Name string `json:"name"`
+	Name string `cdsl:"name,default=World" doc:"Name to greet"`
 }
 
 // SayHello is a DSL that prints a greeting
@@ -18,37 +18,24 @@ type SayHello struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *SayHello) NewModel() interface{} {
+	return &SayHelloModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *SayHello) Describe() DslDescriptor {
+	return describeModelDsl(d, "Puts a greeting in the greeting variable")
+}
+
 // Execute implements Dsl
 func (d *SayHello) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var name string
-	
-	// Try to extract the name attribute from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if n, ok := m.Get("name").(string); ok {
-			name = n
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if n, ok := props["name"].(string); ok {
-				name = n
-			}
-		} else if n, ok := m["name"].(string); ok {
-			name = n
-		}
-	}
-	
-	if name == "" {
-		name = "World"
-	} else {
-		// Remove any trailing quotes
-		if len(name) >= 2 && name[len(name)-1] == '"' {
-			name = name[:len(name)-1]
-		}
+	m, err := boundModel[SayHelloModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
-	message := fmt.Sprintf("Hello, %s!", name)
+	message := fmt.Sprintf("Hello, %s!", m.Name)
 	log.Printf("SayHello: %s", message)
 	
 	if err := ctx.PutVar("greeting", message); err != nil {
@@ -9,7 +9,7 @@ import (
 
 // SetStateModel represents the model for the SetState DSL
 type SetStateModel struct {
-	Val string `json:"val"`
+	Val string `cdsl:"val,required,enum=Undefined|Alive|Await|End|Error" doc:"State to move the context to"`
 }
 
 // SetState is a DSL that sets the state of the context
@@ -17,40 +17,26 @@ type SetState struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *SetState) NewModel() interface{} {
+	return &SetStateModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *SetState) Describe() DslDescriptor {
+	return describeModelDsl(d, "Sets the state of the context")
+}
+
 // Execute implements Dsl
 func (d *SetState) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var val string
-	
-	// Try to extract the val attribute from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if v, ok := m.Get("val").(string); ok {
-			val = v
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if v, ok := props["val"].(string); ok {
-				val = v
-			}
-		} else if v, ok := m["val"].(string); ok {
-			val = v
-		}
-	}
-	
-	if val == "" {
-		log.Printf("SetState: Val is not a string or not found")
-		return nil, nil
-	}
-	
-	// Remove any trailing quotes
-	if len(val) >= 2 && val[len(val)-1] == '"' {
-		val = val[:len(val)-1]
+	m, err := boundModel[SetStateModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
-	log.Printf("SetState: Setting state to '%s'", val)
+	log.Printf("SetState: Setting state to '%s'", m.Val)
 	
-	switch val {
+	switch m.Val {
 	case "Undefined":
 		ctx.State = context.StateUndefined
 		log.Printf("STATE CHANGE: Context '%s', New State: Undefined", ctx.ID)
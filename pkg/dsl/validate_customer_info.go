@@ -10,7 +10,7 @@ import (
 
 // ValidateCustomerInfoModel represents the model for the ValidateCustomerInfo DSL
 type ValidateCustomerInfoModel struct {
-	StrictValidation string `json:"strictValidation"`
+	StrictValidation bool `cdsl:"strictValidation" doc:"Also require a two letter country code"`
 }
 
 // ValidateCustomerInfo is a DSL that validates customer information
@@ -18,27 +18,21 @@ type ValidateCustomerInfo struct {
 	DslSupport
 }
 
+// NewModel implements ModelDsl
+func (d *ValidateCustomerInfo) NewModel() interface{} {
+	return &ValidateCustomerInfoModel{}
+}
+
+// Describe implements DescribingDsl
+func (d *ValidateCustomerInfo) Describe() DslDescriptor {
+	return describeModelDsl(d, "Validates the collected customer information")
+}
+
 // Execute implements Dsl
 func (d *ValidateCustomerInfo) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
-	var strictValidation bool
-	
-	// Try to extract attributes from different model types
-	switch m := model.(type) {
-	case *MapModel:
-		if sv, ok := m.Get("strictValidation").(string); ok {
-			strictValidation = sv == "true"
-		}
-	case map[string]interface{}:
-		// Check if there's a Properties key
-		if props, ok := m["Properties"].(map[string]interface{}); ok {
-			if sv, ok := props["strictValidation"].(string); ok {
-				strictValidation = sv == "true"
-			}
-		} else {
-			if sv, ok := m["strictValidation"].(string); ok {
-				strictValidation = sv == "true"
-			}
-		}
+	m, err := boundModel[ValidateCustomerInfoModel](model)
+	if err != nil {
+		return nil, err
 	}
 	
 	// Get customer information from context
@@ -75,7 +69,7 @@ func (d *ValidateCustomerInfo) Execute(runtime *context.CdslRuntime, ctx *contex
 	}
 	
 	// Validate country code
-	if len(countryCode) != 2 && strictValidation {
+	if len(countryCode) != 2 && m.StrictValidation {
 		valid = false
 		validationErrors = append(validationErrors, "Invalid country code")
 	}
@@ -2,20 +2,28 @@ package exceptions
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/rsqn/go-cdsl/pkg/types"
 )
 
 // CdslError is the base error type for CDSL errors
 type CdslError struct {
-	Message string
-	Cause   error
+	Message  string
+	Cause    error
+	Position types.SourcePosition
 }
 
 // Error implements the error interface
 func (e *CdslError) Error() string {
+	message := e.Message
+	if !e.Position.IsZero() {
+		message = fmt.Sprintf("%s at %s", e.Message, e.Position)
+	}
 	if e.Cause != nil {
-		return fmt.Sprintf("%s: %v", e.Message, e.Cause)
+		return fmt.Sprintf("%s: %v", message, e.Cause)
 	}
-	return e.Message
+	return message
 }
 
 // Unwrap returns the underlying cause
@@ -31,6 +39,15 @@ func NewCdslError(message string, cause error) *CdslError {
 	}
 }
 
+// NewCdslErrorAt creates a new CdslError for a definition declared at position
+func NewCdslErrorAt(position types.SourcePosition, message string, cause error) *CdslError {
+	return &CdslError{
+		Message:  message,
+		Cause:    cause,
+		Position: position,
+	}
+}
+
 // CdslValidationError represents a validation error
 type CdslValidationError struct {
 	CdslError
@@ -45,3 +62,170 @@ func NewCdslValidationError(message string, cause error) *CdslValidationError {
 		},
 	}
 }
+
+// NewCdslValidationErrorAt creates a new CdslValidationError for a definition declared at position
+func NewCdslValidationErrorAt(position types.SourcePosition, message string, cause error) *CdslValidationError {
+	return &CdslValidationError{
+		CdslError: CdslError{
+			Message:  message,
+			Cause:    cause,
+			Position: position,
+		},
+	}
+}
+
+// CdslParseError represents an error encountered while parsing a definition document
+type CdslParseError struct {
+	CdslError
+	File   string
+	Line   int
+	Column int
+}
+
+// Error implements the error interface
+func (e *CdslParseError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.CdslError.Error())
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.CdslError.Error())
+}
+
+// NewCdslParseError creates a new CdslParseError
+func NewCdslParseError(file string, line int, column int, message string, cause error) *CdslParseError {
+	return &CdslParseError{
+		CdslError: CdslError{
+			Message: message,
+			Cause:   cause,
+		},
+		File:   file,
+		Line:   line,
+		Column: column,
+	}
+}
+
+// CdslDuplicateFlowError represents two documents defining the same flow ID
+type CdslDuplicateFlowError struct {
+	CdslError
+	FlowID          string
+	ExistingSource  string
+	DuplicateSource string
+}
+
+// NewCdslDuplicateFlowError creates a new CdslDuplicateFlowError
+func NewCdslDuplicateFlowError(flowID string, existingSource string, duplicateSource string) *CdslDuplicateFlowError {
+	return &CdslDuplicateFlowError{
+		CdslError: CdslError{
+			Message: fmt.Sprintf("Flow %s defined in %s is already defined in %s", flowID, duplicateSource, existingSource),
+		},
+		FlowID:          flowID,
+		ExistingSource:  existingSource,
+		DuplicateSource: duplicateSource,
+	}
+}
+
+// CdslFlowNotFoundError represents a flow, or a version of a flow, that is not registered. Version is
+// zero when the current version was asked for.
+type CdslFlowNotFoundError struct {
+	CdslError
+	FlowID  string
+	Version int
+}
+
+// NewCdslFlowNotFoundError creates a new CdslFlowNotFoundError, for the current version when version is zero
+func NewCdslFlowNotFoundError(flowID string, version int) *CdslFlowNotFoundError {
+	message := fmt.Sprintf("Flow %s is not registered", flowID)
+	if version != 0 {
+		message = fmt.Sprintf("Flow %s version %d is not registered", flowID, version)
+	}
+	return &CdslFlowNotFoundError{
+		CdslError: CdslError{
+			Message: message,
+		},
+		FlowID:  flowID,
+		Version: version,
+	}
+}
+
+// CdslCanceledError represents an execution stopped because its context.Context was canceled. It wraps
+// context.Canceled. StepID is empty if the execution was canceled before it reached a step, and ContextID
+// is also empty if it was canceled before its context was created or loaded.
+type CdslCanceledError struct {
+	CdslError
+	ContextID string
+	FlowID    string
+	StepID    string
+}
+
+// NewCdslCanceledError creates a new CdslCanceledError for an execution stopped with cause
+func NewCdslCanceledError(contextID string, flowID string, stepID string, cause error) *CdslCanceledError {
+	return &CdslCanceledError{
+		CdslError: CdslError{
+			Message: interruptedMessage("was canceled", contextID, flowID, stepID),
+			Cause:   cause,
+		},
+		ContextID: contextID,
+		FlowID:    flowID,
+		StepID:    stepID,
+	}
+}
+
+// CdslDeadlineExceededError represents an execution stopped because the deadline of its context.Context passed.
+// It wraps context.DeadlineExceeded. StepID is empty if the deadline passed before the execution reached a step,
+// and ContextID is also empty if it passed before its context was created or loaded.
+type CdslDeadlineExceededError struct {
+	CdslError
+	ContextID string
+	FlowID    string
+	StepID    string
+}
+
+// NewCdslDeadlineExceededError creates a new CdslDeadlineExceededError for an execution stopped with cause
+func NewCdslDeadlineExceededError(contextID string, flowID string, stepID string, cause error) *CdslDeadlineExceededError {
+	return &CdslDeadlineExceededError{
+		CdslError: CdslError{
+			Message: interruptedMessage("passed its deadline", contextID, flowID, stepID),
+			Cause:   cause,
+		},
+		ContextID: contextID,
+		FlowID:    flowID,
+		StepID:    stepID,
+	}
+}
+
+// interruptedMessage describes where an execution of a flow was stopped
+func interruptedMessage(reason string, contextID string, flowID string, stepID string) string {
+	if stepID == "" {
+		return fmt.Sprintf("Execution of flow %s %s before it started", flowID, reason)
+	}
+	return fmt.Sprintf("Execution of flow %s %s in step %s of context %s", flowID, reason, stepID, contextID)
+}
+
+// CdslExecutionLimitError represents an execution stopped because it exceeded one of its limits. Limit names the
+// limit, such as maxTransitions, and Cycle holds the transitions repeating at the end of the context's history,
+// if any, which usually show the routing loop that caused it.
+type CdslExecutionLimitError struct {
+	CdslError
+	ContextID string
+	FlowID    string
+	StepID    string
+	Limit     string
+	Cycle     []string
+}
+
+// NewCdslExecutionLimitError creates a new CdslExecutionLimitError for an execution that exceeded value of limit
+func NewCdslExecutionLimitError(contextID string, flowID string, stepID string, limit string, value interface{}, cycle []string) *CdslExecutionLimitError {
+	message := fmt.Sprintf("Execution of flow %s exceeded %s of %v in step %s of context %s", flowID, limit, value, stepID, contextID)
+	if len(cycle) > 0 {
+		message = fmt.Sprintf("%s, repeating %s -> %s", message, strings.Join(cycle, " -> "), cycle[0])
+	}
+	return &CdslExecutionLimitError{
+		CdslError: CdslError{
+			Message: message,
+		},
+		ContextID: contextID,
+		FlowID:    flowID,
+		StepID:    stepID,
+		Limit:     limit,
+		Cycle:     cycle,
+	}
+}
@@ -0,0 +1,126 @@
+package execution
+
+import (
+	gocontext "context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/model"
+)
+
+// DefaultExecutionLimits are the limits of a new FlowExecutor. They are far above what a flow that makes
+// progress needs, but stop a routing loop before it holds its lock for long.
+var DefaultExecutionLimits = model.ExecutionLimits{
+	MaxTransitions: 1000,
+	MaxElements:    10000,
+}
+
+// errMaxDuration is the cause of the context.Context of an execution that ran for longer than its MaxDuration
+var errMaxDuration = errors.New("maxDuration exceeded")
+
+// executionBudget counts the transitions and elements of one execution against its limits, and bounds its
+// duration with a context.Context that times out after MaxDuration. When a limit is exceeded the execution
+// may continue at the error step of the flow, which runs without limits but may not route anywhere else.
+type executionBudget struct {
+	limits      model.ExecutionLimits
+	parent      gocontext.Context
+	goCtx       gocontext.Context
+	transitions int
+	elements    int
+	exceeded    *exceptions.CdslExecutionLimitError
+	recovering  bool
+}
+
+// newExecutionBudget creates a budget for an execution with goCtx that started at started. The returned
+// function releases the timer of MaxDuration.
+func newExecutionBudget(goCtx gocontext.Context, limits model.ExecutionLimits, started time.Time) (*executionBudget, gocontext.CancelFunc) {
+	budget := &executionBudget{limits: limits, parent: goCtx, goCtx: goCtx}
+	if limits.MaxDuration <= 0 {
+		return budget, func() {}
+	}
+	var cancel gocontext.CancelFunc
+	budget.goCtx, cancel = gocontext.WithDeadlineCause(goCtx, started.Add(limits.MaxDuration), errMaxDuration)
+	return budget, cancel
+}
+
+// context returns the context.Context to execute elements with, which no longer times out once a limit has
+// been exceeded so the error step can run
+func (b *executionBudget) context() gocontext.Context {
+	if b.exceeded != nil {
+		return b.parent
+	}
+	return b.goCtx
+}
+
+// enter counts a transition into step
+func (b *executionBudget) enter(ctx *context.CdslContext, flow *model.Flow, step *model.FlowStep) error {
+	if b.exceeded != nil {
+		if b.recovering {
+			return b.exceeded
+		}
+		b.recovering = true
+		return nil
+	}
+
+	b.transitions++
+	if b.limits.MaxTransitions > 0 && b.transitions > b.limits.MaxTransitions {
+		return exceeded(ctx, flow, step, "maxTransitions", b.limits.MaxTransitions)
+	}
+	return nil
+}
+
+// execute counts an element of step
+func (b *executionBudget) execute(ctx *context.CdslContext, flow *model.Flow, step *model.FlowStep) error {
+	if b.exceeded != nil {
+		return nil
+	}
+
+	b.elements++
+	if b.limits.MaxElements > 0 && b.elements > b.limits.MaxElements {
+		return exceeded(ctx, flow, step, "maxElements", b.limits.MaxElements)
+	}
+	return nil
+}
+
+// timedOut returns the limit error for an execution whose elements in step were stopped with err because it
+// ran for longer than MaxDuration, or err unchanged
+func (b *executionBudget) timedOut(ctx *context.CdslContext, flow *model.Flow, step *model.FlowStep, err error) error {
+	if err == nil || b.parent.Err() != nil || !errors.Is(gocontext.Cause(b.goCtx), errMaxDuration) {
+		return err
+	}
+	return exceeded(ctx, flow, step, "maxDuration", b.limits.MaxDuration)
+}
+
+// recover reports whether an execution that failed with err may continue at the error step, which it may
+// once for an exceeded limit
+func (b *executionBudget) recover(err error) bool {
+	var limitErr *exceptions.CdslExecutionLimitError
+	if !errors.As(err, &limitErr) {
+		return true
+	}
+	if b.exceeded != nil {
+		return false
+	}
+	b.exceeded = limitErr
+	return true
+}
+
+// exceeded returns the error for an execution that exceeded value of limit in step
+func exceeded(ctx *context.CdslContext, flow *model.Flow, step *model.FlowStep, limit string, value interface{}) error {
+	return exceptions.NewCdslExecutionLimitError(ctx.ID, flow.ID, step.ID, limit, value, repeatingCycle(ctx.Transitions))
+}
+
+// repeatingCycle returns the shortest sequence of transitions that ends the history twice in a row, or nil if
+// the history does not end in a repetition
+func repeatingCycle(transitions []string) []string {
+	n := len(transitions)
+	for length := 1; length*2 <= n; length++ {
+		if slices.Equal(transitions[n-2*length:n-length], transitions[n-length:]) {
+			return slices.Clone(transitions[n-length:])
+		}
+	}
+	return nil
+}
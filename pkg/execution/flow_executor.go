@@ -1,10 +1,12 @@
 package execution
 
 import (
-	"encoding/json"
+	gocontext "context"
+	"errors"
 	"fmt"
 	"log"
-	"reflect"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +15,7 @@ import (
 	"github.com/rsqn/go-cdsl/pkg/dsl"
 	"github.com/rsqn/go-cdsl/pkg/exceptions"
 	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/registry"
 	"github.com/rsqn/go-cdsl/pkg/types"
 )
 
@@ -22,6 +25,24 @@ type FlowRegistry interface {
 	GetFlow(id string) (*model.Flow, error)
 }
 
+// VersionedFlowRegistry is implemented by registries that keep earlier versions of flows,
+// allowing contexts to resume on the version they were created with
+type VersionedFlowRegistry interface {
+	FlowRegistry
+	
+	// GetFlowVersion retrieves a specific version of a flow
+	GetFlowVersion(id string, version int) (*model.Flow, error)
+}
+
+// SubscribingFlowRegistry is implemented by registries that publish their changes, which the executor uses
+// to drop the plans of unregistered flows and retired flow versions
+type SubscribingFlowRegistry interface {
+	FlowRegistry
+	
+	// Subscribe registers a listener notified of every change to the registry
+	Subscribe(listener func(registry.FlowRegistryEvent)) func()
+}
+
 // DslInitHelper is an interface for resolving DSL instances
 type DslInitHelper interface {
 	// Resolve resolves a DSL instance from metadata
@@ -39,6 +60,15 @@ type FlowExecutor struct {
 	LockDuration         time.Duration
 	LockRetryMaxDuration time.Duration
 	MyIdentifier         string
+	// Limits bound each execution, and a flow's own limits override them field by field
+	Limits               model.ExecutionLimits
+	// MaxPlans bounds the compiled plans kept, the oldest being dropped first
+	MaxPlans             int
+	// plans holds the compiled plan of each flow version executed, and planOrder the order they were compiled in
+	plans       map[*model.Flow]*FlowPlan
+	planOrder   []*model.Flow
+	plansMu     sync.RWMutex
+	unsubscribe func()
 }
 
 // NewFlowExecutor creates a new FlowExecutor
@@ -48,93 +78,59 @@ func NewFlowExecutor() *FlowExecutor {
 		LockDuration:         30 * time.Second,
 		LockRetryMaxDuration: 1 * time.Second,
 		MyIdentifier:         "<anonymous>",
+		Limits:               DefaultExecutionLimits,
+		MaxPlans:             DefaultMaxPlans,
 	}
 }
 
-// intersectModel creates a deep copy of the model
-func (e *FlowExecutor) intersectModel(src interface{}) interface{} {
-	if src == nil {
-		return nil
-	}
-	
-	// Debug the model
-	e.debugModel(src)
-	
-	data, err := json.Marshal(src)
-	if err != nil {
-		return src // fallback to original if marshaling fails
-	}
-	
-	var result interface{}
-	if err := json.Unmarshal(data, &result); err != nil {
-		return src // fallback to original if unmarshaling fails
-	}
-	
-	return result
-}
+// DefaultMaxPlans is the number of compiled plans a new FlowExecutor keeps
+const DefaultMaxPlans = 256
 
-// debugModel prints debug information about the model
-func (e *FlowExecutor) debugModel(model interface{}) {
-	if model == nil {
-		log.Printf("DEBUG: Model is nil")
-		return
-	}
-	
-	log.Printf("DEBUG: Model type: %T", model)
-	
-	switch m := model.(type) {
-	case *dsl.MapModel:
-		log.Printf("DEBUG: MapModel properties: %+v", m.Properties)
-	case map[string]interface{}:
-		log.Printf("DEBUG: map[string]interface{} contents: %+v", m)
-	default:
-		// Try to use reflection
-		val := reflect.ValueOf(model)
-		if val.Kind() == reflect.Ptr {
-			val = val.Elem()
-		}
-		
-		if val.Kind() == reflect.Struct {
-			log.Printf("DEBUG: Struct with %d fields", val.NumField())
-			for i := 0; i < val.NumField(); i++ {
-				field := val.Type().Field(i)
-				fieldVal := val.Field(i)
-				if fieldVal.CanInterface() {
-					log.Printf("DEBUG: Field %s = %v", field.Name, fieldVal.Interface())
-				} else {
-					log.Printf("DEBUG: Field %s (cannot access value)", field.Name)
-				}
-			}
-		}
-	}
-}
-
-// obtainOutputs executes a list of DSL elements and returns the first output event
+// obtainOutputs executes the compiled elements of a step and returns the first output event. It stops before
+// an element when goCtx is done, returning the error of interrupted, or when the budget is exceeded.
 func (e *FlowExecutor) obtainOutputs(
+	goCtx gocontext.Context,
+	budget *executionBudget,
 	runtime *context.CdslRuntime,
 	ctx *context.CdslContext,
 	inputEvent *types.CdslInputEvent,
 	flow *model.Flow,
 	step *model.FlowStep,
-	elements []types.DslMetadata,
+	elements []elementPlan,
 ) (*types.CdslOutputEvent, error) {
-	for _, dslMeta := range elements {
-		runtime.GetAuditor().Execute(ctx, flow.ID, step.ID, dslMeta.Name)
-		log.Printf("DSL EXECUTE: Flow '%s', Step '%s', Element '%s'", flow.ID, step.ID, dslMeta.Name)
+	for i := range elements {
+		if goCtx.Err() != nil {
+			return nil, interrupted(goCtx, ctx, flow, step)
+		}
 		
-		dslInstance := e.DslInitHelper.Resolve(dslMeta)
-		if dslInstance == nil {
-			return nil, exceptions.NewCdslError(fmt.Sprintf("Failed to resolve DSL %s", dslMeta.Name), nil)
+		element := &elements[i]
+		dslMeta := &element.meta
+		if err := budget.execute(ctx, flow, step); err != nil {
+			runtime.GetAuditor().Error(ctx, flow.ID, step.ID, dslMeta.Name, dslMeta.Position, err)
+			return nil, err
 		}
 		
-		// Build or intersect model
-		model := e.intersectModel(dslMeta.Model)
+		runtime.GetAuditor().Execute(ctx, flow.ID, step.ID, dslMeta.Name, dslMeta.Position)
+		log.Printf("DSL EXECUTE: Flow '%s', Step '%s', Element '%s' at %s", flow.ID, step.ID, dslMeta.Name, dslMeta.Position)
+		
+		if element.err != nil {
+			runtime.GetAuditor().Error(ctx, flow.ID, step.ID, dslMeta.Name, dslMeta.Position, element.err)
+			return nil, element.err
+		}
 		
 		// Execute the step
-		output, err := dslInstance.Execute(runtime, ctx, model, inputEvent)
+		output, err := element.dsl.ExecuteContext(goCtx, runtime, ctx, element.executionModel(), inputEvent)
 		if err != nil {
-			log.Printf("DSL ERROR: Flow '%s', Step '%s', Element '%s': %v", flow.ID, step.ID, dslMeta.Name, err)
-			return nil, err
+			if isInterruption(goCtx, err) {
+				return nil, interrupted(goCtx, ctx, flow, step)
+			}
+			log.Printf("DSL ERROR: Flow '%s', Step '%s', Element '%s' at %s: %v", flow.ID, step.ID, dslMeta.Name, dslMeta.Position, err)
+			runtime.GetAuditor().Error(ctx, flow.ID, step.ID, dslMeta.Name, dslMeta.Position, err)
+			return nil, exceptions.NewCdslErrorAt(
+				dslMeta.Position,
+				fmt.Sprintf("DSL %s failed in step %s of flow %s", dslMeta.Name, step.ID, flow.ID),
+				err,
+			)
 		}
 		
 		// Handle output if required
@@ -148,11 +144,195 @@ func (e *FlowExecutor) obtainOutputs(
 	return nil, nil
 }
 
+// interrupted returns the typed error for an execution stopped in step because goCtx is done
+func interrupted(goCtx gocontext.Context, ctx *context.CdslContext, flow *model.Flow, step *model.FlowStep) error {
+	contextID, stepID := "", ""
+	if ctx != nil {
+		contextID = ctx.ID
+	}
+	if step != nil {
+		stepID = step.ID
+	}
+	
+	if errors.Is(goCtx.Err(), gocontext.DeadlineExceeded) {
+		return exceptions.NewCdslDeadlineExceededError(contextID, flow.ID, stepID, goCtx.Err())
+	}
+	return exceptions.NewCdslCanceledError(contextID, flow.ID, stepID, goCtx.Err())
+}
+
+// obtainLock locks resource for an execution of flow. A ContextLockProvider is given goCtx, and any other
+// provider may spend no longer retrying than is left before the deadline of goCtx. A lock obtained once goCtx
+// is done is released again.
+func (e *FlowExecutor) obtainLock(goCtx gocontext.Context, flow *model.Flow, resource string) (*concurrency.Lock, error) {
+	var lock *concurrency.Lock
+	var err error
+	if provider, ok := e.LockProvider.(concurrency.ContextLockProvider); ok {
+		lock, err = provider.ObtainContext(goCtx, e.MyIdentifier, resource, e.LockDuration, e.LockRetries, e.LockRetryMaxDuration)
+	} else {
+		retryMaxDuration := e.LockRetryMaxDuration
+		if deadline, ok := goCtx.Deadline(); ok {
+			retryMaxDuration = max(min(retryMaxDuration, time.Until(deadline)), 0)
+		}
+		lock, err = e.LockProvider.Obtain(e.MyIdentifier, resource, e.LockDuration, e.LockRetries, retryMaxDuration)
+	}
+	
+	if goCtx.Err() != nil {
+		if lock != nil {
+			_ = e.LockProvider.Release(lock)
+		}
+		return nil, interrupted(goCtx, nil, flow, nil)
+	}
+	return lock, err
+}
+
+// isInterruption reports whether err is the error of an execution stopped because goCtx is done
+func isInterruption(goCtx gocontext.Context, err error) bool {
+	return goCtx.Err() != nil && errors.Is(err, goCtx.Err())
+}
+
+// suspend saves a context whose execution was interrupted in step. The context keeps its state and current
+// step, so executing it again restarts the interrupted step. Tasks queued by the execution are discarded.
+func (e *FlowExecutor) suspend(runtime *context.CdslRuntime, ctx *context.CdslContext, flow *model.Flow, step *model.FlowStep, cause error) error {
+	log.Printf("STEP INTERRUPTED: Flow '%s', Step '%s': %v", flow.ID, step.ID, cause)
+	runtime.GetAuditor().Error(ctx, flow.ID, step.ID, "", step.Position, cause)
+	runtime.ClearPostStepTasks()
+	runtime.ClearPostCommitTasks()
+	
+	ctx.Interrupted = true
+	if err := e.ContextRepository.SaveContext(runtime.GetTransactionID(), ctx); err != nil {
+		return errors.Join(cause, err)
+	}
+	return cause
+}
+
+// Compile returns the plan of a flow, compiling it the first time the flow is executed or compiled. Plans are
+// kept until MaxPlans newer plans have been compiled, or until the flow is unregistered or the version it was
+// compiled from is retired from a registry that publishes changes.
+func (e *FlowExecutor) Compile(flow *model.Flow) *FlowPlan {
+	e.plansMu.RLock()
+	plan, compiled := e.plans[flow]
+	e.plansMu.RUnlock()
+	if compiled {
+		return plan
+	}
+	
+	plan = CompileFlow(flow, e.DslInitHelper)
+	
+	e.plansMu.Lock()
+	defer e.plansMu.Unlock()
+	
+	if existing, compiled := e.plans[flow]; compiled {
+		return existing
+	}
+	if e.unsubscribe == nil {
+		if subscribing, ok := e.FlowRegistry.(SubscribingFlowRegistry); ok {
+			e.unsubscribe = subscribing.Subscribe(e.forget)
+		}
+	}
+	if e.plans == nil {
+		e.plans = make(map[*model.Flow]*FlowPlan)
+	}
+	for e.MaxPlans > 0 && len(e.planOrder) >= e.MaxPlans {
+		e.dropPlan(e.planOrder[0])
+	}
+	e.plans[flow] = plan
+	e.planOrder = append(e.planOrder, flow)
+	return plan
+}
+
+// Close unsubscribes the executor from its registry, so it can be garbage collected while the registry lives
+// on, and drops its plans. The executor may still be used, and subscribes again when it next compiles a flow.
+func (e *FlowExecutor) Close() {
+	e.plansMu.Lock()
+	defer e.plansMu.Unlock()
+	
+	if e.unsubscribe != nil {
+		e.unsubscribe()
+		e.unsubscribe = nil
+	}
+	e.plans = nil
+	e.planOrder = nil
+}
+
+// forget drops the plans of unregistered flows and retired flow versions
+func (e *FlowExecutor) forget(event registry.FlowRegistryEvent) {
+	if event.Type != registry.FlowUnregistered && event.Type != registry.FlowVersionRetired {
+		return
+	}
+	
+	e.plansMu.Lock()
+	defer e.plansMu.Unlock()
+	
+	for _, flow := range slices.Clone(e.planOrder) {
+		if flow.ID == event.FlowID && (event.Type == registry.FlowUnregistered || flow.Version == event.Version) {
+			e.dropPlan(flow)
+		}
+	}
+}
+
+// dropPlan drops the plan of a flow, with plansMu held
+func (e *FlowExecutor) dropPlan(flow *model.Flow) {
+	delete(e.plans, flow)
+	e.planOrder = slices.DeleteFunc(e.planOrder, func(f *model.Flow) bool { return f == flow })
+}
+
+// pinnedFlow returns the version of flow that ctx was created with, if the registry keeps versions
+func (e *FlowExecutor) pinnedFlow(flow *model.Flow, ctx *context.CdslContext) (*model.Flow, error) {
+	versioned, ok := e.FlowRegistry.(VersionedFlowRegistry)
+	if !ok || ctx.FlowVersion == 0 || ctx.CurrentFlow != flow.ID || ctx.FlowVersion == flow.Version {
+		return flow, nil
+	}
+	
+	pinned, err := versioned.GetFlowVersion(flow.ID, ctx.FlowVersion)
+	var notFound *exceptions.CdslFlowNotFoundError
+	if errors.As(err, &notFound) || (err == nil && pinned == nil) {
+		return nil, exceptions.NewCdslError(fmt.Sprintf("Flow %s version %d of context %s is no longer registered", flow.ID, ctx.FlowVersion, ctx.ID), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pinned, nil
+}
+
+// ExecuteFlow looks up the current version of a flow in the registry and executes it with the given input event,
+// returning the registry's CdslFlowNotFoundError if the flow is not registered
+func (e *FlowExecutor) ExecuteFlow(flowID string, inputEvent *types.CdslInputEvent) (*types.CdslFlowOutputEvent, error) {
+	return e.ExecuteFlowContext(gocontext.Background(), flowID, inputEvent)
+}
+
+// ExecuteFlowContext is ExecuteFlow stopped when goCtx is done, as described on ExecuteContext
+func (e *FlowExecutor) ExecuteFlowContext(goCtx gocontext.Context, flowID string, inputEvent *types.CdslInputEvent) (*types.CdslFlowOutputEvent, error) {
+	flow, err := e.FlowRegistry.GetFlow(flowID)
+	if err != nil {
+		return nil, err
+	}
+	if flow == nil {
+		return nil, exceptions.NewCdslFlowNotFoundError(flowID, 0)
+	}
+	return e.ExecuteContext(goCtx, flow, inputEvent)
+}
+
 // Execute executes a flow with the given input event
 func (e *FlowExecutor) Execute(flow *model.Flow, inputEvent *types.CdslInputEvent) (*types.CdslFlowOutputEvent, error) {
+	return e.ExecuteContext(gocontext.Background(), flow, inputEvent)
+}
+
+// ExecuteContext executes a flow with the given input event, passing goCtx to every dsl.ContextDsl. When goCtx
+// is done the execution stops before the next element and returns a CdslCanceledError or
+// CdslDeadlineExceededError. A context that was loaded or created is saved at the interrupted step, without
+// its queued tasks, and its lock released, so it can be executed again with its ID.
+//
+// Each call is bounded by the limits of the flow and executor, and DSLs are given a context.Context that times
+// out after MaxDuration. An execution that exceeds one continues at the error step of the flow, which may not
+// route elsewhere, or returns a CdslExecutionLimitError.
+func (e *FlowExecutor) ExecuteContext(goCtx gocontext.Context, flow *model.Flow, inputEvent *types.CdslInputEvent) (*types.CdslFlowOutputEvent, error) {
 	if flow == nil {
 		return nil, exceptions.NewCdslError("Flow must be provided", nil)
 	}
+	if goCtx.Err() != nil {
+		return nil, interrupted(goCtx, nil, flow, nil)
+	}
+	started := time.Now()
 	
 	var lock *concurrency.Lock
 	var ctx *context.CdslContext
@@ -166,13 +346,11 @@ func (e *FlowExecutor) Execute(flow *model.Flow, inputEvent *types.CdslInputEven
 			// Create a new context
 			ctx = context.NewCdslContext()
 			ctx.ID = uuid.New().String()
-			lock, err = e.LockProvider.Obtain(
-				e.MyIdentifier,
-				"context/"+ctx.ID,
-				e.LockDuration,
-				e.LockRetries,
-				e.LockRetryMaxDuration,
-			)
+			ctx.CurrentFlow = flow.ID
+			ctx.FlowVersion = flow.Version
+			ctx.FlowChecksum = flow.Checksum
+			ctx.DocumentChecksum = flow.DocumentChecksum
+			lock, err = e.obtainLock(goCtx, flow, "context/"+ctx.ID)
 			if err != nil {
 				return nil, err
 			}
@@ -185,15 +363,12 @@ func (e *FlowExecutor) Execute(flow *model.Flow, inputEvent *types.CdslInputEven
 			if err != nil {
 				return nil, err
 			}
+			if goCtx.Err() != nil {
+				return nil, interrupted(goCtx, ctx, flow, nil)
+			}
 		} else {
 			// Lock and load an existing context
-			lock, err = e.LockProvider.Obtain(
-				e.MyIdentifier,
-				"context/"+inputEvent.ContextID,
-				e.LockDuration,
-				e.LockRetries,
-				e.LockRetryMaxDuration,
-			)
+			lock, err = e.obtainLock(goCtx, flow, "context/"+inputEvent.ContextID)
 			if err != nil {
 				return nil, err
 			}
@@ -202,10 +377,18 @@ func (e *FlowExecutor) Execute(flow *model.Flow, inputEvent *types.CdslInputEven
 			if err != nil {
 				return nil, err
 			}
+			if goCtx.Err() != nil {
+				return nil, interrupted(goCtx, ctx, flow, nil)
+			}
 			
 			if ctx.State == context.StateEnd {
 				return nil, exceptions.NewCdslError(fmt.Sprintf("State of %s is End", ctx.ID), nil)
 			}
+			
+			// Resume on the version of the flow the context started on
+			if flow, err = e.pinnedFlow(flow, ctx); err != nil {
+				return nil, err
+			}
 		}
 		
 		// Get or determine current step
@@ -217,39 +400,65 @@ func (e *FlowExecutor) Execute(flow *model.Flow, inputEvent *types.CdslInputEven
 		runtime.SetAuditor(e.Auditor)
 		runtime.SetTransactionID(lock.ID)
 		ctx.SetRuntime(runtime)
+		budget, cancel := newExecutionBudget(goCtx, flow.Limits.Or(e.Limits), started)
+		defer cancel()
 		
 		// Get the step
-		var step *model.FlowStep
-		nextStep := flow.FetchStep(ctx.CurrentStep)
+		plan := e.Compile(flow)
+		var step *stepPlan
+		nextStep := plan.step(ctx.CurrentStep)
 		var outputEvent *types.CdslFlowOutputEvent
 		
 		if inputEvent.RequestedStep != "" {
-			nextStep = flow.FetchStep(inputEvent.RequestedStep)
+			nextStep = plan.step(inputEvent.RequestedStep)
 			if nextStep == nil {
 				return nil, exceptions.NewCdslError(fmt.Sprintf("Requested step %s was not found", inputEvent.RequestedStep), nil)
 			}
 			ctx.CurrentStep = inputEvent.RequestedStep
 		}
 		
+		// The interrupted step of a context was entered by the execution that was stopped, so it is restarted
+		// without another transition
+		resuming := ctx.Interrupted && inputEvent.RequestedStep == ""
+		ctx.Interrupted = false
+		
 		for nextStep != nil {
 			ctx.CurrentStep = nextStep.ID
-			ctx.PushTransition(flow.ID + "/" + nextStep.ID)
-			runtime.GetAuditor().Transition(ctx, flow.ID, nextStep.ID)
-			
-			log.Printf("STEP ENTER: Flow '%s', Step '%s'", flow.ID, nextStep.ID)
-			
 			step = nextStep
 			nextStep = nil
 			
+			if resuming {
+				resuming = false
+				log.Printf("STEP RESUME: Flow '%s', Step '%s'", flow.ID, step.ID)
+			} else {
+				ctx.PushTransition(flow.ID + "/" + step.ID)
+				runtime.GetAuditor().Transition(ctx, flow.ID, step.ID)
+				
+				log.Printf("STEP ENTER: Flow '%s', Step '%s'", flow.ID, step.ID)
+				
+				if err := budget.enter(ctx, flow, step.FlowStep); err != nil {
+					runtime.GetAuditor().Error(ctx, flow.ID, step.ID, "", step.Position, err)
+					if flow.ErrorStep != "" && budget.recover(err) {
+						nextStep = plan.step(flow.ErrorStep)
+						log.Printf("STEP ERROR: Flow '%s', Step '%s': %v", flow.ID, step.ID, err)
+						continue
+					}
+					return nil, err
+				}
+			}
+			
 			var result *types.CdslOutputEvent
 			var err error
 			
 			// Execute logic elements
-			generalOutput, err := e.obtainOutputs(runtime, ctx, inputEvent, flow, step, step.LogicElements)
+			generalOutput, err := e.obtainOutputs(budget.context(), budget, runtime, ctx, inputEvent, flow, step.FlowStep, step.logic)
+			err = budget.timedOut(ctx, flow, step.FlowStep, err)
+			if isInterruption(goCtx, err) {
+				return nil, e.suspend(runtime, ctx, flow, step.FlowStep, err)
+			}
 			if err != nil {
-				if flow.ErrorStep != "" {
-					nextStep = flow.FetchStep(flow.ErrorStep)
-					runtime.GetAuditor().Error(ctx, flow.ID, step.ID, "", err)
+				if flow.ErrorStep != "" && budget.recover(err) {
+					nextStep = plan.step(flow.ErrorStep)
 					log.Printf("STEP ERROR: Flow '%s', Step '%s': %v", flow.ID, step.ID, err)
 					continue
 				}
@@ -257,11 +466,14 @@ func (e *FlowExecutor) Execute(flow *model.Flow, inputEvent *types.CdslInputEven
 			}
 			
 			// Execute final elements
-			finalOutput, err := e.obtainOutputs(runtime, ctx, inputEvent, flow, step, step.FinalElements)
+			finalOutput, err := e.obtainOutputs(budget.context(), budget, runtime, ctx, inputEvent, flow, step.FlowStep, step.final)
+			err = budget.timedOut(ctx, flow, step.FlowStep, err)
+			if isInterruption(goCtx, err) {
+				return nil, e.suspend(runtime, ctx, flow, step.FlowStep, err)
+			}
 			if err != nil {
-				if flow.ErrorStep != "" {
-					nextStep = flow.FetchStep(flow.ErrorStep)
-					runtime.GetAuditor().Error(ctx, flow.ID, step.ID, "", err)
+				if flow.ErrorStep != "" && budget.recover(err) {
+					nextStep = plan.step(flow.ErrorStep)
 					log.Printf("STEP ERROR: Flow '%s', Step '%s': %v", flow.ID, step.ID, err)
 					continue
 				}
@@ -280,7 +492,7 @@ func (e *FlowExecutor) Execute(flow *model.Flow, inputEvent *types.CdslInputEven
 				func() {
 					defer func() {
 						if r := recover(); r != nil {
-							runtime.GetAuditor().Error(ctx, flow.ID, step.ID, "", fmt.Errorf("panic in post step task: %v", r))
+							runtime.GetAuditor().Error(ctx, flow.ID, step.ID, "", step.Position, fmt.Errorf("panic in post step task: %v", r))
 						}
 					}()
 					
@@ -294,7 +506,7 @@ func (e *FlowExecutor) Execute(flow *model.Flow, inputEvent *types.CdslInputEven
 				switch result.Action {
 				case types.ActionRoute:
 					ctx.CurrentStep = result.NextRoute
-					nextStep = flow.FetchStep(result.NextRoute)
+					nextStep = plan.step(result.NextRoute)
 					if nextStep == nil {
 						return nil, exceptions.NewCdslError(fmt.Sprintf("Invalid Route %s", result.NextRoute), nil)
 					}
@@ -0,0 +1,118 @@
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/types"
+)
+
+// FlowPlan is a flow compiled for execution. Every element has its DSL resolved, adapted to dsl.ContextDsl and,
+// for a dsl.ModelDsl, its typed model bound once, so executing the plan does no lookups or binding. The DSL
+// instances and typed models of a plan are shared by every execution of it: DSLs must not keep execution state
+// in their fields or modify a typed model. Any other DSL is given its own copy of the element's *dsl.MapModel on
+// each execution, as it was before flows were compiled.
+type FlowPlan struct {
+	Flow  *model.Flow
+	steps map[string]*stepPlan
+}
+
+// stepPlan is a compiled step
+type stepPlan struct {
+	*model.FlowStep
+	logic []elementPlan
+	final []elementPlan
+}
+
+// elementPlan is an element ready to execute, or the error that prevented it being compiled
+type elementPlan struct {
+	meta  types.DslMetadata
+	dsl   dsl.ContextDsl
+	model interface{}
+	// copied is set when model is a *dsl.MapModel shared with the registry, which is copied for each execution
+	copied bool
+	err    error
+}
+
+// CompileFlow compiles a flow, resolving its DSLs with resolver. Elements that cannot be resolved or bound
+// fail when they are executed, as they would without compilation, and are reported by Err.
+func CompileFlow(flow *model.Flow, resolver DslInitHelper) *FlowPlan {
+	plan := &FlowPlan{
+		Flow:  flow,
+		steps: make(map[string]*stepPlan, len(flow.Steps)),
+	}
+	for id, step := range flow.Steps {
+		plan.steps[id] = &stepPlan{
+			FlowStep: step,
+			logic:    compileElements(flow, step, step.LogicElements, resolver),
+			final:    compileElements(flow, step, step.FinalElements, resolver),
+		}
+	}
+	return plan
+}
+
+// compileElements compiles the elements of a step
+func compileElements(flow *model.Flow, step *model.FlowStep, elements []types.DslMetadata, resolver DslInitHelper) []elementPlan {
+	plans := make([]elementPlan, len(elements))
+	for i, meta := range elements {
+		plans[i] = compileElement(flow, step, meta, resolver)
+	}
+	return plans
+}
+
+// compileElement resolves the DSL of an element and binds its model
+func compileElement(flow *model.Flow, step *model.FlowStep, meta types.DslMetadata, resolver DslInitHelper) elementPlan {
+	instance := resolver.Resolve(meta)
+	if instance == nil {
+		return elementPlan{meta: meta, err: exceptions.NewCdslErrorAt(meta.Position, fmt.Sprintf("Failed to resolve DSL %s", meta.Name), nil)}
+	}
+
+	bound, err := dsl.Bind(instance, meta.Model)
+	if err != nil {
+		return elementPlan{meta: meta, err: exceptions.NewCdslErrorAt(
+			meta.Position,
+			fmt.Sprintf("Invalid model for DSL %s in step %s of flow %s", meta.Name, step.ID, flow.ID),
+			err,
+		)}
+	}
+	_, copied := bound.(*dsl.MapModel)
+	return elementPlan{meta: meta, dsl: dsl.WithContext(instance), model: bound, copied: copied}
+}
+
+// executionModel returns the model to execute the element with
+func (p *elementPlan) executionModel() interface{} {
+	if p.copied {
+		return p.model.(*dsl.MapModel).Copy()
+	}
+	return p.model
+}
+
+// step returns the plan of a step, or nil if the flow has no step with the ID
+func (p *FlowPlan) step(id string) *stepPlan {
+	return p.steps[id]
+}
+
+// Err returns the errors of every element that could not be compiled, ordered by step ID
+func (p *FlowPlan) Err() error {
+	ids := make([]string, 0, len(p.steps))
+	for id := range p.steps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var errs []error
+	for _, id := range ids {
+		for _, elements := range [][]elementPlan{p.steps[id].logic, p.steps[id].final} {
+			for _, element := range elements {
+				if element.err != nil {
+					errs = append(errs, element.err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
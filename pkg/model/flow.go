@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/rsqn/go-cdsl/pkg/definitionsource"
 	"github.com/rsqn/go-cdsl/pkg/types"
 )
@@ -8,9 +10,42 @@ import (
 // Flow represents a flow definition
 type Flow struct {
 	ID          string
+	Extends     string
 	DefaultStep string
 	ErrorStep   string
+	Limits      ExecutionLimits
 	Steps       map[string]*FlowStep
+	Position    types.SourcePosition
+	// Checksum identifies the content of the flow as executed and DocumentChecksum the
+	// document it was defined in, both as hex encoded SHA-256
+	Checksum         string
+	DocumentChecksum string
+	// Source is the source of the document the flow was loaded from, empty for flows built in code
+	Source string
+	// Version is assigned by the registry, starting at 1 for each flow ID
+	Version int
+}
+
+// ExecutionLimits bound a single execution of a flow, guarding against routing loops and runaway executions.
+// A zero field sets no limit.
+type ExecutionLimits struct {
+	MaxTransitions int
+	MaxElements    int
+	MaxDuration    time.Duration
+}
+
+// Or returns the limits with each zero field taken from fallback
+func (l ExecutionLimits) Or(fallback ExecutionLimits) ExecutionLimits {
+	if l.MaxTransitions == 0 {
+		l.MaxTransitions = fallback.MaxTransitions
+	}
+	if l.MaxElements == 0 {
+		l.MaxElements = fallback.MaxElements
+	}
+	if l.MaxDuration == 0 {
+		l.MaxDuration = fallback.MaxDuration
+	}
+	return l
 }
 
 // NewFlow creates a new Flow
@@ -20,11 +55,16 @@ func NewFlow() *Flow {
 	}
 }
 
-// From initializes a Flow from a FlowDefinition
+// From initializes a Flow from a FlowDefinition. An invalid MaxDuration is ignored, as the registry loader rejects it.
 func (f *Flow) From(def definitionsource.FlowDefinition) *Flow {
 	f.ID = def.ID
+	f.Extends = def.Extends
 	f.DefaultStep = def.DefaultStep
 	f.ErrorStep = def.ErrorStep
+	f.Limits.MaxTransitions = def.MaxTransitions
+	f.Limits.MaxElements = def.MaxElements
+	f.Limits.MaxDuration, _ = def.MaxDurationValue()
+	f.Position = def.Position
 	return f
 }
 
@@ -43,6 +83,7 @@ type FlowStep struct {
 	ID            string
 	LogicElements []types.DslMetadata
 	FinalElements []types.DslMetadata
+	Position      types.SourcePosition
 }
 
 // NewFlowStep creates a new FlowStep
@@ -53,3 +94,12 @@ func NewFlowStep(id string) *FlowStep {
 		FinalElements: make([]types.DslMetadata, 0),
 	}
 }
+
+// Copy returns a copy of the step whose element lists can be changed independently of the original
+func (s *FlowStep) Copy() *FlowStep {
+	result := NewFlowStep(s.ID)
+	result.LogicElements = append(result.LogicElements, s.LogicElements...)
+	result.FinalElements = append(result.FinalElements, s.FinalElements...)
+	result.Position = s.Position
+	return result
+}
@@ -1,31 +1,246 @@
 package registry
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"sync"
 
 	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
 	"github.com/rsqn/go-cdsl/pkg/types"
 )
 
 // DslInitialisationHelper is responsible for resolving DSL instances
 type DslInitialisationHelper struct {
 	dslFactories map[string]func() dsl.Dsl
-	mu           sync.RWMutex
+	descriptors  map[string]dsl.DslDescriptor
+	// libraries holds the registered library namespaces and unqualified maps the name of each library DSL
+	// to its qualified name
+	libraries   map[string]bool
+	unqualified map[string]string
+	// services are injected into each DSL as it is resolved
+	services *ServiceContainer
+	mu       sync.RWMutex
 }
 
 // NewDslInitialisationHelper creates a new DslInitialisationHelper
 func NewDslInitialisationHelper() *DslInitialisationHelper {
 	return &DslInitialisationHelper{
 		dslFactories: make(map[string]func() dsl.Dsl),
+		descriptors:  make(map[string]dsl.DslDescriptor),
+		libraries:    make(map[string]bool),
+		unqualified:  make(map[string]string),
+		services:     NewServiceContainer(),
 	}
 }
 
-// RegisterDsl registers a DSL factory
-func (h *DslInitialisationHelper) RegisterDsl(name string, factory func() dsl.Dsl) {
+// Services returns the container whose services are injected into the DSLs this helper resolves
+func (h *DslInitialisationHelper) Services() *ServiceContainer {
+	return h.services
+}
+
+// Init initialises the services and checks that every registered DSL can be given the services it needs,
+// so a missing service is reported at startup rather than when a flow first uses the DSL
+func (h *DslInitialisationHelper) Init() error {
+	if err := h.services.Init(); err != nil {
+		return err
+	}
+	
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	
+	var errs []error
+	for _, name := range sortedKeys(h.dslFactories) {
+		if err := h.services.Inject(h.dslFactories[name]()); err != nil {
+			errs = append(errs, exceptions.NewCdslValidationError(fmt.Sprintf("DSL %s is missing a service", name), err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(append(errs, h.services.Close())...)
+	}
+	return nil
+}
+
+// Close closes the services initialised by Init
+func (h *DslInitialisationHelper) Close() error {
+	return h.services.Close()
+}
+
+// RegisterDsl registers a DSL factory, replacing any DSL registered under the same name. DSLs that describe
+// themselves, or declare a typed model, are added to the catalogue. A name with a namespace prefix, or the name
+// of a library DSL, is an error and registers nothing, as the DSL would replace or hide the library's.
+// The factory is called for each element when a flow is compiled, not for each execution, so the DSLs it
+// returns are shared by concurrent executions as described on dsl.Dsl.
+func (h *DslInitialisationHelper) RegisterDsl(name string, factory func() dsl.Dsl) error {
+	descriptor, described := dsl.Describe(factory())
+	
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	
+	if err := h.checkUnqualified(name); err != nil {
+		return err
+	}
 	h.dslFactories[name] = factory
+	delete(h.descriptors, name)
+	if described {
+		descriptor.Name = name
+		h.descriptors[name] = descriptor
+	}
+	return nil
+}
+
+// RegisterDescribedDsl registers a DSL factory with a descriptor for the catalogue, for DSLs that do not
+// describe themselves. Names are checked as they are by RegisterDsl.
+func (h *DslInitialisationHelper) RegisterDescribedDsl(name string, factory func() dsl.Dsl, descriptor dsl.DslDescriptor) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	
+	if err := h.checkUnqualified(name); err != nil {
+		return err
+	}
+	h.dslFactories[name] = factory
+	descriptor.Name = name
+	h.descriptors[name] = descriptor
+	return nil
+}
+
+// RegisterFunc registers a function as a DSL. Each element is bound into a new M, a struct or pointer to a struct
+// with `cdsl` tags, which also describes the DSL for the catalogue and the validator.
+func RegisterFunc[M any](h *DslInitialisationHelper, name string, fn func(ec *dsl.ExecContext, m M) (*types.CdslOutputEvent, error)) error {
+	return h.RegisterDsl(name, func() dsl.Dsl { return dsl.NewFuncDsl(fn) })
+}
+
+// checkUnqualified checks that a DSL may be registered outside a library under name, with the lock held
+func (h *DslInitialisationHelper) checkUnqualified(name string) error {
+	if strings.Contains(name, dsl.NamespaceSeparator) {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("DSL %s has a namespace prefix, register it in a DSL library", name), nil)
+	}
+	if qualified, exists := h.unqualified[name]; exists {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("DSL %s would hide library DSL %s", name, qualified), nil)
+	}
+	return nil
+}
+
+// RegisterLibrary registers every DSL of a library under its namespace. Registering a namespace twice, a
+// library that names the same DSL twice, or a library with a DSL already defined by another library or
+// registered with RegisterDsl, is an error and registers nothing.
+func (h *DslInitialisationHelper) RegisterLibrary(library *dsl.DslLibrary) error {
+	if library.Namespace == "" || strings.Contains(library.Namespace, dsl.NamespaceSeparator) {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("Invalid DSL library namespace %q", library.Namespace), nil)
+	}
+	
+	entries := library.Entries()
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if seen[entry.Name] {
+			return exceptions.NewCdslValidationError(fmt.Sprintf("DSL library %s defines %s more than once", library.Namespace, entry.Name), nil)
+		}
+		seen[entry.Name] = true
+	}
+	
+	descriptors := make([]*dsl.DslDescriptor, len(entries))
+	for i, entry := range entries {
+		descriptors[i] = entry.Descriptor
+		if descriptors[i] == nil {
+			if descriptor, described := dsl.Describe(entry.Factory()); described {
+				descriptors[i] = &descriptor
+			}
+		}
+	}
+	
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	
+	if h.libraries[library.Namespace] {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("DSL library %s is already registered", library.Namespace), nil)
+	}
+	var errs []error
+	for _, entry := range entries {
+		if existing, exists := h.unqualified[entry.Name]; exists {
+			errs = append(errs, exceptions.NewCdslValidationError(
+				fmt.Sprintf("DSL %s of library %s is already defined as %s", entry.Name, library.Namespace, existing),
+				nil,
+			))
+		} else if _, exists := h.dslFactories[entry.Name]; exists {
+			errs = append(errs, exceptions.NewCdslValidationError(
+				fmt.Sprintf("DSL %s of library %s is already registered outside a library", entry.Name, library.Namespace),
+				nil,
+			))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	h.libraries[library.Namespace] = true
+	
+	for i, entry := range entries {
+		qualified := library.Namespace + dsl.NamespaceSeparator + entry.Name
+		h.dslFactories[qualified] = entry.Factory
+		h.unqualified[entry.Name] = qualified
+		if descriptors[i] != nil {
+			descriptor := *descriptors[i]
+			descriptor.Name = entry.Name
+			descriptor.Namespace = library.Namespace
+			h.descriptors[qualified] = descriptor
+		}
+	}
+	return nil
+}
+
+// ResolveName returns the registered name of the DSL an element names. A name without a namespace prefix is
+// resolved to a DSL registered under that name, otherwise to the library DSL with that name.
+func (h *DslInitialisationHelper) ResolveName(name string) (string, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	
+	return h.resolveName(name)
+}
+
+// resolveName implements ResolveName with the lock held
+func (h *DslInitialisationHelper) resolveName(name string) (string, error) {
+	if _, exists := h.dslFactories[name]; exists {
+		return name, nil
+	}
+	
+	if namespace, local, qualified := strings.Cut(name, dsl.NamespaceSeparator); qualified {
+		if !h.libraries[namespace] {
+			return "", exceptions.NewCdslValidationError(fmt.Sprintf("DSL %s names unknown library %s", name, namespace), nil)
+		}
+		return "", exceptions.NewCdslValidationError(fmt.Sprintf("DSL library %s has no DSL %s", namespace, local), nil)
+	}
+	
+	qualified, exists := h.unqualified[name]
+	if !exists {
+		return "", exceptions.NewCdslValidationError(fmt.Sprintf("DSL %s is not registered", name), nil)
+	}
+	return qualified, nil
+}
+
+// Descriptor returns the catalogue descriptor of a registered DSL
+func (h *DslInitialisationHelper) Descriptor(name string) (dsl.DslDescriptor, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	
+	resolved, err := h.resolveName(name)
+	if err != nil {
+		return dsl.DslDescriptor{}, false
+	}
+	descriptor, exists := h.descriptors[resolved]
+	return descriptor, exists
+}
+
+// Catalogue returns the descriptors of every described DSL, ordered by qualified name
+func (h *DslInitialisationHelper) Catalogue() []dsl.DslDescriptor {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	
+	catalogue := make([]dsl.DslDescriptor, 0, len(h.descriptors))
+	for _, name := range sortedKeys(h.descriptors) {
+		catalogue = append(catalogue, h.descriptors[name])
+	}
+	return catalogue
 }
 
 // Resolve resolves a DSL instance from metadata
@@ -33,10 +248,15 @@ func (h *DslInitialisationHelper) Resolve(metadata types.DslMetadata) dsl.Dsl {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 	
-	factory, exists := h.dslFactories[metadata.Name]
-	if !exists {
+	name, err := h.resolveName(metadata.Name)
+	if err != nil {
 		return nil
 	}
 	
-	return factory()
+	instance := h.dslFactories[name]()
+	if err := h.services.Inject(instance); err != nil {
+		log.Printf("DSL RESOLVE: Failed to inject services into DSL %s: %v", name, err)
+		return nil
+	}
+	return instance
 }
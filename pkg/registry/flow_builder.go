@@ -0,0 +1,186 @@
+package registry
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/types"
+)
+
+// ElementBuilder describes a DSL element for a FlowBuilder
+type ElementBuilder struct {
+	def definitionsource.ElementDefinition
+	err error
+}
+
+// Elem describes a DSL element with attributes given as name, value pairs
+func Elem(name string, attrs ...string) *ElementBuilder {
+	e := &ElementBuilder{
+		def: definitionsource.ElementDefinition{
+			Name:       name,
+			Attributes: make(map[string]string, len(attrs)/2),
+			Position:   callerPosition(),
+		},
+	}
+
+	if len(attrs)%2 != 0 {
+		e.err = exceptions.NewCdslValidationErrorAt(e.def.Position, fmt.Sprintf("Element %s has an attribute without a value", name), nil)
+		return e
+	}
+	for i := 0; i < len(attrs); i += 2 {
+		e.def.Attributes[attrs[i]] = attrs[i+1]
+	}
+	return e
+}
+
+// Children adds nested child elements
+func (e *ElementBuilder) Children(children ...*ElementBuilder) *ElementBuilder {
+	for _, child := range children {
+		if child.err != nil && e.err == nil {
+			e.err = child.err
+		}
+		e.def.Elements = append(e.def.Elements, child.def)
+	}
+	return e
+}
+
+// Content sets the text content of the element
+func (e *ElementBuilder) Content(content string) *ElementBuilder {
+	e.def.Content = content
+	return e
+}
+
+// FlowBuilder assembles a flow in code, producing the same models RegistryLoader builds from documents
+type FlowBuilder struct {
+	flow     *model.Flow
+	lastStep *model.FlowStep
+	err      error
+}
+
+// NewFlowBuilder creates a new FlowBuilder for a flow with the given ID
+func NewFlowBuilder(id string) *FlowBuilder {
+	flow := model.NewFlow()
+	flow.ID = id
+	flow.Position = callerPosition()
+
+	return &FlowBuilder{
+		flow: flow,
+	}
+}
+
+// DefaultStep sets the step the flow starts at
+func (b *FlowBuilder) DefaultStep(stepID string) *FlowBuilder {
+	b.flow.DefaultStep = stepID
+	return b
+}
+
+// ErrorStep sets the step the flow routes to on error
+func (b *FlowBuilder) ErrorStep(stepID string) *FlowBuilder {
+	b.flow.ErrorStep = stepID
+	return b
+}
+
+// Limits sets the limits of one execution of the flow, overriding the executor's for each non-zero field
+func (b *FlowBuilder) Limits(limits model.ExecutionLimits) *FlowBuilder {
+	if limits.MaxTransitions < 0 || limits.MaxElements < 0 || limits.MaxDuration < 0 {
+		b.fail(exceptions.NewCdslValidationErrorAt(callerPosition(), fmt.Sprintf("Limits of flow %s must not be negative", b.flow.ID), nil))
+	}
+	b.flow.Limits = limits
+	return b
+}
+
+// Step adds a step with the given logic elements
+func (b *FlowBuilder) Step(id string, elems ...*ElementBuilder) *FlowBuilder {
+	step := model.NewFlowStep(id)
+	step.Position = callerPosition()
+
+	if b.flow.FetchStep(id) != nil {
+		b.fail(exceptions.NewCdslValidationErrorAt(step.Position, fmt.Sprintf("Duplicate step id %s in flow %s", id, b.flow.ID), nil))
+	}
+
+	step.LogicElements = b.metadata(elems)
+	b.flow.PutStep(id, step)
+	b.lastStep = step
+	return b
+}
+
+// Finally sets the finally elements of the most recently added step
+func (b *FlowBuilder) Finally(elems ...*ElementBuilder) *FlowBuilder {
+	if b.lastStep == nil {
+		b.fail(exceptions.NewCdslValidationErrorAt(callerPosition(), fmt.Sprintf("Finally called before any step in flow %s", b.flow.ID), nil))
+		return b
+	}
+
+	b.lastStep.FinalElements = b.metadata(elems)
+	return b
+}
+
+// Build returns the flow, or the first error found while building it
+func (b *FlowBuilder) Build() (*model.Flow, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	checksum, err := FlowChecksum(b.flow)
+	if err != nil {
+		return nil, err
+	}
+	b.flow.Checksum = checksum
+	return b.flow, nil
+}
+
+// BuildAndRegister builds the flow, validates it with RegistryValidator and registers it, returning the current
+// version of the flow, which is the version already registered if it has the same checksum
+func (b *FlowBuilder) BuildAndRegister(flowRegistry FlowRegistry, dslInitHelper *DslInitialisationHelper) (*model.Flow, error) {
+	flow, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := NewRegistryValidator(flowRegistry, dslInitHelper).ValidateFlow(flow); err != nil {
+		return nil, err
+	}
+	if err := flowRegistry.RegisterFlow(flow); err != nil {
+		return nil, err
+	}
+	return flowRegistry.GetFlow(flow.ID)
+}
+
+// metadata converts element builders into DslMetadata with MapModels
+func (b *FlowBuilder) metadata(elems []*ElementBuilder) []types.DslMetadata {
+	result := make([]types.DslMetadata, 0, len(elems))
+	for _, elem := range elems {
+		if elem.err != nil {
+			b.fail(elem.err)
+		}
+		result = append(result, types.DslMetadata{
+			Name:     elem.def.Name,
+			Model:    buildMapModel(elem.def),
+			Position: elem.def.Position,
+		})
+	}
+	return result
+}
+
+// fail records the first error found while building
+func (b *FlowBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// callerPosition returns the position in Go source of the code calling the builder, so validation
+// errors for built flows point at the code that built them
+func callerPosition() types.SourcePosition {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return types.SourcePosition{}
+	}
+	return types.SourcePosition{
+		File: file,
+		Line: line,
+	}
+}
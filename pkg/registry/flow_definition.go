@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/types"
+)
+
+// FlowDefinitionOf converts a flow back into a definition so it can be written out. Steps are ordered
+// by ID since flows do not record their document order.
+func FlowDefinitionOf(flow *model.Flow) (*definitionsource.FlowDefinition, error) {
+	result := &definitionsource.FlowDefinition{
+		ID:             flow.ID,
+		DefaultStep:    flow.DefaultStep,
+		ErrorStep:      flow.ErrorStep,
+		Steps:          make(map[string]*definitionsource.StepDefinition, len(flow.Steps)),
+		Position:       flow.Position,
+		MaxTransitions: flow.Limits.MaxTransitions,
+		MaxElements:    flow.Limits.MaxElements,
+	}
+	if flow.Limits.MaxDuration != 0 {
+		result.MaxDuration = flow.Limits.MaxDuration.String()
+	}
+
+	stepIDs := make([]string, 0, len(flow.Steps))
+	for stepID := range flow.Steps {
+		stepIDs = append(stepIDs, stepID)
+	}
+	sort.Strings(stepIDs)
+
+	for _, stepID := range stepIDs {
+		step := flow.Steps[stepID]
+		stepDef := definitionsource.StepDefinition{
+			ID:       stepID,
+			Position: step.Position,
+		}
+
+		var err error
+		if stepDef.Elements, err = elementDefinitionsOf(step.LogicElements); err != nil {
+			return nil, err
+		}
+		if stepDef.Finally, err = elementDefinitionsOf(step.FinalElements); err != nil {
+			return nil, err
+		}
+		result.StepsList = append(result.StepsList, stepDef)
+	}
+
+	for i := range result.StepsList {
+		step := &result.StepsList[i]
+		result.Steps[step.ID] = step
+	}
+
+	return result, nil
+}
+
+// elementDefinitionsOf converts the elements of a step back into definitions
+func elementDefinitionsOf(elems []types.DslMetadata) ([]definitionsource.ElementDefinition, error) {
+	var result []definitionsource.ElementDefinition
+	for _, meta := range elems {
+		mapModel, ok := meta.Model.(*dsl.MapModel)
+		if !ok {
+			return nil, exceptions.NewCdslErrorAt(meta.Position, fmt.Sprintf("Cannot convert model %T of element %s to a definition", meta.Model, meta.Name), nil)
+		}
+		elemDef := elementDefinitionOf(mapModel)
+		elemDef.Name = meta.Name
+		elemDef.Position = meta.Position
+		result = append(result, elemDef)
+	}
+	return result, nil
+}
+
+// elementDefinitionOf converts a MapModel into an element definition, the reverse of buildMapModel
+func elementDefinitionOf(m *dsl.MapModel) definitionsource.ElementDefinition {
+	result := definitionsource.ElementDefinition{
+		Name:       m.Name,
+		Attributes: make(map[string]string, len(m.Properties)),
+		Content:    m.Content,
+	}
+
+	for k, v := range m.Properties {
+		result.Attributes[k] = fmt.Sprint(v)
+	}
+	for _, child := range m.Children {
+		result.Elements = append(result.Elements, elementDefinitionOf(child))
+	}
+
+	return result
+}
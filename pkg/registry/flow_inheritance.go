@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/model"
+)
+
+// flowResolver builds flows from their definitions, flattening flows that extend another flow.
+// A parent is looked up first amongst the definitions being loaded and then in the registry.
+type flowResolver struct {
+	loader    *RegistryLoader
+	defs      map[string]*definitionsource.FlowDefinition
+	session   *buildSession
+	built     map[string]*model.Flow
+	resolving []string
+}
+
+// newFlowResolver creates a new flowResolver
+func newFlowResolver(loader *RegistryLoader, defs map[string]*definitionsource.FlowDefinition, session *buildSession) *flowResolver {
+	return &flowResolver{
+		loader:  loader,
+		defs:    defs,
+		session: session,
+		built:   make(map[string]*model.Flow),
+	}
+}
+
+// resolve returns the flattened flow for a definition being loaded
+func (r *flowResolver) resolve(flowID string) (*model.Flow, error) {
+	if flow, exists := r.built[flowID]; exists {
+		return flow, nil
+	}
+
+	for i, id := range r.resolving {
+		if id == flowID {
+			chain := append(append([]string{}, r.resolving[i:]...), flowID)
+			return nil, exceptions.NewCdslValidationErrorAt(
+				r.defs[flowID].Position,
+				fmt.Sprintf("Flow inheritance cycle: %s", strings.Join(chain, " extends ")),
+				nil,
+			)
+		}
+	}
+
+	r.resolving = append(r.resolving, flowID)
+	defer func() { r.resolving = r.resolving[:len(r.resolving)-1] }()
+
+	flowDef := r.defs[flowID]
+	flow, err := r.loader.buildFlow(flowDef, r.session)
+	if err != nil {
+		return nil, err
+	}
+
+	if flowDef.Extends != "" {
+		parent, err := r.parent(flowDef)
+		if err != nil {
+			return nil, err
+		}
+		if flow, err = inherit(parent, flow, flowDef); err != nil {
+			return nil, err
+		}
+	}
+
+	r.built[flowID] = flow
+	return flow, nil
+}
+
+// parent finds the flow a definition extends
+func (r *flowResolver) parent(flowDef *definitionsource.FlowDefinition) (*model.Flow, error) {
+	if _, pending := r.defs[flowDef.Extends]; pending {
+		return r.resolve(flowDef.Extends)
+	}
+
+	parent, err := r.loader.flowRegistry.GetFlow(flowDef.Extends)
+	var notFound *exceptions.CdslFlowNotFoundError
+	if errors.As(err, &notFound) {
+		return nil, exceptions.NewCdslValidationErrorAt(
+			flowDef.Position,
+			fmt.Sprintf("Flow %s extends unknown flow %s", flowDef.ID, flowDef.Extends),
+			nil,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// inherit flattens a child flow onto its parent. Steps the child marks as overrides must exist in
+// the parent, and any other step must not, so a typo cannot silently add a step instead of replacing one.
+func inherit(parent *model.Flow, child *model.Flow, childDef *definitionsource.FlowDefinition) (*model.Flow, error) {
+	result := model.NewFlow()
+	result.ID = child.ID
+	result.Extends = child.Extends
+	result.Position = child.Position
+
+	result.DefaultStep = child.DefaultStep
+	if result.DefaultStep == "" {
+		result.DefaultStep = parent.DefaultStep
+	}
+	result.ErrorStep = child.ErrorStep
+	if result.ErrorStep == "" {
+		result.ErrorStep = parent.ErrorStep
+	}
+	result.Limits = child.Limits.Or(parent.Limits)
+
+	for stepID, step := range parent.Steps {
+		result.PutStep(stepID, step.Copy())
+	}
+
+	for _, stepID := range sortedKeys(childDef.Steps) {
+		stepDef := childDef.Steps[stepID]
+		_, inherited := parent.Steps[stepID]
+
+		if stepDef.Override && !inherited {
+			return nil, exceptions.NewCdslValidationErrorAt(
+				stepDef.Position,
+				fmt.Sprintf("Step %s in flow %s overrides a step that does not exist in parent flow %s", stepID, child.ID, parent.ID),
+				nil,
+			)
+		}
+		if !stepDef.Override && inherited {
+			return nil, exceptions.NewCdslValidationErrorAt(
+				stepDef.Position,
+				fmt.Sprintf("Step %s in flow %s redefines a step of parent flow %s without override=\"true\"", stepID, child.ID, parent.ID),
+				nil,
+			)
+		}
+
+		result.PutStep(stepID, child.Steps[stepID])
+	}
+
+	return result, nil
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,47 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/model"
+)
+
+// FlowChecksum returns the hex encoded SHA-256 of the canonical JSON form of a flow. It covers the flow
+// as executed, after inheritance, fragments and properties are resolved, and ignores source positions,
+// so the same flow has the same checksum whichever format or file it was loaded from.
+func FlowChecksum(flow *model.Flow) (string, error) {
+	flowDef, err := FlowDefinitionOf(flow)
+	if err != nil {
+		return "", err
+	}
+
+	doc := &definitionsource.DocumentDefinition{
+		Flows: map[string]*definitionsource.FlowDefinition{flowDef.ID: flowDef},
+	}
+	var buf bytes.Buffer
+	if err := (definitionsource.JsonDocumentWriter{}).WriteDocument(&buf, doc); err != nil {
+		return "", err
+	}
+	return definitionsource.Checksum(buf.Bytes()), nil
+}
+
+// verifySignature checks that a document carries a detached ed25519 signature made by one of keys
+func verifySignature(doc *definitionsource.DocumentDefinition, keys []ed25519.PublicKey) error {
+	if doc.Raw == nil {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("Document %s has no content to verify", doc.Source), nil)
+	}
+	if len(doc.Signature) == 0 {
+		return exceptions.NewCdslValidationError(fmt.Sprintf("Document %s is not signed", doc.Source), nil)
+	}
+
+	for _, key := range keys {
+		if ed25519.Verify(key, doc.Raw, doc.Signature) {
+			return nil
+		}
+	}
+	return exceptions.NewCdslValidationError(fmt.Sprintf("Signature of document %s does not verify", doc.Source), nil)
+}
@@ -1,8 +1,10 @@
 package registry
 
 import (
+	"fmt"
 	"sync"
 
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
 	"github.com/rsqn/go-cdsl/pkg/model"
 )
 
@@ -11,36 +13,341 @@ type FlowRegistry interface {
 	// RegisterFlow registers a flow
 	RegisterFlow(flow *model.Flow) error
 	
-	// GetFlow retrieves a flow by ID
+	// GetFlow retrieves a flow by ID, returning a CdslFlowNotFoundError if it is not registered
 	GetFlow(id string) (*model.Flow, error)
+	
+	// ListFlows describes every registered flow, ordered by ID
+	ListFlows() []FlowInfo
+	
+	// FlowInfo describes a registered flow, returning a CdslFlowNotFoundError if it is not registered
+	FlowInfo(id string) (FlowInfo, error)
+	
+	// Unregister removes a flow so it can no longer be started
+	Unregister(id string) error
+	
+	// Subscribe registers a listener notified of every change to the registry and returns a function
+	// that removes it
+	Subscribe(listener func(FlowRegistryEvent)) func()
 }
 
-// InMemoryFlowRegistry is an in-memory implementation of FlowRegistry
+// FlowInfo describes a registered flow for listings and admin tooling
+type FlowInfo struct {
+	ID      string
+	Extends string
+	// Source is the file the flow was defined in, empty for flows built in code
+	Source    string
+	Version   int
+	StepCount int
+	// Checksum identifies the content of the flow and DocumentChecksum the document it was defined in
+	Checksum         string
+	DocumentChecksum string
+	// Versions lists the versions of the flow that have not been retired, oldest first
+	Versions []int
+}
+
+// newFlowInfo describes the current version of a flow and the versions kept beside it
+func newFlowInfo(flow *model.Flow, versions []*model.Flow) FlowInfo {
+	info := FlowInfo{
+		ID:               flow.ID,
+		Extends:          flow.Extends,
+		Source:           flow.Source,
+		Version:          flow.Version,
+		StepCount:        len(flow.Steps),
+		Checksum:         flow.Checksum,
+		DocumentChecksum: flow.DocumentChecksum,
+	}
+	for _, version := range versions {
+		info.Versions = append(info.Versions, version.Version)
+	}
+	return info
+}
+
+// FlowRegistryEventType identifies a change to a registry
+type FlowRegistryEventType string
+
+const (
+	// FlowRegistered is published when a new version of a flow becomes current
+	FlowRegistered FlowRegistryEventType = "Registered"
+	// FlowUnregistered is published when a flow is removed and can no longer be started
+	FlowUnregistered FlowRegistryEventType = "Unregistered"
+	// FlowVersionRetired is published when an earlier version of a flow is retired
+	FlowVersionRetired FlowRegistryEventType = "VersionRetired"
+)
+
+// FlowRegistryEvent describes a change to a registry
+type FlowRegistryEvent struct {
+	Type    FlowRegistryEventType
+	FlowID  string
+	Version int
+}
+
+// registrySubscription is a listener registered with Subscribe
+type registrySubscription struct {
+	id       int
+	listener func(FlowRegistryEvent)
+}
+
+// VersionUsage reports whether contexts still reference a version of a flow
+type VersionUsage interface {
+	// CountLiveContexts returns the number of contexts that have not ended and are pinned to a version of a flow
+	CountLiveContexts(flowID string, version int) (int, error)
+}
+
+// InMemoryFlowRegistry is an in-memory implementation of FlowRegistry. It keeps every version
+// registered for a flow ID so contexts started on an older version can resume on it.
 type InMemoryFlowRegistry struct {
+	// flows holds the current version of each flow
 	flows map[string]*model.Flow
-	mu    sync.RWMutex
+	// versions holds every version of each flow that has not been retired, oldest first
+	versions    map[string][]*model.Flow
+	lastVersion map[string]int
+	// subscriptions are notified of changes in the order they subscribed
+	subscriptions    []registrySubscription
+	nextSubscription int
+	mu               sync.RWMutex
 }
 
 // NewInMemoryFlowRegistry creates a new InMemoryFlowRegistry
 func NewInMemoryFlowRegistry() *InMemoryFlowRegistry {
 	return &InMemoryFlowRegistry{
-		flows: make(map[string]*model.Flow),
+		flows:       make(map[string]*model.Flow),
+		versions:    make(map[string][]*model.Flow),
+		lastVersion: make(map[string]int),
 	}
 }
 
-// RegisterFlow implements FlowRegistry
+// RegisterFlow implements FlowRegistry. The flow becomes the current version of its ID, numbered one
+// higher than the last; registering a flow with the same checksum as the current version keeps the current
+// version and leaves the flow unchanged.
 func (r *InMemoryFlowRegistry) RegisterFlow(flow *model.Flow) error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	registered := r.register(flow)
+	r.mu.Unlock()
 	
-	r.flows[flow.ID] = flow
+	if registered {
+		r.publish(FlowRegistryEvent{Type: FlowRegistered, FlowID: flow.ID, Version: flow.Version})
+	}
 	return nil
 }
 
-// GetFlow implements FlowRegistry
+// register makes a flow the current version, the caller must hold r.mu. It returns false if the flow
+// has the same checksum as the current version, which is kept.
+func (r *InMemoryFlowRegistry) register(flow *model.Flow) bool {
+	if current, exists := r.flows[flow.ID]; exists && current.Checksum != "" && current.Checksum == flow.Checksum {
+		return false
+	}
+	
+	r.lastVersion[flow.ID]++
+	flow.Version = r.lastVersion[flow.ID]
+	r.flows[flow.ID] = flow
+	r.versions[flow.ID] = append(r.versions[flow.ID], flow)
+	return true
+}
+
+// GetFlow implements FlowRegistry, returning the current version of a flow
 func (r *InMemoryFlowRegistry) GetFlow(id string) (*model.Flow, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	
-	return r.flows[id], nil
+	flow, exists := r.flows[id]
+	if !exists {
+		return nil, exceptions.NewCdslFlowNotFoundError(id, 0)
+	}
+	return flow, nil
+}
+
+// GetFlowVersion retrieves a specific version of a flow, returning a CdslFlowNotFoundError if it was never
+// registered or has been retired
+func (r *InMemoryFlowRegistry) GetFlowVersion(id string, version int) (*model.Flow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	for _, flow := range r.versions[id] {
+		if flow.Version == version {
+			return flow, nil
+		}
+	}
+	return nil, exceptions.NewCdslFlowNotFoundError(id, version)
+}
+
+// ListFlows implements FlowRegistry
+func (r *InMemoryFlowRegistry) ListFlows() []FlowInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	infos := make([]FlowInfo, 0, len(r.flows))
+	for _, id := range sortedFlowIDs(r.flows) {
+		infos = append(infos, newFlowInfo(r.flows[id], r.versions[id]))
+	}
+	return infos
+}
+
+// FlowInfo implements FlowRegistry
+func (r *InMemoryFlowRegistry) FlowInfo(id string) (FlowInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	flow, exists := r.flows[id]
+	if !exists {
+		return FlowInfo{}, exceptions.NewCdslFlowNotFoundError(id, 0)
+	}
+	return newFlowInfo(flow, r.versions[id]), nil
+}
+
+// Unregister implements FlowRegistry. The versions of the flow are kept until retired so contexts
+// already running on them can finish, and registering the ID again continues its version numbers.
+func (r *InMemoryFlowRegistry) Unregister(id string) error {
+	r.mu.Lock()
+	flow, exists := r.flows[id]
+	delete(r.flows, id)
+	r.mu.Unlock()
+	
+	if !exists {
+		return exceptions.NewCdslFlowNotFoundError(id, 0)
+	}
+	r.publish(FlowRegistryEvent{Type: FlowUnregistered, FlowID: id, Version: flow.Version})
+	return nil
+}
+
+// Subscribe implements FlowRegistry. Listeners are called after the change has been made, outside the
+// registry lock, so they may query the registry.
+func (r *InMemoryFlowRegistry) Subscribe(listener func(FlowRegistryEvent)) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	r.nextSubscription++
+	id := r.nextSubscription
+	r.subscriptions = append(r.subscriptions, registrySubscription{id: id, listener: listener})
+	
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		
+		for i, subscription := range r.subscriptions {
+			if subscription.id == id {
+				r.subscriptions = append(r.subscriptions[:i:i], r.subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// publish notifies every subscriber of events, the caller must not hold r.mu
+func (r *InMemoryFlowRegistry) publish(events ...FlowRegistryEvent) {
+	r.mu.RLock()
+	subscriptions := append([]registrySubscription{}, r.subscriptions...)
+	r.mu.RUnlock()
+	
+	for _, event := range events {
+		for _, subscription := range subscriptions {
+			subscription.listener(event)
+		}
+	}
+}
+
+// ListVersions returns the versions of a flow that have not been retired, oldest first
+func (r *InMemoryFlowRegistry) ListVersions(id string) []*model.Flow {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	return append([]*model.Flow{}, r.versions[id]...)
+}
+
+// RetireVersion removes a version of a flow once no live context is pinned to it. The current version cannot be retired.
+// Live contexts are counted with the registry locked, so the version cannot become current while they are
+// counted, and usage must not call the registry.
+func (r *InMemoryFlowRegistry) RetireVersion(id string, version int, usage VersionUsage) error {
+	if err := r.retire(id, version, usage); err != nil {
+		return err
+	}
+	r.publish(FlowRegistryEvent{Type: FlowVersionRetired, FlowID: id, Version: version})
+	return nil
+}
+
+// retire removes a version of a flow that is not current and has no live contexts
+func (r *InMemoryFlowRegistry) retire(id string, version int, usage VersionUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	if current, exists := r.flows[id]; exists && current.Version == version {
+		return exceptions.NewCdslError(fmt.Sprintf("Flow %s version %d is the current version", id, version), nil)
+	}
+	
+	live, err := usage.CountLiveContexts(id, version)
+	if err != nil {
+		return err
+	}
+	if live > 0 {
+		return exceptions.NewCdslError(fmt.Sprintf("Flow %s version %d is used by %d live contexts", id, version, live), nil)
+	}
+	
+	versions := r.versions[id]
+	for i, flow := range versions {
+		if flow.Version == version {
+			r.versions[id] = append(versions[:i:i], versions[i+1:]...)
+			return nil
+		}
+	}
+	return exceptions.NewCdslError(fmt.Sprintf("Flow %s has no version %d", id, version), nil)
+}
+
+// RetireUnusedVersions retires every version of a flow other than the current one that no live context
+// is pinned to, returning the versions retired
+func (r *InMemoryFlowRegistry) RetireUnusedVersions(id string, usage VersionUsage) ([]int, error) {
+	var retired []int
+	for _, flow := range r.ListVersions(id) {
+		if current, _ := r.GetFlow(id); current != nil && current.Version == flow.Version {
+			continue
+		}
+		
+		live, err := usage.CountLiveContexts(id, flow.Version)
+		if err != nil {
+			return retired, err
+		}
+		if live > 0 {
+			continue
+		}
+		if err := r.RetireVersion(id, flow.Version, usage); err != nil {
+			return retired, err
+		}
+		retired = append(retired, flow.Version)
+	}
+	return retired, nil
+}
+
+// ReplaceFlows atomically replaces the current flows with the given flows. Changed flows become new
+// versions and flows left out are no longer current, though their versions remain until retired.
+func (r *InMemoryFlowRegistry) ReplaceFlows(flows []*model.Flow) {
+	r.mu.Lock()
+	var events []FlowRegistryEvent
+	previous := r.flows
+	r.flows = make(map[string]*model.Flow, len(flows))
+	for _, flow := range flows {
+		if current, exists := previous[flow.ID]; exists {
+			r.flows[flow.ID] = current
+		}
+		if r.register(flow) {
+			events = append(events, FlowRegistryEvent{Type: FlowRegistered, FlowID: flow.ID, Version: flow.Version})
+		}
+	}
+	for _, id := range sortedFlowIDs(previous) {
+		if _, kept := r.flows[id]; !kept {
+			events = append(events, FlowRegistryEvent{Type: FlowUnregistered, FlowID: id, Version: previous[id].Version})
+		}
+	}
+	r.mu.Unlock()
+	
+	r.publish(events...)
+}
+
+// snapshot returns a copy of the current flows keyed by ID
+func (r *InMemoryFlowRegistry) snapshot() map[string]*model.Flow {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	result := make(map[string]*model.Flow, len(r.flows))
+	for id, flow := range r.flows {
+		result[id] = flow
+	}
+	return result
 }
@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"crypto/ed25519"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/model"
+)
+
+// ReloadEvent describes the outcome of reloading flow definitions
+type ReloadEvent struct {
+	Added   []string
+	Changed []string
+	Removed []string
+	// Err is set when the new definitions were rejected and the previous flows kept
+	Err error
+}
+
+// HasChanges reports whether the reload altered the registry
+func (e ReloadEvent) HasChanges() bool {
+	return len(e.Added) > 0 || len(e.Changed) > 0 || len(e.Removed) > 0
+}
+
+// FlowReloader re-reads a definition source and swaps the resulting flows into a registry,
+// but only once every flow has passed validation. The reloader owns the contents of the registry.
+type FlowReloader struct {
+	source           definitionsource.DefinitionSource
+	flowRegistry     *InMemoryFlowRegistry
+	dslInitHelper    *DslInitialisationHelper
+	propertyResolver PropertyResolver
+	signatureKeys    []ed25519.PublicKey
+	listeners        []func(ReloadEvent)
+	stop             chan struct{}
+	done             chan struct{}
+	mu               sync.Mutex
+}
+
+// NewFlowReloader creates a new FlowReloader
+func NewFlowReloader(source definitionsource.DefinitionSource, flowRegistry *InMemoryFlowRegistry, dslInitHelper *DslInitialisationHelper) *FlowReloader {
+	return &FlowReloader{
+		source:        source,
+		flowRegistry:  flowRegistry,
+		dslInitHelper: dslInitHelper,
+	}
+}
+
+// SetPropertyResolver sets the resolver for property placeholders in reloaded definitions
+func (r *FlowReloader) SetPropertyResolver(resolver PropertyResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.propertyResolver = resolver
+}
+
+// RequireSignatures makes reloads reject documents that are not signed by one of keys
+func (r *FlowReloader) RequireSignatures(keys ...ed25519.PublicKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.signatureKeys = keys
+}
+
+// OnReload registers a listener notified after every reload that changed the registry or failed
+func (r *FlowReloader) OnReload(listener func(ReloadEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.listeners = append(r.listeners, listener)
+}
+
+// Reload reads the source once, validates the flows and swaps them in if anything changed
+func (r *FlowReloader) Reload() ReloadEvent {
+	r.mu.Lock()
+	event := r.reload()
+	listeners := append([]func(ReloadEvent){}, r.listeners...)
+	r.mu.Unlock()
+
+	if event.Err != nil {
+		log.Printf("RELOAD FAILED: keeping previous flows: %v", event.Err)
+	} else if event.HasChanges() {
+		log.Printf("RELOAD: Added %v, Changed %v, Removed %v", event.Added, event.Changed, event.Removed)
+	}
+
+	if event.Err != nil || event.HasChanges() {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+	return event
+}
+
+// reload performs a reload, the caller must hold r.mu
+func (r *FlowReloader) reload() ReloadEvent {
+	docs, err := r.source.LoadAll()
+	if err != nil {
+		return ReloadEvent{Err: err}
+	}
+
+	staging := NewInMemoryFlowRegistry()
+	loader := NewRegistryLoader(staging, r.dslInitHelper)
+	loader.SetPropertyResolver(r.propertyResolver)
+	loader.RequireSignatures(r.signatureKeys...)
+	if err := loader.LoadDocuments(docs); err != nil {
+		return ReloadEvent{Err: err}
+	}
+
+	validator := NewRegistryValidator(staging, r.dslInitHelper)
+	next := staging.snapshot()
+	for _, id := range sortedFlowIDs(next) {
+		if err := validator.ValidateFlow(next[id]); err != nil {
+			return ReloadEvent{Err: exceptions.NewCdslValidationError("Reloaded flow "+id+" is invalid", err)}
+		}
+	}
+
+	event := diffFlows(r.flowRegistry.snapshot(), next)
+	if event.HasChanges() {
+		flows := make([]*model.Flow, 0, len(next))
+		for _, id := range sortedFlowIDs(next) {
+			flows = append(flows, next[id])
+		}
+		r.flowRegistry.ReplaceFlows(flows)
+	}
+	return event
+}
+
+// Start reloads immediately and then polls the source every interval until Stop is called
+func (r *FlowReloader) Start(interval time.Duration) {
+	r.mu.Lock()
+	if r.stop != nil {
+		r.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	r.stop = stop
+	r.done = done
+	r.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		r.Reload()
+		for {
+			select {
+			case <-ticker.C:
+				r.Reload()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for any reload in progress to finish
+func (r *FlowReloader) Stop() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// diffFlows compares two sets of flows keyed by ID, flows with the same checksum being unchanged
+func diffFlows(previous map[string]*model.Flow, next map[string]*model.Flow) ReloadEvent {
+	event := ReloadEvent{}
+
+	for _, id := range sortedFlowIDs(next) {
+		old, exists := previous[id]
+		if !exists {
+			event.Added = append(event.Added, id)
+		} else if old.Checksum != next[id].Checksum {
+			event.Changed = append(event.Changed, id)
+		}
+	}
+	for _, id := range sortedFlowIDs(previous) {
+		if _, exists := next[id]; !exists {
+			event.Removed = append(event.Removed, id)
+		}
+	}
+
+	return event
+}
+
+// sortedFlowIDs returns the keys of a flow map in order
+func sortedFlowIDs(flows map[string]*model.Flow) []string {
+	ids := make([]string, 0, len(flows))
+	for id := range flows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,127 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+)
+
+// useFragmentElement is the element name that steps use to include a fragment
+const useFragmentElement = "useFragment"
+
+// fragmentExpander replaces useFragment elements with the elements of the fragment they reference
+type fragmentExpander struct {
+	fragments map[string]*definitionsource.FragmentDefinition
+	expanding []string
+}
+
+// newFragmentExpander collects the fragments of a batch of documents. A fragment ID may only be defined by one source.
+func newFragmentExpander(docs []*definitionsource.DocumentDefinition) (*fragmentExpander, error) {
+	e := &fragmentExpander{
+		fragments: make(map[string]*definitionsource.FragmentDefinition),
+	}
+
+	sources := make(map[string]*definitionsource.DocumentDefinition)
+	for _, d := range docs {
+		for _, fragmentID := range sortedKeys(d.Fragments) {
+			fragment := d.Fragments[fragmentID]
+			if existing, exists := sources[fragmentID]; exists && !sameDocument(existing, d) {
+				return nil, exceptions.NewCdslValidationErrorAt(
+					fragment.Position,
+					fmt.Sprintf("Fragment %s is already defined in %s", fragmentID, documentName(existing)),
+					nil,
+				)
+			}
+			sources[fragmentID] = d
+			e.fragments[fragmentID] = fragment
+		}
+	}
+
+	return e, nil
+}
+
+// expand returns elems with every useFragment element replaced by the fragment it references.
+// The remaining attributes of useFragment are parameters substituted for ${name} in the fragment;
+// placeholders without a matching parameter are left as they are for property resolution, so a parameter
+// hides a property of the same name within the fragment.
+func (e *fragmentExpander) expand(elems []definitionsource.ElementDefinition) ([]definitionsource.ElementDefinition, error) {
+	var result []definitionsource.ElementDefinition
+
+	for _, elem := range elems {
+		if elem.Name != useFragmentElement {
+			result = append(result, elem)
+			continue
+		}
+
+		ref := elem.Attributes["ref"]
+		if ref == "" {
+			return nil, exceptions.NewCdslValidationErrorAt(elem.Position, "<useFragment> must have a ref attribute", nil)
+		}
+		fragment, exists := e.fragments[ref]
+		if !exists {
+			return nil, exceptions.NewCdslValidationErrorAt(elem.Position, fmt.Sprintf("Unknown fragment %s", ref), nil)
+		}
+		for i, id := range e.expanding {
+			if id == ref {
+				chain := append(append([]string{}, e.expanding[i:]...), ref)
+				return nil, exceptions.NewCdslValidationErrorAt(
+					elem.Position,
+					fmt.Sprintf("Fragment cycle: %s", strings.Join(chain, " -> ")),
+					nil,
+				)
+			}
+		}
+
+		params := make(map[string]string)
+		for k, v := range elem.Attributes {
+			if k != "ref" {
+				params[k] = v
+			}
+		}
+
+		substituted := substituteParameters(fragment.Elements, params)
+
+		e.expanding = append(e.expanding, ref)
+		expanded, err := e.expand(substituted)
+		e.expanding = e.expanding[:len(e.expanding)-1]
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, expanded...)
+	}
+
+	return result, nil
+}
+
+// substituteParameters returns a deep copy of elems with parameters substituted into attributes and content
+func substituteParameters(elems []definitionsource.ElementDefinition, params map[string]string) []definitionsource.ElementDefinition {
+	if elems == nil {
+		return nil
+	}
+
+	result := make([]definitionsource.ElementDefinition, len(elems))
+	for i, elem := range elems {
+		result[i] = elem
+		result[i].Attributes = make(map[string]string, len(elem.Attributes))
+		for _, k := range sortedKeys(elem.Attributes) {
+			result[i].Attributes[k] = substituteString(elem.Attributes[k], params)
+		}
+		result[i].Content = substituteString(elem.Content, params)
+		result[i].Elements = substituteParameters(elem.Elements, params)
+	}
+	return result
+}
+
+// substituteString replaces the placeholders in s that have a parameter, leaving the rest, and escapes, for
+// property resolution
+func substituteString(s string, params map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		if value, exists := params[name]; exists {
+			return value
+		}
+		return placeholder
+	})
+}
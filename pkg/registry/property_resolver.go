@@ -0,0 +1,199 @@
+package registry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/types"
+)
+
+// placeholderPattern matches ${name} and ${name:default} placeholders in attribute values and content, and the
+// escape $${, which stands for a literal ${ and leaves the placeholder after it unresolved. An escape matches
+// with an empty name.
+var placeholderPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_.-]*)(?::([^}]*))?\}`)
+
+// placeholderEscape is the escape for a literal ${
+const placeholderEscape = "$${"
+
+// PropertyResolver supplies the values of ${name} placeholders in flow definitions
+type PropertyResolver interface {
+	// Lookup returns the value of a property and whether it is defined
+	Lookup(name string) (string, bool)
+}
+
+// MapPropertyResolver resolves properties from an in-memory map
+type MapPropertyResolver struct {
+	properties map[string]string
+}
+
+// NewMapPropertyResolver creates a new MapPropertyResolver holding a copy of properties
+func NewMapPropertyResolver(properties map[string]string) *MapPropertyResolver {
+	r := &MapPropertyResolver{
+		properties: make(map[string]string, len(properties)),
+	}
+	for k, v := range properties {
+		r.properties[k] = v
+	}
+	return r
+}
+
+// Lookup implements PropertyResolver
+func (r *MapPropertyResolver) Lookup(name string) (string, bool) {
+	value, exists := r.properties[name]
+	return value, exists
+}
+
+// NewPropertiesFileResolver creates a MapPropertyResolver from a properties file in fsys.
+// Each line holds key=value or key: value; blank lines and lines starting with # or ! are ignored.
+func NewPropertiesFileResolver(fsys fs.FS, name string) (*MapPropertyResolver, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	properties, err := readProperties(name, file)
+	if err != nil {
+		return nil, err
+	}
+	return &MapPropertyResolver{properties: properties}, nil
+}
+
+// readProperties parses the contents of a properties file
+func readProperties(name string, reader io.Reader) (map[string]string, error) {
+	properties := make(map[string]string)
+
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		separator := strings.IndexAny(line, "=:")
+		if separator < 0 {
+			return nil, exceptions.NewCdslParseError(name, lineNumber, 0, "Expected key=value", nil)
+		}
+		key := strings.TrimSpace(line[:separator])
+		if key == "" {
+			return nil, exceptions.NewCdslParseError(name, lineNumber, 1, "Missing property name", nil)
+		}
+		properties[key] = strings.TrimSpace(line[separator+1:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return properties, nil
+}
+
+// EnvPropertyResolver resolves properties from environment variables. A property is looked up by its
+// own name first and then in upper case with dots and dashes replaced by underscores, so kyc.aml-level
+// may be supplied as KYC_AML_LEVEL.
+type EnvPropertyResolver struct{}
+
+// NewEnvPropertyResolver creates a new EnvPropertyResolver
+func NewEnvPropertyResolver() *EnvPropertyResolver {
+	return &EnvPropertyResolver{}
+}
+
+// Lookup implements PropertyResolver
+func (r *EnvPropertyResolver) Lookup(name string) (string, bool) {
+	if value, exists := os.LookupEnv(name); exists {
+		return value, true
+	}
+	return os.LookupEnv(strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name)))
+}
+
+// ChainPropertyResolver consults several resolvers in turn, returning the first value found
+type ChainPropertyResolver struct {
+	resolvers []PropertyResolver
+}
+
+// NewChainPropertyResolver creates a new ChainPropertyResolver, earlier resolvers taking precedence
+func NewChainPropertyResolver(resolvers ...PropertyResolver) *ChainPropertyResolver {
+	return &ChainPropertyResolver{
+		resolvers: resolvers,
+	}
+}
+
+// Lookup implements PropertyResolver
+func (r *ChainPropertyResolver) Lookup(name string) (string, bool) {
+	for _, resolver := range r.resolvers {
+		if value, exists := resolver.Lookup(name); exists {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// propertySubstituter replaces placeholders in element definitions, collecting every
+// placeholder that has neither a property nor a default so they can be reported together
+type propertySubstituter struct {
+	resolver   PropertyResolver
+	unresolved []string
+}
+
+// newPropertySubstituter creates a new propertySubstituter, resolver may be nil
+func newPropertySubstituter(resolver PropertyResolver) *propertySubstituter {
+	return &propertySubstituter{
+		resolver: resolver,
+	}
+}
+
+// substitute returns a copy of elems with the placeholders in their attributes, content and children resolved
+func (s *propertySubstituter) substitute(elems []definitionsource.ElementDefinition) []definitionsource.ElementDefinition {
+	if elems == nil {
+		return nil
+	}
+
+	result := make([]definitionsource.ElementDefinition, len(elems))
+	for i, elem := range elems {
+		result[i] = elem
+		result[i].Attributes = make(map[string]string, len(elem.Attributes))
+		for _, k := range sortedKeys(elem.Attributes) {
+			result[i].Attributes[k] = s.substituteString(elem.Attributes[k], elem.Position)
+		}
+		result[i].Content = s.substituteString(elem.Content, elem.Position)
+		result[i].Elements = s.substitute(elem.Elements)
+	}
+	return result
+}
+
+// substituteString resolves the placeholders in a single value and unescapes literal ${
+func (s *propertySubstituter) substituteString(value string, position types.SourcePosition) string {
+	return placeholderPattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+		if placeholder == placeholderEscape {
+			return "${"
+		}
+		match := placeholderPattern.FindStringSubmatch(placeholder)
+		if s.resolver != nil {
+			if resolved, exists := s.resolver.Lookup(match[1]); exists {
+				return resolved
+			}
+		}
+		if strings.Contains(placeholder, ":") {
+			return match[2]
+		}
+		s.unresolved = append(s.unresolved, fmt.Sprintf("%s at %s", match[1], position))
+		return placeholder
+	})
+}
+
+// err returns an error listing every unresolved placeholder, or nil if all were resolved
+func (s *propertySubstituter) err() error {
+	if len(s.unresolved) == 0 {
+		return nil
+	}
+	return exceptions.NewCdslValidationError(
+		fmt.Sprintf("Unresolved properties: %s", strings.Join(s.unresolved, ", ")),
+		nil,
+	)
+}
@@ -1,18 +1,23 @@
 package registry
 
 import (
+	"crypto/ed25519"
 	"log"
 	
 	"github.com/rsqn/go-cdsl/pkg/definitionsource"
 	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
 	"github.com/rsqn/go-cdsl/pkg/model"
 	"github.com/rsqn/go-cdsl/pkg/types"
 )
 
 // RegistryLoader is responsible for loading flow definitions into the registry
 type RegistryLoader struct {
-	flowRegistry  FlowRegistry
-	dslInitHelper *DslInitialisationHelper
+	flowRegistry     FlowRegistry
+	dslInitHelper    *DslInitialisationHelper
+	propertyResolver PropertyResolver
+	signatureKeys    []ed25519.PublicKey
+	flowSources      map[string]*definitionsource.DocumentDefinition
 }
 
 // NewRegistryLoader creates a new RegistryLoader
@@ -20,50 +25,192 @@ func NewRegistryLoader(flowRegistry FlowRegistry, dslInitHelper *DslInitialisati
 	return &RegistryLoader{
 		flowRegistry:  flowRegistry,
 		dslInitHelper: dslInitHelper,
+		flowSources:   make(map[string]*definitionsource.DocumentDefinition),
 	}
 }
 
-// LoadDocument loads a document into the registry
+// SetPropertyResolver sets the resolver for ${name:default} placeholders in attribute values and content.
+// Without a resolver only placeholders with a default can be resolved.
+func (l *RegistryLoader) SetPropertyResolver(resolver PropertyResolver) {
+	l.propertyResolver = resolver
+}
+
+// RequireSignatures makes the loader reject any document without a detached signature over its
+// content that verifies against one of keys
+func (l *RegistryLoader) RequireSignatures(keys ...ed25519.PublicKey) {
+	l.signatureKeys = keys
+}
+
+// LoadDocuments loads several documents into the registry
+func (l *RegistryLoader) LoadDocuments(docs []*definitionsource.DocumentDefinition) error {
+	// Documents are loaded as one batch so a flow may extend a flow from any of them
+	var batch []*definitionsource.DocumentDefinition
+	seen := make(map[*definitionsource.DocumentDefinition]bool)
+	for _, doc := range docs {
+		for _, d := range doc.WithImported() {
+			if !seen[d] {
+				seen[d] = true
+				batch = append(batch, d)
+			}
+		}
+	}
+	
+	return l.load(batch)
+}
+
+// LoadDocument loads a document, along with the documents it imports, into the registry.
+// A flow ID may only be defined by one source; reloading the same source replaces its flows. A document
+// without a Source can only replace its own flows.
 func (l *RegistryLoader) LoadDocument(doc *definitionsource.DocumentDefinition) error {
-	for _, flowDef := range doc.Flows {
-		flow := model.NewFlow().From(*flowDef)
-		
-		// Process steps
-		for stepID, stepDef := range flowDef.Steps {
-			step := model.NewFlowStep(stepID)
-			
-			// Process logic elements
-			for _, elemDef := range stepDef.Elements {
-				meta := types.DslMetadata{
-					Name:  elemDef.Name,
-					Model: l.buildModel(elemDef),
-				}
-				step.LogicElements = append(step.LogicElements, meta)
+	return l.load(doc.WithImported())
+}
+
+// load registers the flows of a batch of documents, registering nothing if any flow is rejected
+func (l *RegistryLoader) load(docs []*definitionsource.DocumentDefinition) error {
+	if len(l.signatureKeys) > 0 {
+		for _, d := range docs {
+			if err := verifySignature(d, l.signatureKeys); err != nil {
+				return err
 			}
-			
-			// Process finally elements
-			for _, elemDef := range stepDef.Finally {
-				meta := types.DslMetadata{
-					Name:  elemDef.Name,
-					Model: l.buildModel(elemDef),
-				}
-				step.FinalElements = append(step.FinalElements, meta)
+		}
+	}
+	
+	// Reject duplicate flow IDs before anything is registered
+	pending := make(map[string]*definitionsource.DocumentDefinition)
+	checksums := make(map[string]string)
+	defs := make(map[string]*definitionsource.FlowDefinition)
+	for _, d := range docs {
+		for flowID, flowDef := range d.Flows {
+			if existing, exists := pending[flowID]; exists && !sameDocument(existing, d) {
+				return exceptions.NewCdslDuplicateFlowError(flowID, documentName(existing), documentName(d))
+			}
+			if existing, exists := l.flowSources[flowID]; exists && !sameDocument(existing, d) {
+				return exceptions.NewCdslDuplicateFlowError(flowID, documentName(existing), documentName(d))
 			}
-			
-			flow.PutStep(stepID, step)
+			pending[flowID] = d
+			checksums[flowID] = d.Checksum
+			defs[flowID] = flowDef
 		}
-		
+	}
+	
+	fragments, err := newFragmentExpander(docs)
+	if err != nil {
+		return err
+	}
+	session := &buildSession{
+		fragments:  fragments,
+		properties: newPropertySubstituter(l.propertyResolver),
+	}
+	
+	// Build every flow, resolving inheritance, before registering any of them
+	resolver := newFlowResolver(l, defs, session)
+	flows := make([]*model.Flow, 0, len(defs))
+	for _, flowID := range sortedKeys(defs) {
+		flow, err := resolver.resolve(flowID)
+		if err != nil {
+			return err
+		}
+		flows = append(flows, flow)
+	}
+	if err := session.properties.err(); err != nil {
+		return err
+	}
+	
+	for _, flow := range flows {
+		if flow.Checksum, err = FlowChecksum(flow); err != nil {
+			return err
+		}
+		flow.DocumentChecksum = checksums[flow.ID]
+		flow.Source = pending[flow.ID].Source
+	}
+	
+	for _, flow := range flows {
 		if err := l.flowRegistry.RegisterFlow(flow); err != nil {
 			return err
 		}
+		l.flowSources[flow.ID] = pending[flow.ID]
 	}
 	
 	return nil
 }
 
+// sameDocument reports whether two documents are the same document, or are loaded from the same source.
+// Documents without a Source are only the same as themselves.
+func sameDocument(a *definitionsource.DocumentDefinition, b *definitionsource.DocumentDefinition) bool {
+	return a == b || (a.Source != "" && a.Source == b.Source)
+}
+
+// documentName names a document in errors
+func documentName(d *definitionsource.DocumentDefinition) string {
+	if d.Source == "" {
+		return "<unnamed document>"
+	}
+	return d.Source
+}
+
+// buildSession holds the state shared by the flows built from one batch of documents
+type buildSession struct {
+	fragments  *fragmentExpander
+	properties *propertySubstituter
+}
+
+// buildFlow builds a flow from its definition, expanding fragments and resolving property placeholders
+func (l *RegistryLoader) buildFlow(flowDef *definitionsource.FlowDefinition, session *buildSession) (*model.Flow, error) {
+	if _, err := flowDef.MaxDurationValue(); err != nil {
+		return nil, exceptions.NewCdslValidationErrorAt(flowDef.Position, "Invalid flow limits", err)
+	}
+	flow := model.NewFlow().From(*flowDef)
+	
+	// Process steps
+	for stepID, stepDef := range flowDef.Steps {
+		step := model.NewFlowStep(stepID)
+		step.Position = stepDef.Position
+		
+		elements, err := session.fragments.expand(stepDef.Elements)
+		if err != nil {
+			return nil, err
+		}
+		finally, err := session.fragments.expand(stepDef.Finally)
+		if err != nil {
+			return nil, err
+		}
+		elements = session.properties.substitute(elements)
+		finally = session.properties.substitute(finally)
+		
+		// Process logic elements
+		for _, elemDef := range elements {
+			meta := types.DslMetadata{
+				Name:     elemDef.Name,
+				Model:    l.buildModel(elemDef),
+				Position: elemDef.Position,
+			}
+			step.LogicElements = append(step.LogicElements, meta)
+		}
+		
+		// Process finally elements
+		for _, elemDef := range finally {
+			meta := types.DslMetadata{
+				Name:     elemDef.Name,
+				Model:    l.buildModel(elemDef),
+				Position: elemDef.Position,
+			}
+			step.FinalElements = append(step.FinalElements, meta)
+		}
+		
+		flow.PutStep(stepID, step)
+	}
+	
+	return flow, nil
+}
+
 // buildModel builds a model from an element definition
 func (l *RegistryLoader) buildModel(elemDef definitionsource.ElementDefinition) interface{} {
-	model := dsl.NewMapModel()
+	return buildMapModel(elemDef)
+}
+
+// buildMapModel builds a MapModel from an element definition, nesting a model for each child element
+func buildMapModel(elemDef definitionsource.ElementDefinition) *dsl.MapModel {
+	model := dsl.NewNamedMapModel(elemDef.Name)
 	
 	// Add attributes
 	for k, v := range elemDef.Attributes {
@@ -71,15 +218,15 @@ func (l *RegistryLoader) buildModel(elemDef definitionsource.ElementDefinition)
 		log.Printf("Setting attribute in model: %s = %s", k, v)
 	}
 	
-	// Add elements
-	for k, v := range elemDef.Elements {
-		model.Set(k, v)
-		log.Printf("Setting element in model: %s = %v", k, v)
+	// Add elements in document order
+	for _, child := range elemDef.Elements {
+		model.AddChild(buildMapModel(child))
+		log.Printf("Setting element in model: %s", child.Name)
 	}
 	
 	// Add content if present
 	if elemDef.Content != "" {
-		model.Set("content", elemDef.Content)
+		model.Content = elemDef.Content
 		log.Printf("Setting content in model: %s", elemDef.Content)
 	}
 	
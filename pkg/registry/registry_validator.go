@@ -2,6 +2,7 @@ package registry
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/rsqn/go-cdsl/pkg/dsl"
 	"github.com/rsqn/go-cdsl/pkg/exceptions"
@@ -23,74 +24,247 @@ func NewRegistryValidator(flowRegistry FlowRegistry, dslInitHelper *DslInitialis
 	}
 }
 
-// ValidateFlow validates a flow
+// ValidateFlow validates a flow, returning a FlowValidationError listing every error found by AnalyseFlow
 func (v *RegistryValidator) ValidateFlow(flow *model.Flow) error {
+	return v.AnalyseFlow(flow).Err()
+}
+
+// AnalyseFlow checks a flow and the step graph built from its routing DSLs, returning every problem
+// found rather than stopping at the first. Route targets that do not exist and cycles that can loop
+// without an await are errors; unreachable steps and steps with no exit are warnings.
+func (v *RegistryValidator) AnalyseFlow(flow *model.Flow) *ValidationReport {
+	report := &ValidationReport{FlowID: flow.ID}
+	
 	// Validate flow has an ID
 	if flow.ID == "" {
-		return exceptions.NewCdslValidationError("Flow must have an ID", nil)
+		report.add(SeverityError, "", flow.Position, "Flow must have an ID", nil)
 	}
 	
 	// Validate flow has a default step
 	if flow.DefaultStep == "" {
-		return exceptions.NewCdslValidationError(fmt.Sprintf("Flow %s must have a default step", flow.ID), nil)
-	}
-	
-	// Validate default step exists
-	if flow.FetchStep(flow.DefaultStep) == nil {
-		return exceptions.NewCdslValidationError(
-			fmt.Sprintf("Flow %s default step %s does not exist", flow.ID, flow.DefaultStep),
-			nil,
-		)
+		report.add(SeverityError, "", flow.Position, fmt.Sprintf("Flow %s must have a default step", flow.ID), nil)
+	} else if flow.FetchStep(flow.DefaultStep) == nil {
+		report.add(SeverityError, "", flow.Position, fmt.Sprintf("Flow %s default step %s does not exist", flow.ID, flow.DefaultStep), nil)
 	}
 	
 	// Validate error step exists if specified
 	if flow.ErrorStep != "" && flow.FetchStep(flow.ErrorStep) == nil {
-		return exceptions.NewCdslValidationError(
-			fmt.Sprintf("Flow %s error step %s does not exist", flow.ID, flow.ErrorStep),
-			nil,
-		)
+		report.add(SeverityError, "", flow.Position, fmt.Sprintf("Flow %s error step %s does not exist", flow.ID, flow.ErrorStep), nil)
 	}
 	
 	// Validate steps
-	for stepID, step := range flow.Steps {
+	for _, stepID := range sortedKeys(flow.Steps) {
+		step := flow.Steps[stepID]
+		
 		// Validate step has an ID
 		if step.ID == "" {
-			return exceptions.NewCdslValidationError(
-				fmt.Sprintf("Step in flow %s must have an ID", flow.ID),
-				nil,
-			)
+			report.add(SeverityError, stepID, step.Position, fmt.Sprintf("Step in flow %s must have an ID", flow.ID), nil)
+			continue
 		}
 		
 		// Validate step ID matches key
 		if step.ID != stepID {
-			return exceptions.NewCdslValidationError(
-				fmt.Sprintf("Step ID %s does not match key %s in flow %s", step.ID, stepID, flow.ID),
-				nil,
-			)
+			report.add(SeverityError, stepID, step.Position, fmt.Sprintf("Step ID %s does not match key %s in flow %s", step.ID, stepID, flow.ID), nil)
 		}
 		
 		// Validate logic elements
 		for _, elemMeta := range step.LogicElements {
 			if err := v.validateDslElement(elemMeta); err != nil {
-				return exceptions.NewCdslValidationError(
-					fmt.Sprintf("Invalid logic element %s in step %s of flow %s", elemMeta.Name, step.ID, flow.ID),
-					err,
-				)
+				report.add(SeverityError, stepID, elemMeta.Position, fmt.Sprintf("Invalid logic element %s in step %s of flow %s", elemMeta.Name, step.ID, flow.ID), err)
 			}
 		}
 		
 		// Validate final elements
 		for _, elemMeta := range step.FinalElements {
 			if err := v.validateDslElement(elemMeta); err != nil {
-				return exceptions.NewCdslValidationError(
-					fmt.Sprintf("Invalid final element %s in step %s of flow %s", elemMeta.Name, step.ID, flow.ID),
-					err,
-				)
+				report.add(SeverityError, stepID, elemMeta.Position, fmt.Sprintf("Invalid final element %s in step %s of flow %s", elemMeta.Name, step.ID, flow.ID), err)
 			}
 		}
 	}
 	
-	return nil
+	v.analyseGraph(flow, report)
+	
+	return report
+}
+
+// stepEdge is an exit from one step to another, read from a routing DSL
+type stepEdge struct {
+	action      types.Action
+	target      string
+	conditional bool
+	position    types.SourcePosition
+}
+
+// stepGraph returns the exits of each step and whether the step can leave at all, reporting routes
+// to steps that do not exist
+func (v *RegistryValidator) stepGraph(flow *model.Flow, report *ValidationReport) (map[string][]stepEdge, map[string]bool) {
+	edges := make(map[string][]stepEdge, len(flow.Steps))
+	exits := make(map[string]bool, len(flow.Steps))
+	
+	for _, stepID := range sortedKeys(flow.Steps) {
+		step := flow.Steps[stepID]
+		elements := append(append([]types.DslMetadata{}, step.LogicElements...), step.FinalElements...)
+		
+		for _, elemMeta := range elements {
+			routingDsl, ok := v.dslInitHelper.Resolve(elemMeta).(dsl.RoutingDsl)
+			if !ok {
+				continue
+			}
+			model, err := dsl.Bind(routingDsl, elemMeta.Model)
+			if err != nil {
+				continue
+			}
+			
+			// A DSL with several exits takes at most one of them, so each depends on the execution
+			routes := routingDsl.Routes(model)
+			for _, route := range routes {
+				exits[stepID] = true
+				if route.Action == types.ActionEnd {
+					continue
+				}
+				if flow.FetchStep(route.Target) == nil {
+					report.add(SeverityError, stepID, elemMeta.Position, fmt.Sprintf("Step %s of flow %s routes to step %s which does not exist", stepID, flow.ID, route.Target), nil)
+					continue
+				}
+				edges[stepID] = append(edges[stepID], stepEdge{
+					action:      route.Action,
+					target:      route.Target,
+					conditional: route.Conditional || len(routes) > 1,
+					position:    elemMeta.Position,
+				})
+			}
+		}
+	}
+	
+	return edges, exits
+}
+
+// analyseGraph reports unreachable steps, steps with no exit and cycles that never await
+func (v *RegistryValidator) analyseGraph(flow *model.Flow, report *ValidationReport) {
+	edges, exits := v.stepGraph(flow, report)
+	
+	// The error step is entered by the executor rather than a route, so it counts as reachable
+	reachable := make(map[string]bool, len(flow.Steps))
+	var visit func(stepID string)
+	visit = func(stepID string) {
+		if reachable[stepID] || flow.FetchStep(stepID) == nil {
+			return
+		}
+		reachable[stepID] = true
+		for _, edge := range edges[stepID] {
+			visit(edge.target)
+		}
+	}
+	visit(flow.DefaultStep)
+	visit(flow.ErrorStep)
+	
+	for _, stepID := range sortedKeys(flow.Steps) {
+		step := flow.Steps[stepID]
+		if flow.DefaultStep != "" && !reachable[stepID] {
+			report.add(SeverityWarning, stepID, step.Position, fmt.Sprintf("Step %s of flow %s is not reachable from default step %s", stepID, flow.ID, flow.DefaultStep), nil)
+		}
+		if !exits[stepID] {
+			report.add(SeverityWarning, stepID, step.Position, fmt.Sprintf("Step %s of flow %s has no routeTo, await or endRoute", stepID, flow.ID), nil)
+		}
+	}
+	
+	// A cycle with a conditional route may be left at runtime, so it is only a warning
+	for _, cycle := range routeCycles(flow, edges) {
+		severity, message := SeverityError, "form a cycle without an await"
+		if cycle.conditional {
+			severity, message = SeverityWarning, "form a cycle without an await unless a conditional route leaves it"
+		}
+		report.add(
+			severity,
+			cycle.steps[0],
+			flow.Steps[cycle.steps[0]].Position,
+			fmt.Sprintf("Steps %s of flow %s %s", strings.Join(append(cycle.steps, cycle.steps[0]), " -> "), flow.ID, message),
+			nil,
+		)
+	}
+}
+
+// routeCycle is a cycle of steps joined by routes, conditional if any of its steps has a conditional route
+// that could leave it
+type routeCycle struct {
+	steps       []string
+	conditional bool
+}
+
+// routeCycles finds cycles made only of routes, which would loop forever without pausing for an event.
+// Each step is reported in at most one cycle
+func routeCycles(flow *model.Flow, edges map[string][]stepEdge) []routeCycle {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	
+	state := make(map[string]int, len(flow.Steps))
+	reported := make(map[string]bool)
+	var path []string
+	var cycles []routeCycle
+	
+	var visit func(stepID string)
+	visit = func(stepID string) {
+		state[stepID] = onPath
+		path = append(path, stepID)
+		
+		for _, edge := range edges[stepID] {
+			if edge.action != types.ActionRoute {
+				continue
+			}
+			switch state[edge.target] {
+			case unvisited:
+				visit(edge.target)
+			case onPath:
+				start := len(path) - 1
+				for path[start] != edge.target {
+					start--
+				}
+				cycle := routeCycle{steps: append([]string{}, path[start:]...)}
+				cycle.conditional = anyConditional(cycle.steps, edges)
+				if !anyReported(cycle.steps, reported) {
+					for _, id := range cycle.steps {
+						reported[id] = true
+					}
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+		
+		path = path[:len(path)-1]
+		state[stepID] = done
+	}
+	
+	for _, stepID := range sortedKeys(flow.Steps) {
+		if state[stepID] == unvisited {
+			visit(stepID)
+		}
+	}
+	return cycles
+}
+
+// anyConditional returns true if any of the steps has a conditional route
+func anyConditional(stepIDs []string, edges map[string][]stepEdge) bool {
+	for _, id := range stepIDs {
+		for _, edge := range edges[id] {
+			if edge.conditional {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// anyReported returns true if any of the steps is already part of a reported cycle
+func anyReported(stepIDs []string, reported map[string]bool) bool {
+	for _, id := range stepIDs {
+		if reported[id] {
+			return true
+		}
+	}
+	return false
 }
 
 // validateDslElement validates a DSL element
@@ -101,11 +275,28 @@ func (v *RegistryValidator) validateDslElement(elemMeta types.DslMetadata) error
 	}
 	
 	// Validate element can be resolved
+	if _, err := v.dslInitHelper.ResolveName(elemMeta.Name); err != nil {
+		return err
+	}
 	dslInstance := v.dslInitHelper.Resolve(elemMeta)
 	if dslInstance == nil {
 		return exceptions.NewCdslValidationError(fmt.Sprintf("DSL %s could not be resolved", elemMeta.Name), nil)
 	}
 	
+	// Validate attributes and child elements against the catalogue
+	if descriptor, described := v.dslInitHelper.Descriptor(elemMeta.Name); described {
+		if m, ok := elemMeta.Model.(*dsl.MapModel); ok {
+			if err := dsl.ValidateElement(descriptor.ElementDescriptor, m); err != nil {
+				return err
+			}
+		}
+	}
+	
+	// Validate the element binds to the DSL's typed model
+	if _, err := dsl.Bind(dslInstance, elemMeta.Model); err != nil {
+		return err
+	}
+	
 	// Validate element if it's a validating DSL
 	if validatingDsl, ok := dslInstance.(dsl.ValidatingDsl); ok {
 		if err := validatingDsl.Validate(); err != nil {
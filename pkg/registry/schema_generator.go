@@ -0,0 +1,463 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+)
+
+// schemaIndent is the indentation used for each level of nesting in generated schemas
+const schemaIndent = "    "
+
+// placeholderSchemaPattern matches a value holding a ${name} or ${name:default} property placeholder, which is
+// accepted wherever a typed value is
+const placeholderSchemaPattern = `.*\$\{[A-Za-z_][A-Za-z0-9_.\-]*(:[^}]*)?\}.*`
+
+// GenerateXsd generates an XML Schema for flow documents using the DSL elements in the catalogue, for editors
+// to validate and complete XML flow files against. DSLs from libraries are declared by their bare name when no
+// other library uses it; elements written with a namespace prefix are accepted without being checked, as they
+// belong to a namespace this schema does not declare.
+func GenerateXsd(catalogue []dsl.DslDescriptor) []byte {
+	w := &xsdWriter{}
+
+	w.line(0, `<?xml version="1.0" encoding="utf-8" ?>`)
+	w.line(0, `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" elementFormDefault="qualified">`)
+
+	w.line(1, `<xs:element name="cdsl">`)
+	w.line(2, `<xs:complexType>`)
+	w.line(3, `<xs:sequence>`)
+	w.line(4, `<xs:element name="import" minOccurs="0" maxOccurs="unbounded">`)
+	w.line(5, `<xs:complexType>`)
+	w.line(6, `<xs:attribute name="href" type="xs:string" use="required"/>`)
+	w.line(5, `</xs:complexType>`)
+	w.line(4, `</xs:element>`)
+	w.line(4, `<xs:choice minOccurs="0" maxOccurs="unbounded">`)
+	w.line(5, `<xs:element name="fragment" type="fragmentType"/>`)
+	w.line(5, `<xs:element name="flow" type="flowType"/>`)
+	w.line(4, `</xs:choice>`)
+	w.line(3, `</xs:sequence>`)
+	w.line(2, `</xs:complexType>`)
+	w.line(1, `</xs:element>`)
+
+	w.blank()
+	w.line(1, `<xs:complexType name="flowType">`)
+	w.line(2, `<xs:sequence>`)
+	w.line(3, `<xs:element name="step" type="stepType" minOccurs="0" maxOccurs="unbounded"/>`)
+	w.line(2, `</xs:sequence>`)
+	w.line(2, `<xs:attribute name="id" type="xs:string" use="required"/>`)
+	w.line(2, `<xs:attribute name="extends" type="xs:string"/>`)
+	w.line(2, `<xs:attribute name="defaultStep" type="xs:string"/>`)
+	w.line(2, `<xs:attribute name="errorStep" type="xs:string"/>`)
+	w.line(2, `<xs:attribute name="maxTransitions" type="xs:nonNegativeInteger"/>`)
+	w.line(2, `<xs:attribute name="maxElements" type="xs:nonNegativeInteger"/>`)
+	w.line(2, `<xs:attribute name="maxDuration" type="xs:string"/>`)
+	w.line(1, `</xs:complexType>`)
+
+	w.blank()
+	w.line(1, `<xs:complexType name="stepType">`)
+	w.line(2, `<xs:sequence>`)
+	w.line(3, `<xs:group ref="dslElements" minOccurs="0" maxOccurs="unbounded"/>`)
+	w.line(3, `<xs:element name="finally" minOccurs="0">`)
+	w.line(4, `<xs:complexType>`)
+	w.line(5, `<xs:group ref="dslElements" minOccurs="0" maxOccurs="unbounded"/>`)
+	w.line(4, `</xs:complexType>`)
+	w.line(3, `</xs:element>`)
+	w.line(2, `</xs:sequence>`)
+	w.line(2, `<xs:attribute name="id" type="xs:string" use="required"/>`)
+	w.line(2, `<xs:attribute name="override" type="xs:boolean"/>`)
+	w.line(1, `</xs:complexType>`)
+
+	w.blank()
+	w.line(1, `<xs:complexType name="fragmentType">`)
+	w.line(2, `<xs:group ref="dslElements" minOccurs="0" maxOccurs="unbounded"/>`)
+	w.line(2, `<xs:attribute name="id" type="xs:string" use="required"/>`)
+	w.line(1, `</xs:complexType>`)
+
+	w.blank()
+	w.line(1, `<xs:group name="dslElements">`)
+	w.line(2, `<xs:choice>`)
+	unique := uniqueLocalNames(catalogue)
+	for _, descriptor := range catalogue {
+		if unique[descriptor.Name] {
+			w.line(3, `<xs:element`+xsdAttr("name", descriptor.Name)+xsdAttr("type", xsdTypeName(descriptor))+`/>`)
+		}
+	}
+	w.line(3, `<xs:element name="`+useFragmentElement+`">`)
+	w.line(4, `<xs:complexType>`)
+	w.line(5, `<xs:attribute name="ref" type="xs:string" use="required"/>`)
+	w.line(5, `<xs:anyAttribute processContents="skip"/>`)
+	w.line(4, `</xs:complexType>`)
+	w.line(3, `</xs:element>`)
+	w.line(3, `<xs:any namespace="##other" processContents="lax"/>`)
+	w.line(2, `</xs:choice>`)
+	w.line(1, `</xs:group>`)
+
+	for _, descriptor := range catalogue {
+		w.blank()
+		w.complexType(1, xsdTypeName(descriptor), descriptor.ElementDescriptor)
+	}
+
+	w.blank()
+	w.line(1, `<xs:simpleType name="placeholder">`)
+	w.line(2, `<xs:restriction base="xs:string">`)
+	w.line(3, `<xs:pattern`+xsdAttr("value", placeholderSchemaPattern)+`/>`)
+	w.line(2, `</xs:restriction>`)
+	w.line(1, `</xs:simpleType>`)
+	for _, simple := range []struct{ name, base string }{
+		{"intValue", "xs:integer"},
+		{"floatValue", "xs:double"},
+		{"boolValue", "xs:boolean"},
+		{"durationValue", "goDuration"},
+	} {
+		w.line(1, `<xs:simpleType`+xsdAttr("name", simple.name)+`>`)
+		w.line(2, `<xs:union`+xsdAttr("memberTypes", simple.base+" placeholder")+`/>`)
+		w.line(1, `</xs:simpleType>`)
+	}
+	w.line(1, `<xs:simpleType name="goDuration">`)
+	w.line(2, `<xs:restriction base="xs:string">`)
+	w.line(3, `<xs:pattern value="[\-+]?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)"/>`)
+	w.line(2, `</xs:restriction>`)
+	w.line(1, `</xs:simpleType>`)
+
+	w.line(0, `</xs:schema>`)
+	return w.buf.Bytes()
+}
+
+// uniqueLocalNames returns the DSL names that only one entry of the catalogue uses, which elements may name
+// without a namespace prefix
+func uniqueLocalNames(catalogue []dsl.DslDescriptor) map[string]bool {
+	counts := make(map[string]int, len(catalogue))
+	for _, descriptor := range catalogue {
+		counts[descriptor.Name]++
+	}
+	unique := make(map[string]bool, len(counts))
+	for name, count := range counts {
+		unique[name] = count == 1
+	}
+	return unique
+}
+
+// xsdTypeName returns the name of the complex type declared for a DSL, such as kyc.amlCheckElement
+func xsdTypeName(descriptor dsl.DslDescriptor) string {
+	return strings.ReplaceAll(descriptor.QualifiedName(), dsl.NamespaceSeparator, ".") + "Element"
+}
+
+// xsdTypes maps attribute types to the simple types declared by GenerateXsd
+var xsdTypes = map[dsl.AttributeType]string{
+	dsl.AttributeString:   "xs:string",
+	dsl.AttributeInt:      "intValue",
+	dsl.AttributeFloat:    "floatValue",
+	dsl.AttributeBool:     "boolValue",
+	dsl.AttributeDuration: "durationValue",
+}
+
+// xsdWriter accumulates the lines of a generated XML Schema
+type xsdWriter struct {
+	buf bytes.Buffer
+}
+
+// line writes a line at the given depth
+func (w *xsdWriter) line(depth int, text string) {
+	w.buf.WriteString(strings.Repeat(schemaIndent, depth))
+	w.buf.WriteString(text)
+	w.buf.WriteByte('\n')
+}
+
+// blank writes an empty line
+func (w *xsdWriter) blank() {
+	w.buf.WriteByte('\n')
+}
+
+// documentation writes an annotation holding text, if there is any
+func (w *xsdWriter) documentation(depth int, text string) {
+	if text == "" {
+		return
+	}
+	w.line(depth, `<xs:annotation>`)
+	w.line(depth+1, `<xs:documentation>`+xsdText(text)+`</xs:documentation>`)
+	w.line(depth, `</xs:annotation>`)
+}
+
+// complexType writes the complex type of an element, named for DSL elements and anonymous for their children
+func (w *xsdWriter) complexType(depth int, name string, descriptor dsl.ElementDescriptor) {
+	open := `<xs:complexType`
+	if name != "" {
+		open += xsdAttr("name", name)
+	}
+	if descriptor.Content {
+		open += ` mixed="true"`
+	}
+	w.line(depth, open+`>`)
+	if name != "" {
+		w.documentation(depth+1, descriptor.Description)
+	}
+
+	if len(descriptor.Children) > 0 {
+		w.line(depth+1, `<xs:choice minOccurs="0" maxOccurs="unbounded">`)
+		for _, child := range descriptor.Children {
+			w.line(depth+2, `<xs:element`+xsdAttr("name", child.Name)+`>`)
+			w.documentation(depth+3, child.Description)
+			w.complexType(depth+3, "", child)
+			w.line(depth+2, `</xs:element>`)
+		}
+		w.line(depth+1, `</xs:choice>`)
+	}
+
+	for _, attr := range descriptor.Attributes {
+		w.attribute(depth+1, attr)
+	}
+	w.line(depth, `</xs:complexType>`)
+}
+
+// attribute writes an attribute declaration, restricting it to its allowed values when it has them
+func (w *xsdWriter) attribute(depth int, attr dsl.AttributeDescriptor) {
+	open := `<xs:attribute` + xsdAttr("name", attr.Name)
+	if len(attr.AllowedValues) == 0 {
+		open += xsdAttr("type", xsdTypes[attr.Type])
+	}
+	if attr.Required {
+		open += ` use="required"`
+	}
+
+	description := attr.Description
+	if attr.Default != "" {
+		description = strings.TrimSpace(description + " (default " + attr.Default + ")")
+	}
+	if description == "" && len(attr.AllowedValues) == 0 {
+		w.line(depth, open+`/>`)
+		return
+	}
+
+	w.line(depth, open+`>`)
+	w.documentation(depth+1, description)
+	if len(attr.AllowedValues) > 0 {
+		w.line(depth+1, `<xs:simpleType>`)
+		w.line(depth+2, `<xs:union memberTypes="placeholder">`)
+		w.line(depth+3, `<xs:simpleType>`)
+		w.line(depth+4, `<xs:restriction base="xs:string">`)
+		for _, value := range attr.AllowedValues {
+			w.line(depth+5, `<xs:enumeration`+xsdAttr("value", value)+`/>`)
+		}
+		w.line(depth+4, `</xs:restriction>`)
+		w.line(depth+3, `</xs:simpleType>`)
+		w.line(depth+2, `</xs:union>`)
+		w.line(depth+1, `</xs:simpleType>`)
+	}
+	w.line(depth, `</xs:attribute>`)
+}
+
+// xsdAttr formats an attribute with a leading space
+func xsdAttr(name string, value string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(value))
+	return " " + name + `="` + buf.String() + `"`
+}
+
+// xsdText escapes text content
+func xsdText(text string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// jsonAttributePatterns restrict attribute values of each type in the JSON Schema, where every attribute is a string
+var jsonAttributePatterns = map[dsl.AttributeType]string{
+	dsl.AttributeInt:      `^[-+]?[0-9]+$`,
+	dsl.AttributeFloat:    `^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`,
+	dsl.AttributeBool:     `^(1|t|T|TRUE|true|True|0|f|F|FALSE|false|False)$`,
+	dsl.AttributeDuration: `^[-+]?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`,
+}
+
+// GenerateJsonSchema generates a JSON Schema for flow documents using the DSL elements in the catalogue, for
+// editors to validate and complete JSON and YAML flow files against
+func GenerateJsonSchema(catalogue []dsl.DslDescriptor) ([]byte, error) {
+	elementRefs := make([]interface{}, 0, len(catalogue)+1)
+	defs := map[string]interface{}{
+		"placeholder": map[string]interface{}{
+			"type":    "string",
+			"pattern": placeholderSchemaPattern,
+		},
+		"elements": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"$ref": "#/$defs/element"},
+		},
+		"fragment": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":       map[string]interface{}{"type": "string"},
+				"elements": map[string]interface{}{"$ref": "#/$defs/elements"},
+			},
+			"additionalProperties": false,
+		},
+		"flow": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":             map[string]interface{}{"type": "string"},
+				"extends":        map[string]interface{}{"type": "string"},
+				"defaultStep":    map[string]interface{}{"type": "string"},
+				"errorStep":      map[string]interface{}{"type": "string"},
+				"maxTransitions": map[string]interface{}{"type": "integer", "minimum": 0},
+				"maxElements":    map[string]interface{}{"type": "integer", "minimum": 0},
+				"maxDuration":    map[string]interface{}{"type": "string"},
+				"steps": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": map[string]interface{}{"$ref": "#/$defs/step"},
+				},
+			},
+			"required":             []string{"steps"},
+			"additionalProperties": false,
+		},
+		"step": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":       map[string]interface{}{"type": "string"},
+				"override": map[string]interface{}{"type": "boolean"},
+				"elements": map[string]interface{}{"$ref": "#/$defs/elements"},
+				"finally":  map[string]interface{}{"$ref": "#/$defs/elements"},
+			},
+			"additionalProperties": false,
+		},
+		useFragmentElement: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{"const": useFragmentElement},
+				"attributes": map[string]interface{}{
+					"type":                 "object",
+					"properties":           map[string]interface{}{"ref": map[string]interface{}{"type": "string"}},
+					"required":             []string{"ref"},
+					"additionalProperties": map[string]interface{}{"type": "string"},
+				},
+			},
+			"required":             []string{"name", "attributes"},
+			"additionalProperties": false,
+		},
+	}
+
+	unique := uniqueLocalNames(catalogue)
+	for _, descriptor := range catalogue {
+		key := "dsl." + descriptor.QualifiedName()
+		schema := jsonElementSchema(descriptor.ElementDescriptor)
+		names := []string{descriptor.QualifiedName()}
+		if descriptor.Namespace != "" && unique[descriptor.Name] {
+			names = append(names, descriptor.Name)
+		}
+		schema["properties"].(map[string]interface{})["name"] = map[string]interface{}{"enum": names}
+		defs[key] = schema
+		elementRefs = append(elementRefs, map[string]interface{}{"$ref": "#/$defs/" + key})
+	}
+	elementRefs = append(elementRefs, map[string]interface{}{"$ref": "#/$defs/" + useFragmentElement})
+	defs["element"] = map[string]interface{}{"oneOf": elementRefs}
+
+	schema := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "CDSL flow document",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"imports": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+			"fragments": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"$ref": "#/$defs/fragment"},
+			},
+			"flows": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"$ref": "#/$defs/flow"},
+			},
+		},
+		"required":             []string{"flows"},
+		"additionalProperties": false,
+		"$defs":                defs,
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", schemaIndent)
+	if err := encoder.Encode(schema); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonElementSchema returns the schema of an element definition with the attributes and children of descriptor
+func jsonElementSchema(descriptor dsl.ElementDescriptor) map[string]interface{} {
+	attributes := make(map[string]interface{}, len(descriptor.Attributes))
+	var required []string
+	for _, attr := range descriptor.Attributes {
+		attributes[attr.Name] = jsonAttributeSchema(attr)
+		if attr.Required {
+			required = append(required, attr.Name)
+		}
+	}
+
+	attributesSchema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           attributes,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		attributesSchema["required"] = required
+	}
+
+	properties := map[string]interface{}{
+		"name":       map[string]interface{}{"const": descriptor.Name},
+		"attributes": attributesSchema,
+	}
+	if descriptor.Content {
+		properties["content"] = map[string]interface{}{"type": "string"}
+	}
+	if len(descriptor.Children) > 0 {
+		children := make([]interface{}, len(descriptor.Children))
+		for i, child := range descriptor.Children {
+			children[i] = jsonElementSchema(child)
+		}
+		properties["elements"] = map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"oneOf": children},
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             []string{"name"},
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = []string{"name", "attributes"}
+	}
+	if descriptor.Description != "" {
+		schema["description"] = descriptor.Description
+	}
+	return schema
+}
+
+// jsonAttributeSchema returns the schema of an attribute value, which may always be a property placeholder
+func jsonAttributeSchema(attr dsl.AttributeDescriptor) map[string]interface{} {
+	var value map[string]interface{}
+	switch {
+	case len(attr.AllowedValues) > 0:
+		value = map[string]interface{}{"enum": attr.AllowedValues}
+	case jsonAttributePatterns[attr.Type] != "":
+		value = map[string]interface{}{"type": "string", "pattern": jsonAttributePatterns[attr.Type]}
+	}
+
+	schema := map[string]interface{}{"type": "string"}
+	if value != nil {
+		schema = map[string]interface{}{
+			"anyOf": []interface{}{value, map[string]interface{}{"$ref": "#/$defs/placeholder"}},
+		}
+	}
+	if attr.Description != "" {
+		schema["description"] = attr.Description
+	}
+	if attr.Default != "" {
+		schema["default"] = attr.Default
+	}
+	return schema
+}
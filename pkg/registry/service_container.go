@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+)
+
+// InitialisingService is a service that must be initialised before DSLs use it, such as a client that
+// opens a connection
+type InitialisingService interface {
+	Init() error
+}
+
+// ServiceContainer holds the shared services DSLs depend on, such as screening clients, clocks and databases.
+// DslInitialisationHelper injects them into each DSL it resolves, setting exported fields tagged `inject`:
+//
+//	Screener SanctionsScreener `inject:""`                // the one service assignable to the field
+//	Archive  Store             `inject:"documentArchive"` // the service registered under that name
+//	Clock    Clock             `inject:",optional"`       // left nil if there is no such service
+type ServiceContainer struct {
+	services    []namedService
+	initialised []namedService
+	mu          sync.RWMutex
+}
+
+// namedService is a service and the name it was registered under
+type namedService struct {
+	name    string
+	service interface{}
+}
+
+// NewServiceContainer creates an empty ServiceContainer
+func NewServiceContainer() *ServiceContainer {
+	return &ServiceContainer{}
+}
+
+// Register adds a service under a name. Services are initialised in the order they are registered and
+// closed in reverse.
+func (c *ServiceContainer) Register(name string, service interface{}) error {
+	if name == "" || service == nil {
+		return exceptions.NewCdslValidationError("A service must have a name and a value", nil)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, existing := range c.services {
+		if existing.name == name {
+			return exceptions.NewCdslValidationError(fmt.Sprintf("Service %s is already registered", name), nil)
+		}
+	}
+	c.services = append(c.services, namedService{name: name, service: service})
+	return nil
+}
+
+// Lookup returns the service registered under a name
+func (c *ServiceContainer) Lookup(name string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, s := range c.services {
+		if s.name == name {
+			return s.service, true
+		}
+	}
+	return nil, false
+}
+
+// ServiceOf returns the one service in the container assignable to T
+func ServiceOf[T any](c *ServiceContainer) (T, error) {
+	var zero T
+	service, err := c.byType(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return zero, err
+	}
+	return service.(T), nil
+}
+
+// byType returns the one service assignable to t
+func (c *ServiceContainer) byType(t reflect.Type) (interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var matches []namedService
+	for _, s := range c.services {
+		if reflect.TypeOf(s.service).AssignableTo(t) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, exceptions.NewCdslValidationError(fmt.Sprintf("No service of type %s is registered", t), nil)
+	case 1:
+		return matches[0].service, nil
+	}
+
+	names := make([]string, len(matches))
+	for i, s := range matches {
+		names[i] = s.name
+	}
+	return nil, exceptions.NewCdslValidationError(
+		fmt.Sprintf("More than one service of type %s is registered, name one of %s", t, strings.Join(names, ", ")),
+		nil,
+	)
+}
+
+// injectTag is the parsed form of an `inject` struct tag
+type injectTag struct {
+	name     string
+	optional bool
+}
+
+// parseInjectTag parses an `inject` struct tag, returning false if the field is not injected
+func parseInjectTag(field reflect.StructField) (injectTag, bool) {
+	value, ok := field.Tag.Lookup("inject")
+	if !ok || !field.IsExported() {
+		return injectTag{}, false
+	}
+
+	parts := strings.Split(value, ",")
+	tag := injectTag{name: parts[0]}
+	for _, option := range parts[1:] {
+		if option == "optional" {
+			tag.optional = true
+		}
+	}
+	return tag, true
+}
+
+// Inject sets the `inject` tagged fields of target, which must be a pointer to a struct. Targets of any other
+// kind have nothing to inject.
+func (c *ServiceContainer) Inject(target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	value = value.Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := parseInjectTag(field)
+		if !ok {
+			continue
+		}
+
+		service, err := c.serviceFor(tag, field.Type)
+		if err != nil {
+			if tag.optional {
+				continue
+			}
+			return exceptions.NewCdslValidationError(fmt.Sprintf("Cannot inject field %s of %T", field.Name, target), err)
+		}
+		value.Field(i).Set(reflect.ValueOf(service))
+	}
+	return nil
+}
+
+// serviceFor returns the service a field with tag should be injected with
+func (c *ServiceContainer) serviceFor(tag injectTag, t reflect.Type) (interface{}, error) {
+	if tag.name == "" {
+		return c.byType(t)
+	}
+
+	service, exists := c.Lookup(tag.name)
+	if !exists {
+		return nil, exceptions.NewCdslValidationError(fmt.Sprintf("No service named %s is registered", tag.name), nil)
+	}
+	if !reflect.TypeOf(service).AssignableTo(t) {
+		return nil, exceptions.NewCdslValidationError(fmt.Sprintf("Service %s is a %T, not a %s", tag.name, service, t), nil)
+	}
+	return service, nil
+}
+
+// Init initialises every InitialisingService in the order they were registered. If one fails, the services
+// already initialised are closed again. Calling Init again initialises only services registered since.
+func (c *ServiceContainer) Init() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.services[len(c.initialised):] {
+		if initialising, ok := s.service.(InitialisingService); ok {
+			if err := initialising.Init(); err != nil {
+				closeErr := c.close()
+				return errors.Join(exceptions.NewCdslError(fmt.Sprintf("Failed to initialise service %s", s.name), err), closeErr)
+			}
+		}
+		c.initialised = append(c.initialised, s)
+	}
+	return nil
+}
+
+// Close closes every initialised service that implements io.Closer, in the reverse of the order they were
+// initialised, returning every error encountered
+func (c *ServiceContainer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.close()
+}
+
+// close implements Close with the lock held
+func (c *ServiceContainer) close() error {
+	var errs []error
+	for i := len(c.initialised) - 1; i >= 0; i-- {
+		s := c.initialised[i]
+		if closer, ok := s.service.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, exceptions.NewCdslError(fmt.Sprintf("Failed to close service %s", s.name), err))
+			}
+		}
+	}
+	c.initialised = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/types"
+)
+
+// Severity is how serious a validation problem is. Errors stop a flow from being registered,
+// warnings are reported but do not
+type Severity string
+
+const (
+	// SeverityError marks a problem that makes the flow invalid
+	SeverityError Severity = "error"
+	// SeverityWarning marks a problem that is likely a mistake but does not stop the flow running
+	SeverityWarning Severity = "warning"
+)
+
+// ValidationProblem is a single problem found while validating a flow
+type ValidationProblem struct {
+	Severity Severity
+	FlowID   string
+	StepID   string
+	Message  string
+	Position types.SourcePosition
+	Cause    error
+}
+
+// String formats the problem with its position and cause
+func (p ValidationProblem) String() string {
+	return exceptions.NewCdslValidationErrorAt(p.Position, p.Message, p.Cause).Error()
+}
+
+// ValidationReport holds every problem found while validating a flow
+type ValidationReport struct {
+	FlowID   string
+	Problems []ValidationProblem
+}
+
+// add records a problem against a step of the flow; stepID is empty for problems with the flow itself
+func (r *ValidationReport) add(severity Severity, stepID string, position types.SourcePosition, message string, cause error) {
+	r.Problems = append(r.Problems, ValidationProblem{
+		Severity: severity,
+		FlowID:   r.FlowID,
+		StepID:   stepID,
+		Message:  message,
+		Position: position,
+		Cause:    cause,
+	})
+}
+
+// Errors returns the problems with SeverityError
+func (r *ValidationReport) Errors() []ValidationProblem {
+	return r.withSeverity(SeverityError)
+}
+
+// Warnings returns the problems with SeverityWarning
+func (r *ValidationReport) Warnings() []ValidationProblem {
+	return r.withSeverity(SeverityWarning)
+}
+
+// HasErrors returns true if the report holds any errors
+func (r *ValidationReport) HasErrors() bool {
+	return len(r.Errors()) > 0
+}
+
+// withSeverity returns the problems with the given severity, in the order they were found
+func (r *ValidationReport) withSeverity(severity Severity) []ValidationProblem {
+	var result []ValidationProblem
+	for _, problem := range r.Problems {
+		if problem.Severity == severity {
+			result = append(result, problem)
+		}
+	}
+	return result
+}
+
+// Err returns nil if the report holds no errors, otherwise a FlowValidationError describing every error
+func (r *ValidationReport) Err() error {
+	errs := r.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+
+	if len(errs) == 1 {
+		return &FlowValidationError{
+			CdslValidationError: *exceptions.NewCdslValidationErrorAt(errs[0].Position, errs[0].Message, errs[0].Cause),
+			Report:              r,
+		}
+	}
+
+	messages := make([]string, len(errs))
+	for i, problem := range errs {
+		messages[i] = problem.String()
+	}
+	return &FlowValidationError{
+		CdslValidationError: *exceptions.NewCdslValidationError(
+			fmt.Sprintf("Flow %s has %d errors: %s", r.FlowID, len(errs), strings.Join(messages, "; ")),
+			nil,
+		),
+		Report: r,
+	}
+}
+
+// FlowValidationError is returned when a flow fails validation. Report holds every problem found,
+// including warnings
+type FlowValidationError struct {
+	exceptions.CdslValidationError
+	Report *ValidationReport
+}
+
+// Unwrap returns the embedded CdslValidationError so errors.As finds it
+func (e *FlowValidationError) Unwrap() error {
+	return &e.CdslValidationError
+}
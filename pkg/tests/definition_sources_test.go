@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadIntoRegistry loads a document into a fresh registry
+func loadIntoRegistry(t *testing.T, doc *definitionsource.DocumentDefinition) *registry.InMemoryFlowRegistry {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	registryLoader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	require.NoError(t, registryLoader.LoadDocument(doc))
+
+	return flowRegistry
+}
+
+// withoutSource clears the source, positions, document checksum and registry version of a flow so
+// flows loaded from different formats compare equal
+func withoutSource(flow *model.Flow) *model.Flow {
+	flow.Position = types.SourcePosition{}
+	flow.DocumentChecksum = ""
+	flow.Source = ""
+	flow.Version = 0
+	for _, step := range flow.Steps {
+		step.Position = types.SourcePosition{}
+		for i := range step.LogicElements {
+			step.LogicElements[i].Position = types.SourcePosition{}
+		}
+		for i := range step.FinalElements {
+			step.FinalElements[i].Position = types.SourcePosition{}
+		}
+	}
+	return flow
+}
+
+// TestJsonAndXmlKycFlowsAreEquivalent tests that both forms of kyc-flow load to the same flow
+func TestJsonAndXmlKycFlowsAreEquivalent(t *testing.T) {
+	resourcesDir := filepath.Join("..", "..", "resources")
+
+	xmlDoc, err := definitionsource.NewXmlDomDefinitionSource(resourcesDir).LoadDocument("kyc-flow.xml")
+	require.NoError(t, err)
+
+	jsonDoc, err := definitionsource.NewJsonDefinitionSource(resourcesDir).LoadDocument("kyc-flow.json")
+	require.NoError(t, err)
+
+	xmlFlow, err := loadIntoRegistry(t, xmlDoc).GetFlow("kycProcess")
+	require.NoError(t, err)
+	require.NotNil(t, xmlFlow)
+
+	jsonFlow, err := loadIntoRegistry(t, jsonDoc).GetFlow("kycProcess")
+	require.NoError(t, err)
+	require.NotNil(t, jsonFlow)
+
+	assert.Equal(t, withoutSource(xmlFlow), withoutSource(jsonFlow))
+
+	step := jsonDoc.Flows["kycProcess"].Steps["complete"]
+	require.Len(t, step.Finally, 1)
+	assert.Equal(t, "setState", step.Finally[0].Name)
+}
+
+// TestYamlAndXmlKycFlowsAreEquivalent tests that the YAML form of kyc-flow loads to the same flow
+func TestYamlAndXmlKycFlowsAreEquivalent(t *testing.T) {
+	resourcesDir := filepath.Join("..", "..", "resources")
+
+	xmlDoc, err := definitionsource.NewXmlDomDefinitionSource(resourcesDir).LoadDocument("kyc-flow.xml")
+	require.NoError(t, err)
+
+	yamlDoc, err := definitionsource.NewYamlDefinitionSource(resourcesDir).LoadDocument("kyc-flow.yaml")
+	require.NoError(t, err)
+
+	xmlFlow, err := loadIntoRegistry(t, xmlDoc).GetFlow("kycProcess")
+	require.NoError(t, err)
+
+	yamlFlow, err := loadIntoRegistry(t, yamlDoc).GetFlow("kycProcess")
+	require.NoError(t, err)
+
+	assert.Equal(t, withoutSource(xmlFlow), withoutSource(yamlFlow))
+}
+
+// TestFlowWrittenBackOutLoadsToSameFlow tests that a registered flow can be written as XML and loaded again
+func TestFlowWrittenBackOutLoadsToSameFlow(t *testing.T) {
+	doc, err := definitionsource.NewXmlDomDefinitionSource(filepath.Join("..", "..", "resources")).LoadDocument("kyc-flow.xml")
+	require.NoError(t, err)
+
+	flow, err := loadIntoRegistry(t, doc).GetFlow("kycProcess")
+	require.NoError(t, err)
+
+	flowDef, err := registry.FlowDefinitionOf(flow)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	written := &definitionsource.DocumentDefinition{
+		Flows: map[string]*definitionsource.FlowDefinition{flowDef.ID: flowDef},
+	}
+	require.NoError(t, definitionsource.XmlDocumentWriter{}.WriteDocument(&buf, written))
+
+	reread, err := definitionsource.XmlDocumentParser{}.ParseDocument("written.xml", &buf)
+	require.NoError(t, err)
+
+	rereadFlow, err := loadIntoRegistry(t, reread).GetFlow("kycProcess")
+	require.NoError(t, err)
+
+	assert.Equal(t, withoutSource(flow), withoutSource(rereadFlow))
+}
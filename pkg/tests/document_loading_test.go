@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// singleStepFlowXml returns a document holding one flow with a single ending step
+func singleStepFlowXml(flowID string, imports ...string) string {
+	doc := "<cdsl>"
+	for _, href := range imports {
+		doc += `<import href="` + href + `"/>`
+	}
+	return doc + `<flow id="` + flowID + `" defaultStep="init"><step id="init"><endRoute/></step></flow></cdsl>`
+}
+
+// TestLoadDirectoryWithImports tests that a directory of documents sharing an import loads once per flow
+func TestLoadDirectoryWithImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"flows/onboarding.xml":    {Data: []byte(singleStepFlowXml("onboarding", "shared/common.xml"))},
+		"flows/renewal.xml":       {Data: []byte(singleStepFlowXml("renewal", "shared/common.xml"))},
+		"flows/shared/common.xml": {Data: []byte(singleStepFlowXml("common"))},
+		"other/ignored.xml":       {Data: []byte(singleStepFlowXml("ignored"))},
+	}
+
+	source := definitionsource.NewFSDefinitionSource(fsys)
+	docs, err := source.LoadGlob("flows/*.xml")
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Same(t, docs[0].Imported[0], docs[1].Imported[0])
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocuments(docs))
+
+	for _, flowID := range []string{"onboarding", "renewal", "common"} {
+		flow, err := flowRegistry.GetFlow(flowID)
+		require.NoError(t, err)
+		assert.NotNil(t, flow, flowID)
+	}
+	flow, _ := flowRegistry.GetFlow("ignored")
+	assert.Nil(t, flow)
+
+	docs, err = source.LoadDir("flows")
+	require.NoError(t, err)
+	assert.Len(t, docs, 3)
+}
+
+// TestImportCycleIsRejected tests that documents importing each other fail to load
+func TestImportCycleIsRejected(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.xml": {Data: []byte(singleStepFlowXml("a", "b.xml"))},
+		"b.xml": {Data: []byte(singleStepFlowXml("b", "a.xml"))},
+	}
+
+	_, err := definitionsource.NewFSDefinitionSource(fsys).LoadDocument("a.xml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a.xml -> b.xml -> a.xml")
+}
+
+// TestDuplicateFlowIdReportsBothSources tests that a flow ID defined twice is rejected
+func TestDuplicateFlowIdReportsBothSources(t *testing.T) {
+	fsys := fstest.MapFS{
+		"first.xml":  {Data: []byte(singleStepFlowXml("kyc"))},
+		"second.xml": {Data: []byte(singleStepFlowXml("kyc"))},
+	}
+
+	docs, err := definitionsource.NewFSDefinitionSource(fsys).LoadAll()
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	loader := registry.NewRegistryLoader(registry.NewInMemoryFlowRegistry(), dslInitHelper)
+
+	err = loader.LoadDocuments(docs)
+	var dupErr *exceptions.CdslDuplicateFlowError
+	require.True(t, errors.As(err, &dupErr))
+	assert.Equal(t, "kyc", dupErr.FlowID)
+	assert.Equal(t, "first.xml", dupErr.ExistingSource)
+	assert.Equal(t, "second.xml", dupErr.DuplicateSource)
+
+	// Reloading the original source is not a duplicate
+	assert.NoError(t, loader.LoadDocument(docs[0]))
+}
+
+// TestDuplicateFlowIdInUnnamedDocuments tests that documents without a source cannot replace each other's flows
+func TestDuplicateFlowIdInUnnamedDocuments(t *testing.T) {
+	parser := definitionsource.XmlDocumentParser{}
+	first, err := parser.ParseDocument("", strings.NewReader(singleStepFlowXml("kyc")))
+	require.NoError(t, err)
+	second, err := parser.ParseDocument("", strings.NewReader(singleStepFlowXml("kyc")))
+	require.NoError(t, err)
+	require.Equal(t, "", first.Source)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+
+	err = registry.NewRegistryLoader(registry.NewInMemoryFlowRegistry(), dslInitHelper).LoadDocuments([]*definitionsource.DocumentDefinition{first, second})
+	var dupErr *exceptions.CdslDuplicateFlowError
+	require.ErrorAs(t, err, &dupErr)
+	assert.Equal(t, "<unnamed document>", dupErr.ExistingSource)
+	assert.Equal(t, "<unnamed document>", dupErr.DuplicateSource)
+
+	loader := registry.NewRegistryLoader(registry.NewInMemoryFlowRegistry(), dslInitHelper)
+	require.NoError(t, loader.LoadDocument(first))
+	require.ErrorAs(t, loader.LoadDocument(second), &dupErr)
+
+	// Reloading the same document is not a duplicate
+	assert.NoError(t, loader.LoadDocument(first))
+}
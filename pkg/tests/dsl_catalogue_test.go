@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookDsl is a DSL without a typed model, described when it is registered
+type webhookDsl struct {
+	dsl.DslSupport
+}
+
+// Execute implements Dsl
+func (d *webhookDsl) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	return nil, nil
+}
+
+// catalogueHelper registers the test DSLs, await and a described webhook DSL
+func catalogueHelper(t *testing.T) *registry.DslInitialisationHelper {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	dslInitHelper.RegisterDescribedDsl("webhook", func() dsl.Dsl { return &webhookDsl{} }, dsl.DslDescriptor{
+		ElementDescriptor: dsl.ElementDescriptor{
+			Description: "Calls a webhook",
+			Attributes: []dsl.AttributeDescriptor{
+				{Name: "url", Type: dsl.AttributeString, Required: true},
+				{Name: "retries", Type: dsl.AttributeInt},
+			},
+		},
+	})
+	return dslInitHelper
+}
+
+// TestCatalogueDescribesRegisteredDsls tests that the catalogue describes DSLs from their models and descriptors
+func TestCatalogueDescribesRegisteredDsls(t *testing.T) {
+	dslInitHelper := catalogueHelper(t)
+
+	risk, ok := dslInitHelper.Descriptor("riskAssessment")
+	require.True(t, ok)
+	assert.Equal(t, "Assesses the risk level of a customer", risk.Description)
+	age, ok := risk.Attribute("customerAge")
+	require.True(t, ok)
+	assert.Equal(t, dsl.AttributeInt, age.Type)
+	assert.Equal(t, "30", age.Default)
+	assert.False(t, age.Required)
+
+	aml, _ := dslInitHelper.Descriptor("amlCheck")
+	level, _ := aml.Attribute("checkLevel")
+	assert.Equal(t, []string{"standard", "enhanced"}, level.AllowedValues)
+
+	sanctions, _ := dslInitHelper.Descriptor("sanctionsCheck")
+	list, ok := sanctions.Child("list")
+	require.True(t, ok)
+	assert.True(t, list.Multiple)
+	name, _ := list.Attribute("name")
+	assert.True(t, name.Required)
+
+	routeTo, _ := dslInitHelper.Descriptor("routeTo")
+	await, _ := dslInitHelper.Descriptor("await")
+	endRoute, _ := dslInitHelper.Descriptor("endRoute")
+	assert.True(t, routeTo.Routes)
+	assert.True(t, await.Awaits)
+	assert.True(t, endRoute.Ends)
+
+	webhook, ok := dslInitHelper.Descriptor("webhook")
+	require.True(t, ok)
+	assert.Equal(t, "webhook", webhook.Name)
+
+	var names []string
+	for _, descriptor := range dslInitHelper.Catalogue() {
+		names = append(names, descriptor.QualifiedName())
+	}
+	assert.Equal(t, []string{
+		"core:await", "core:endRoute", "core:routeTo", "core:setState", "core:setVar",
+		"kyc:amlCheck", "kyc:collectCustomerInfo", "kyc:documentVerification", "kyc:finalDecision",
+		"kyc:riskAssessment", "kyc:sanctionsCheck", "kyc:validateCustomerInfo", "webhook",
+	}, names)
+}
+
+// TestValidatorChecksAttributesAgainstCatalogue tests that unknown, missing and mistyped attributes are rejected
+func TestValidatorChecksAttributesAgainstCatalogue(t *testing.T) {
+	flow, err := registry.NewFlowBuilder("attributes").
+		DefaultStep("start").
+		Step("start",
+			registry.Elem("riskAssessment", "custmerAge", "35"),
+			registry.Elem("webhook", "retries", "3"),
+			registry.Elem("webhook", "url", "https://example.com", "retries", "many"),
+			registry.Elem("sanctionsCheck").Children(registry.Elem("rule")),
+			registry.Elem("endRoute", "reason", "done"),
+		).
+		Build()
+	require.NoError(t, err)
+
+	validator := registry.NewRegistryValidator(registry.NewInMemoryFlowRegistry(), catalogueHelper(t))
+	errs := validator.AnalyseFlow(flow).Errors()
+	require.Len(t, errs, 5)
+	assert.Contains(t, errs[0].String(), "Element riskAssessment does not accept attribute custmerAge, expected one of countryCode, customerAge, transactionValue")
+	assert.Contains(t, errs[1].String(), "Element webhook requires attribute url")
+	assert.Contains(t, errs[2].String(), `Attribute retries of element webhook is "many", expected an integer`)
+	assert.Contains(t, errs[3].String(), "Element sanctionsCheck does not accept a <rule> element")
+	assert.Contains(t, errs[4].String(), "Element endRoute does not accept attribute reason, expected no attributes")
+}
+
+// TestGeneratedXsdDeclaresCatalogue tests that the XML Schema declares each DSL element and its attributes
+func TestGeneratedXsdDeclaresCatalogue(t *testing.T) {
+	xsd := registry.GenerateXsd(catalogueHelper(t).Catalogue())
+
+	declared := make(map[string]bool)
+	var enumerations []string
+	decoder := xml.NewDecoder(bytes.NewReader(xsd))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if start, ok := token.(xml.StartElement); ok {
+			for _, attr := range start.Attr {
+				if start.Name.Local == "element" && attr.Name.Local == "name" {
+					declared[attr.Value] = true
+				}
+				if start.Name.Local == "enumeration" {
+					enumerations = append(enumerations, attr.Value)
+				}
+			}
+		}
+	}
+
+	for _, name := range []string{"cdsl", "flow", "step", "finally", "fragment", "useFragment", "riskAssessment", "sanctionsCheck", "list", "webhook"} {
+		assert.True(t, declared[name], "element %s is not declared", name)
+	}
+	assert.Contains(t, enumerations, "enhanced")
+	assert.Contains(t, string(xsd), `<xs:element name="riskAssessment" type="kyc.riskAssessmentElement"/>`)
+	assert.Contains(t, string(xsd), `<xs:complexType name="kyc.riskAssessmentElement">`)
+	assert.Contains(t, string(xsd), `<xs:attribute name="customerAge" type="intValue">`)
+	assert.Contains(t, string(xsd), `<xs:attribute name="url" type="xs:string" use="required"/>`)
+}
+
+// TestGeneratedJsonSchemaCoversKycFlow tests that the JSON Schema defines every element the KYC flow uses
+func TestGeneratedJsonSchemaCoversKycFlow(t *testing.T) {
+	data, err := registry.GenerateJsonSchema(catalogueHelper(t).Catalogue())
+	require.NoError(t, err)
+
+	var schema struct {
+		Defs map[string]struct {
+			Properties struct {
+				Name struct {
+					Enum []string `json:"enum"`
+				} `json:"name"`
+				Attributes struct {
+					Properties map[string]map[string]interface{} `json:"properties"`
+					Required   []string                          `json:"required"`
+				} `json:"attributes"`
+			} `json:"properties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+
+	setVar := schema.Defs["dsl.core:setVar"]
+	assert.Equal(t, []string{"name", "val"}, setVar.Properties.Attributes.Required)
+	assert.Equal(t, []string{"core:setVar", "setVar"}, setVar.Properties.Name.Enum)
+	assert.Contains(t, schema.Defs["dsl.kyc:amlCheck"].Properties.Attributes.Properties["checkLevel"], "anyOf")
+
+	named := make(map[string]string)
+	for key, def := range schema.Defs {
+		for _, name := range def.Properties.Name.Enum {
+			named[name] = key
+		}
+	}
+
+	flowJson, err := os.ReadFile(filepath.Join("..", "..", "resources", "kyc-flow.json"))
+	require.NoError(t, err)
+	var doc struct {
+		Flows map[string]struct {
+			Steps map[string]struct {
+				Elements []struct {
+					Name       string            `json:"name"`
+					Attributes map[string]string `json:"attributes"`
+				} `json:"elements"`
+			} `json:"steps"`
+		} `json:"flows"`
+	}
+	require.NoError(t, json.Unmarshal(flowJson, &doc))
+
+	for _, step := range doc.Flows["kycProcess"].Steps {
+		for _, elem := range step.Elements {
+			key, ok := named[elem.Name]
+			require.True(t, ok, "element %s has no schema", elem.Name)
+			def := schema.Defs[key]
+			for attr := range elem.Attributes {
+				assert.Contains(t, def.Properties.Attributes.Properties, attr)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixedFlowXml names DSLs with and without their library prefix
+const prefixedFlowXml = `<cdsl xmlns:kyc="urn:cdsl:kyc">
+    <flow id="prefixed" defaultStep="init">
+        <step id="init">
+            <core:setState val="Alive"/>
+            <kyc:amlCheck checkLevel="enhanced"/>
+            <sanctionsCheck/>
+            <routeTo target="end"/>
+        </step>
+        <step id="end">
+            <core:endRoute/>
+        </step>
+    </flow>
+</cdsl>`
+
+// TestPrefixedElementsResolveToLibraries tests that elements written with a namespace prefix, declared or not,
+// load and execute the DSL of that library
+func TestPrefixedElementsResolveToLibraries(t *testing.T) {
+	doc, err := definitionsource.NewFSDefinitionSource(fstest.MapFS{
+		"prefixed.xml": {Data: []byte(prefixedFlowXml)},
+	}).LoadDocument("prefixed.xml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"kyc": "urn:cdsl:kyc"}, doc.Namespaces)
+
+	init := doc.Flows["prefixed"].Steps["init"]
+	assert.Equal(t, "core:setState", init.Elements[0].Name)
+	assert.Equal(t, "kyc:amlCheck", init.Elements[1].Name)
+	assert.Equal(t, "sanctionsCheck", init.Elements[2].Name)
+
+	var written bytes.Buffer
+	require.NoError(t, definitionsource.XmlDocumentWriter{}.WriteDocument(&written, doc))
+	assert.Contains(t, written.String(), `<cdsl xmlns:kyc="urn:cdsl:kyc">`)
+	assert.Contains(t, written.String(), `<kyc:amlCheck checkLevel="enhanced"/>`)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocument(doc))
+
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = context.NewCdslContextRepositoryUnitTestSupport()
+
+	flow, err := flowRegistry.GetFlow("prefixed")
+	require.NoError(t, err)
+	output, err := executor.Execute(flow, types.NewCdslInputEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "true", output.OutputValues["amlCheckPassed"].Value)
+	assert.Equal(t, "true", output.OutputValues["sanctionsCheckPassed"].Value)
+}
+
+// TestLibraryNameConflicts tests that a DSL name can only be claimed once, by one library or outside any library,
+// and that a namespace can only be registered once
+func TestLibraryNameConflicts(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	require.NoError(t, dslInitHelper.RegisterDsl("pep", func() dsl.Dsl { return &dsl.AmlCheck{} }))
+
+	err := dslInitHelper.RegisterLibrary(dsl.NewDslLibrary("fincrime").
+		Add("amlCheck", func() dsl.Dsl { return &dsl.AmlCheck{} }).
+		Add("pep", func() dsl.Dsl { return &dsl.AmlCheck{} }).
+		Add("fraudCheck", func() dsl.Dsl { return &dsl.AmlCheck{} }))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DSL amlCheck of library fincrime is already defined as kyc:amlCheck")
+	assert.Contains(t, err.Error(), "DSL pep of library fincrime is already registered outside a library")
+	_, err = dslInitHelper.ResolveName("fincrime:fraudCheck")
+	assert.ErrorContains(t, err, "DSL fincrime:fraudCheck names unknown library fincrime")
+
+	err = dslInitHelper.RegisterDsl("amlCheck", func() dsl.Dsl { return &dsl.AmlCheck{} })
+	assert.ErrorContains(t, err, "DSL amlCheck would hide library DSL kyc:amlCheck")
+	err = dslInitHelper.RegisterDsl("kyc:amlCheck", func() dsl.Dsl { return &dsl.AmlCheck{} })
+	assert.ErrorContains(t, err, "DSL kyc:amlCheck has a namespace prefix, register it in a DSL library")
+	err = registry.RegisterFunc(dslInitHelper, "core:setVar", func(ec *dsl.ExecContext, m sleepModel) (*types.CdslOutputEvent, error) {
+		return nil, nil
+	})
+	assert.Error(t, err)
+	assert.IsType(t, &dsl.AmlCheck{}, dslInitHelper.Resolve(types.DslMetadata{Name: "kyc:amlCheck"}))
+
+	err = dslInitHelper.RegisterLibrary(dsl.NewDslLibrary("kyc").Add("kycPep", func() dsl.Dsl { return &dsl.AmlCheck{} }))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DSL library kyc is already registered")
+
+	err = dslInitHelper.RegisterLibrary(dsl.NewDslLibrary("bad:name"))
+	require.Error(t, err)
+
+	resolved, err := dslInitHelper.ResolveName("amlCheck")
+	require.NoError(t, err)
+	assert.Equal(t, "kyc:amlCheck", resolved)
+
+	_, err = dslInitHelper.ResolveName("kyc:pep")
+	assert.ErrorContains(t, err, "DSL library kyc has no DSL pep")
+	_, err = dslInitHelper.ResolveName("aml:check")
+	assert.ErrorContains(t, err, "DSL aml:check names unknown library aml")
+}
+
+// TestCoreLibrary tests that the core library provides the DSLs every flow needs
+func TestCoreLibrary(t *testing.T) {
+	var names []string
+	for _, entry := range dsl.CoreLibrary().Entries() {
+		names = append(names, entry.Name)
+	}
+	assert.Equal(t, []string{"setVar", "setState", "routeTo", "await", "endRoute"}, names)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	require.NoError(t, dslInitHelper.RegisterLibrary(dsl.CoreLibrary()))
+	await, ok := dslInitHelper.Descriptor("await")
+	require.True(t, ok)
+	assert.Equal(t, "core:await", await.QualifiedName())
+	assert.True(t, await.Awaits)
+	assert.IsType(t, &dsl.RouteTo{}, dslInitHelper.Resolve(types.DslMetadata{Name: "core:routeTo"}))
+}
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	gocontext "context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cancelModel is the model of the cancel function DSL
+type cancelModel struct{}
+
+// blockingScreener is a sanctions screener that waits until its screening is canceled
+type blockingScreener struct{}
+
+// Screen implements dsl.SanctionsScreener
+func (s *blockingScreener) Screen(request dsl.SanctionsScreeningRequest) (bool, error) {
+	return true, nil
+}
+
+// ScreenContext implements dsl.ContextSanctionsScreener
+func (s *blockingScreener) ScreenContext(goCtx gocontext.Context, request dsl.SanctionsScreeningRequest) (bool, error) {
+	<-goCtx.Done()
+	return false, goCtx.Err()
+}
+
+// TestExecutionStopsBetweenElements tests that a canceled execution saves its context at the interrupted step,
+// releases its lock and resumes from that step
+func TestExecutionStopsBetweenElements(t *testing.T) {
+	goCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	registry.RegisterFunc(dslInitHelper, "cancel", func(ec *dsl.ExecContext, m cancelModel) (*types.CdslOutputEvent, error) {
+		cancel()
+		return nil, nil
+	})
+
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	_, err := registry.NewFlowBuilder("cancellable").
+		DefaultStep("init").
+		ErrorStep("error").
+		Step("init",
+			registry.Elem("setVar", "name", "started", "val", "true"),
+			registry.Elem("routeTo", "target", "work"),
+		).
+		Step("work",
+			registry.Elem("cancel"),
+			registry.Elem("setVar", "name", "worked", "val", "true"),
+			registry.Elem("endRoute"),
+		).
+		Step("error",
+			registry.Elem("setVar", "name", "failed", "val", "true"),
+			registry.Elem("endRoute"),
+		).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	contexts := context.NewCdslContextRepositoryUnitTestSupport()
+	executor.ContextRepository = contexts
+
+	_, err = executor.ExecuteFlowContext(goCtx, "cancellable", types.NewCdslInputEvent())
+	var canceled *exceptions.CdslCanceledError
+	require.ErrorAs(t, err, &canceled)
+	assert.ErrorIs(t, err, gocontext.Canceled)
+	assert.Equal(t, "work", canceled.StepID)
+	assert.Contains(t, err.Error(), "Execution of flow cancellable was canceled in step work of context "+canceled.ContextID)
+
+	saved, err := contexts.GetContext("", canceled.ContextID)
+	require.NoError(t, err)
+	assert.Equal(t, "work", saved.CurrentStep)
+	assert.Equal(t, "true", saved.GetVar("started"))
+	assert.Equal(t, "", saved.GetVar("worked"))
+	assert.Equal(t, "", saved.GetVar("failed"))
+
+	input := types.NewCdslInputEvent()
+	input.ContextID = canceled.ContextID
+	output, err := executor.ExecuteFlow("cancellable", input)
+	require.NoError(t, err)
+	assert.Equal(t, "true", output.OutputValues["worked"].Value)
+	assert.Equal(t, string(context.StateEnd), output.ContextState)
+}
+
+// TestResumedStepsAreNotCountedAgain tests that restarting an interrupted step does not count as a transition
+// into it against maxTransitions, or repeat it in the transition history
+func TestResumedStepsAreNotCountedAgain(t *testing.T) {
+	goCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	require.NoError(t, registry.RegisterFunc(dslInitHelper, "cancel", func(ec *dsl.ExecContext, m cancelModel) (*types.CdslOutputEvent, error) {
+		cancel()
+		return nil, nil
+	}))
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	_, err := registry.NewFlowBuilder("resumable").
+		DefaultStep("init").
+		Step("init", registry.Elem("routeTo", "target", "work")).
+		Step("work", registry.Elem("cancel"), registry.Elem("routeTo", "target", "done")).
+		Step("done", registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	contexts := context.NewCdslContextRepositoryUnitTestSupport()
+	executor.ContextRepository = contexts
+	_, err = executor.ExecuteFlowContext(goCtx, "resumable", types.NewCdslInputEvent())
+	var canceled *exceptions.CdslCanceledError
+	require.ErrorAs(t, err, &canceled)
+
+	executor.Limits = model.ExecutionLimits{MaxTransitions: 1}
+	input := types.NewCdslInputEvent()
+	input.ContextID = canceled.ContextID
+	output, err := executor.ExecuteFlow("resumable", input)
+	require.NoError(t, err)
+	assert.Equal(t, string(context.StateEnd), output.ContextState)
+
+	saved, err := contexts.GetContext("", canceled.ContextID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"resumable/init", "resumable/work", "resumable/done"}, saved.Transitions)
+	assert.False(t, saved.Interrupted)
+}
+
+// TestDeadlinesReachContextDsls tests that a DSL waiting on an external service is stopped by the deadline
+// of the execution
+func TestDeadlinesReachContextDsls(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	require.NoError(t, dslInitHelper.Services().Register("screener", &blockingScreener{}))
+	require.NoError(t, dslInitHelper.Init())
+
+	doc, err := definitionsource.NewXmlDomDefinitionSource(filepath.Join("..", "..", "resources")).LoadDocument("kyc-flow.xml")
+	require.NoError(t, err)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocument(doc))
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+
+	goCtx, cancel := gocontext.WithTimeout(gocontext.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = executor.ExecuteFlowContext(goCtx, "kycProcess", types.NewCdslInputEvent())
+
+	var exceeded *exceptions.CdslDeadlineExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.ErrorIs(t, err, gocontext.DeadlineExceeded)
+	assert.Equal(t, "checkSanctionsList", exceeded.StepID)
+	assert.Equal(t, "kycProcess", exceeded.FlowID)
+}
+
+// TestDoneContextsAreNotExecuted tests that nothing is created for an execution canceled before it starts
+func TestDoneContextsAreNotExecuted(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	flow, err := registry.NewFlowBuilder("never").
+		DefaultStep("init").
+		Step("init", registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+
+	goCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	output, err := executor.ExecuteContext(goCtx, flow, types.NewCdslInputEvent())
+	assert.Nil(t, output)
+	var canceled *exceptions.CdslCanceledError
+	require.ErrorAs(t, err, &canceled)
+	assert.Equal(t, "", canceled.ContextID)
+	assert.EqualError(t, err, "Execution of flow never was canceled before it started: context canceled")
+}
+
+// retryingLockProvider is a lock provider that does not take a context.Context and spends retryMaxDuration
+// retrying before it rejects a lock
+type retryingLockProvider struct {
+	retryMaxDuration time.Duration
+}
+
+// Obtain implements LockProvider
+func (p *retryingLockProvider) Obtain(owner string, resource string, duration time.Duration, retries int, retryMaxDuration time.Duration) (*concurrency.Lock, error) {
+	p.retryMaxDuration = retryMaxDuration
+	time.Sleep(retryMaxDuration)
+	return nil, concurrency.NewLockRejectedException(resource, owner, "Resource is already locked")
+}
+
+// Release implements LockProvider
+func (p *retryingLockProvider) Release(lock *concurrency.Lock) error {
+	return nil
+}
+
+// cancelingRepository is a context repository that cancels the execution while it loads a context
+type cancelingRepository struct {
+	*context.CdslContextRepositoryUnitTestSupport
+	cancel gocontext.CancelFunc
+}
+
+// GetContext implements CdslContextRepository
+func (r *cancelingRepository) GetContext(transactionID string, contextID string) (*context.CdslContext, error) {
+	r.cancel()
+	return r.CdslContextRepositoryUnitTestSupport.GetContext(transactionID, contextID)
+}
+
+// TestLockingAndLoadingHonourContexts tests that lock retries are bounded by the deadline of the execution and
+// that an execution canceled while its context is loaded stops before its first step and releases its lock
+func TestLockingAndLoadingHonourContexts(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	flow, err := registry.NewFlowBuilder("locked").
+		DefaultStep("init").
+		Step("init", registry.Elem("setVar", "name", "ran", "val", "true"), registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	locks := &retryingLockProvider{}
+	executor.LockProvider = locks
+	goCtx, cancel := gocontext.WithTimeout(gocontext.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err = executor.ExecuteContext(goCtx, flow, types.NewCdslInputEvent())
+	require.Error(t, err)
+	assert.Less(t, time.Since(started), executor.LockRetryMaxDuration)
+	assert.LessOrEqual(t, locks.retryMaxDuration, 20*time.Millisecond)
+
+	lockProvider := concurrency.NewLockProviderUnitTestSupport()
+	executor.LockProvider = lockProvider
+	output, err := executor.ExecuteFlow("locked", types.NewCdslInputEvent())
+	require.NoError(t, err)
+	contextID := output.ContextID
+
+	goCtx, cancel = gocontext.WithCancel(gocontext.Background())
+	repository := &cancelingRepository{CdslContextRepositoryUnitTestSupport: context.NewCdslContextRepositoryUnitTestSupport(), cancel: cancel}
+	executor.ContextRepository = repository
+	input := types.NewCdslInputEvent()
+	input.ContextID = contextID
+	_, err = executor.ExecuteContext(goCtx, flow, input)
+	var canceled *exceptions.CdslCanceledError
+	require.ErrorAs(t, err, &canceled)
+	assert.Equal(t, "", canceled.StepID)
+
+	lock, err := lockProvider.Obtain("test", "context/"+contextID, time.Second, 0, 0)
+	require.NoError(t, err)
+	assert.NotNil(t, lock)
+}
+
+// TestDslsAreAdaptedToContexts tests that DSLs without ExecuteContext check the context before executing
+func TestDslsAreAdaptedToContexts(t *testing.T) {
+	sanctionsCheck := &dsl.SanctionsCheck{}
+	assert.Same(t, sanctionsCheck, dsl.WithContext(sanctionsCheck))
+
+	adapted := dsl.WithContext(&dsl.SetVar{})
+	goCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	ctx := context.NewCdslContext()
+	runtime := context.NewCdslRuntime()
+	runtime.SetAuditor(context.NewCdslContextAuditorUnitTestSupport())
+	ctx.SetRuntime(runtime)
+	model := &dsl.SetVarModel{Name: "skipped", Val: "true"}
+
+	_, err := adapted.ExecuteContext(goCtx, runtime, ctx, model, types.NewCdslInputEvent())
+	assert.True(t, errors.Is(err, gocontext.Canceled))
+	assert.Equal(t, "", ctx.GetVar("skipped"))
+
+	_, err = adapted.ExecuteContext(gocontext.Background(), runtime, ctx, model, types.NewCdslInputEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "true", ctx.GetVar("skipped"))
+}
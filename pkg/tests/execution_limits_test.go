@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// limitedFlowXml defines a flow whose steps route to each other forever
+const limitedFlowXml = `<cdsl>
+    <flow id="pingPong" defaultStep="ping" maxTransitions="10" maxDuration="2s">
+        <step id="ping">
+            <routeTo target="pong"/>
+        </step>
+        <step id="pong">
+            <routeTo target="ping"/>
+        </step>
+    </flow>
+    <flow id="recoveringPingPong" extends="pingPong" errorStep="recover" maxElements="4">
+        <step id="recover">
+            <setVar name="recovered" val="true"/>
+            <endRoute/>
+        </step>
+    </flow>
+</cdsl>`
+
+// sleepModel is the model of the sleep function DSL
+type sleepModel struct{}
+
+// loopingExecutor loads limitedFlowXml and returns an executor for it
+func loopingExecutor(t *testing.T) *execution.FlowExecutor {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+
+	doc, err := definitionsource.XmlDocumentParser{}.ParseDocument("limits.xml", strings.NewReader(limitedFlowXml))
+	require.NoError(t, err)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocument(doc))
+	return newPlanExecutor(flowRegistry, dslInitHelper)
+}
+
+// TestRoutingLoopsAreStopped tests that a loop is stopped by the executor's limits and reported with its cycle.
+// The flow is registered without validation, which would reject the loop.
+func TestRoutingLoopsAreStopped(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	flow, err := registry.NewFlowBuilder("loop").
+		DefaultStep("a").
+		Step("a", registry.Elem("routeTo", "target", "b")).
+		Step("b", registry.Elem("routeTo", "target", "a")).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, flowRegistry.RegisterFlow(flow))
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	assert.Equal(t, execution.DefaultExecutionLimits, executor.Limits)
+
+	_, err = executor.ExecuteFlow("loop", types.NewCdslInputEvent())
+	var limitErr *exceptions.CdslExecutionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "maxTransitions", limitErr.Limit)
+	assert.Equal(t, "a", limitErr.StepID)
+	assert.Equal(t, []string{"loop/b", "loop/a"}, limitErr.Cycle)
+	assert.Contains(t, err.Error(), "Execution of flow loop exceeded maxTransitions of 1000 in step a of context "+limitErr.ContextID)
+	assert.Contains(t, err.Error(), "repeating loop/b -> loop/a -> loop/b")
+
+	executor.Limits = model.ExecutionLimits{MaxElements: 7}
+	_, err = executor.ExecuteFlow("loop", types.NewCdslInputEvent())
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "maxElements", limitErr.Limit)
+	assert.Equal(t, "b", limitErr.StepID)
+}
+
+// TestFlowLimitsRouteToTheErrorStep tests that flow limits override the executor's and that an exceeded limit
+// is handled by the error step
+func TestFlowLimitsRouteToTheErrorStep(t *testing.T) {
+	executor := loopingExecutor(t)
+
+	_, err := executor.ExecuteFlow("pingPong", types.NewCdslInputEvent())
+	var limitErr *exceptions.CdslExecutionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "maxTransitions", limitErr.Limit)
+	assert.Equal(t, []string{"pingPong/pong", "pingPong/ping"}, limitErr.Cycle)
+
+	output, err := executor.ExecuteFlow("recoveringPingPong", types.NewCdslInputEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "true", output.OutputValues["recovered"].Value)
+	assert.Equal(t, string(context.StateEnd), output.ContextState)
+}
+
+// TestErrorStepsCannotEscapeLimits tests that an error step handling an exceeded limit may not route elsewhere
+func TestErrorStepsCannotEscapeLimits(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	registry.RegisterFunc(dslInitHelper, "sleep", func(ec *dsl.ExecContext, m sleepModel) (*types.CdslOutputEvent, error) {
+		time.Sleep(5 * time.Millisecond)
+		return nil, nil
+	})
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	_, err := registry.NewFlowBuilder("slow").
+		DefaultStep("work").
+		ErrorStep("retry").
+		Limits(model.ExecutionLimits{MaxDuration: time.Millisecond}).
+		Step("work", registry.Elem("sleep"), registry.Elem("sleep"), registry.Elem("endRoute")).
+		Step("retry", registry.Elem("routeTo", "target", "work")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	_, err = newPlanExecutor(flowRegistry, dslInitHelper).ExecuteFlow("slow", types.NewCdslInputEvent())
+	var limitErr *exceptions.CdslExecutionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "maxDuration", limitErr.Limit)
+	assert.Equal(t, "work", limitErr.StepID)
+	assert.Contains(t, err.Error(), "exceeded maxDuration of 1ms")
+}
+
+// TestMaxDurationStopsRunningElements tests that a DSL waiting on its context.Context is stopped by the
+// flow's maxDuration, which is handled by the error step rather than suspending the execution
+func TestMaxDurationStopsRunningElements(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	require.NoError(t, registry.RegisterFunc(dslInitHelper, "wait", func(ec *dsl.ExecContext, m sleepModel) (*types.CdslOutputEvent, error) {
+		<-ec.Context().Done()
+		return nil, ec.Context().Err()
+	}))
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	builder := func(id string, errorStep string) *registry.FlowBuilder {
+		return registry.NewFlowBuilder(id).
+			DefaultStep("work").
+			ErrorStep(errorStep).
+			Limits(model.ExecutionLimits{MaxDuration: 10 * time.Millisecond}).
+			Step("work", registry.Elem("wait"), registry.Elem("endRoute")).
+			Step("recover", registry.Elem("setVar", "name", "recovered", "val", "true"), registry.Elem("endRoute"))
+	}
+	_, err := builder("waiting", "").BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+	_, err = builder("recoveringWaiting", "recover").BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+
+	_, err = executor.ExecuteFlow("waiting", types.NewCdslInputEvent())
+	var limitErr *exceptions.CdslExecutionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "maxDuration", limitErr.Limit)
+	assert.Equal(t, "work", limitErr.StepID)
+	var exceeded *exceptions.CdslDeadlineExceededError
+	assert.False(t, errors.As(err, &exceeded))
+
+	output, err := executor.ExecuteFlow("recoveringWaiting", types.NewCdslInputEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "true", output.OutputValues["recovered"].Value)
+	assert.Equal(t, string(context.StateEnd), output.ContextState)
+}
+
+// TestFlowLimitsAreDefined tests that limits are read, inherited, written and validated
+func TestFlowLimitsAreDefined(t *testing.T) {
+	doc, err := definitionsource.XmlDocumentParser{}.ParseDocument("limits.xml", strings.NewReader(limitedFlowXml))
+	require.NoError(t, err)
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocument(doc))
+
+	recovering, err := flowRegistry.GetFlow("recoveringPingPong")
+	require.NoError(t, err)
+	assert.Equal(t, model.ExecutionLimits{MaxTransitions: 10, MaxElements: 4, MaxDuration: 2 * time.Second}, recovering.Limits)
+
+	flowDef, err := registry.FlowDefinitionOf(recovering)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, definitionsource.XmlDocumentWriter{}.WriteDocument(&buf, &definitionsource.DocumentDefinition{
+		Flows: map[string]*definitionsource.FlowDefinition{flowDef.ID: flowDef},
+	}))
+	assert.Contains(t, buf.String(), `maxTransitions="10" maxElements="4" maxDuration="2s"`)
+
+	// A limit of 0 falls back to the executor's, so the schemas allow it
+	xsd := registry.GenerateXsd(dslInitHelper.Catalogue())
+	assert.Contains(t, string(xsd), `<xs:attribute name="maxTransitions" type="xs:nonNegativeInteger"/>`)
+	data, err := registry.GenerateJsonSchema(dslInitHelper.Catalogue())
+	require.NoError(t, err)
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]map[string]interface{} `json:"properties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	assert.Equal(t, float64(0), schema.Defs["flow"].Properties["maxElements"]["minimum"])
+
+	_, err = definitionsource.XmlDocumentParser{}.ParseDocument("invalid.xml", strings.NewReader(`<cdsl><flow id="soon" maxDuration="soon"/></cdsl>`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `maxDuration "soon" of flow soon is not a positive duration`)
+
+	_, err = definitionsource.JsonDocumentParser{}.ParseDocument("invalid.json", strings.NewReader(`{"flows": {"negative": {"maxTransitions": -1, "steps": {}}}}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "maxTransitions of flow negative must not be negative")
+}
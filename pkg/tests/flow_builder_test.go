@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// helloWorldBuilder builds the flow defined in test-flow.xml
+func helloWorldBuilder() *registry.FlowBuilder {
+	return registry.NewFlowBuilder("shouldRunHelloWorldAndEndRoute").
+		DefaultStep("init").
+		ErrorStep("error").
+		Step("init",
+			registry.Elem("setState", "val", "Alive"),
+			registry.Elem("sayHello", "name", "Go"),
+			registry.Elem("setVar", "name", "myVar", "val", "myVal"),
+			registry.Elem("routeTo", "target", "end"),
+		).
+		Step("end", registry.Elem("endRoute")).
+		Finally(registry.Elem("setState", "val", "End"))
+}
+
+// TestFlowBuilderMatchesLoadedFlow tests that a flow built in code matches the same flow loaded from XML
+func TestFlowBuilderMatchesLoadedFlow(t *testing.T) {
+	doc, err := definitionsource.NewXmlDomDefinitionSource(filepath.Join("..", "..", "resources")).LoadDocument("test-flow.xml")
+	require.NoError(t, err)
+	loaded, err := loadIntoRegistry(t, doc).GetFlow("shouldRunHelloWorldAndEndRoute")
+	require.NoError(t, err)
+
+	built, err := helloWorldBuilder().Build()
+	require.NoError(t, err)
+	assert.Equal(t, withoutSource(loaded), withoutSource(built))
+}
+
+// TestFlowBuilderValidatesBeforeRegistering tests that BuildAndRegister only registers valid flows
+func TestFlowBuilderValidatesBeforeRegistering(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	dslInitHelper.RegisterDsl("sayHello", func() dsl.Dsl { return &dsl.SayHello{} })
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+
+	// test-flow.xml names an error step it does not define
+	_, err := helloWorldBuilder().BuildAndRegister(flowRegistry, dslInitHelper)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error step error does not exist")
+	assert.Contains(t, err.Error(), "flow_builder_test.go:")
+
+	flow, _ := flowRegistry.GetFlow("shouldRunHelloWorldAndEndRoute")
+	assert.Nil(t, flow)
+
+	built, err := helloWorldBuilder().
+		Step("error", registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	flow, err = flowRegistry.GetFlow("shouldRunHelloWorldAndEndRoute")
+	require.NoError(t, err)
+	assert.Same(t, built, flow)
+}
+
+// TestFlowBuilderNestedElements tests that nested elements build the same models as nested XML
+func TestFlowBuilderNestedElements(t *testing.T) {
+	built, err := registry.NewFlowBuilder("screening").
+		DefaultStep("init").
+		Step("init",
+			registry.Elem("sanctionsCheck", "checkType", "enhanced").Children(
+				registry.Elem("list", "name", "OFAC"),
+				registry.Elem("list", "name", "EU").Content("consolidated"),
+			),
+		).
+		Build()
+	require.NoError(t, err)
+
+	doc, err := definitionsource.XmlDocumentParser{}.ParseDocument("screening.xml", strings.NewReader(`<cdsl>
+    <flow id="screening" defaultStep="init">
+        <step id="init">
+            <sanctionsCheck checkType="enhanced">
+                <list name="OFAC"/>
+                <list name="EU">consolidated</list>
+            </sanctionsCheck>
+        </step>
+    </flow>
+</cdsl>`))
+	require.NoError(t, err)
+	loaded, err := loadIntoRegistry(t, doc).GetFlow("screening")
+	require.NoError(t, err)
+
+	assert.Equal(t, withoutSource(loaded), withoutSource(built))
+}
+
+// TestFlowBuilderErrors tests that mistakes in built flows are reported at the building code
+func TestFlowBuilderErrors(t *testing.T) {
+	_, err := registry.NewFlowBuilder("broken").
+		DefaultStep("init").
+		Step("init", registry.Elem("setVar", "name")).
+		Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "attribute without a value")
+	assert.Contains(t, err.Error(), "flow_builder_test.go:")
+
+	_, err = registry.NewFlowBuilder("broken").
+		Finally(registry.Elem("endRoute")).
+		Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Finally called before any step")
+
+	_, err = registry.NewFlowBuilder("broken").
+		DefaultStep("init").
+		Step("init", registry.Elem("endRoute")).
+		Step("init", registry.Elem("endRoute")).
+		Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Duplicate step id init")
+}
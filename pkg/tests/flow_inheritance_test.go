@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadInheritedFlows loads kyc-flow.xml alongside a document that extends it
+func loadInheritedFlows(t *testing.T, childXml string) (*registry.InMemoryFlowRegistry, error) {
+	parentXml, err := os.ReadFile(filepath.Join("..", "..", "resources", "kyc-flow.xml"))
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"kyc-flow.xml": {Data: parentXml},
+		"kyc-uk.xml":   {Data: []byte(childXml)},
+	}
+	docs, err := definitionsource.NewFSDefinitionSource(fsys).LoadAll()
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	return flowRegistry, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocuments(docs)
+}
+
+// TestFlowInheritsParentSteps tests that a child flow inherits steps and defaults and replaces overridden steps
+func TestFlowInheritsParentSteps(t *testing.T) {
+	flowRegistry, err := loadInheritedFlows(t, `<cdsl>
+    <flow id="kycUK" extends="kycProcess">
+        <step id="checkSanctionsList" override="true">
+            <setVar name="status" val="checking_uk_sanctions"/>
+            <sanctionsCheck checkType="enhanced"/>
+            <routeTo target="performAmlCheck"/>
+        </step>
+    </flow>
+</cdsl>`)
+	require.NoError(t, err)
+
+	parent, err := flowRegistry.GetFlow("kycProcess")
+	require.NoError(t, err)
+	child, err := flowRegistry.GetFlow("kycUK")
+	require.NoError(t, err)
+	require.NotNil(t, child)
+
+	assert.Equal(t, parent.DefaultStep, child.DefaultStep)
+	assert.Equal(t, parent.ErrorStep, child.ErrorStep)
+	assert.Len(t, child.Steps, len(parent.Steps))
+	assert.Equal(t, parent.Steps["finalDecision"], child.Steps["finalDecision"])
+	assert.NotSame(t, parent.Steps["finalDecision"], child.Steps["finalDecision"])
+
+	sanctions := child.Steps["checkSanctionsList"].LogicElements[1].Model.(*dsl.MapModel)
+	assert.Equal(t, "enhanced", sanctions.GetString("checkType"))
+	assert.Equal(t, "kyc-uk.xml", child.Steps["checkSanctionsList"].Position.File)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	assert.NoError(t, registry.NewRegistryValidator(flowRegistry, dslInitHelper).ValidateFlow(child))
+}
+
+// TestFlowInheritanceErrors tests that invalid overrides and inheritance cycles are rejected
+func TestFlowInheritanceErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		childXml string
+		expected string
+	}{
+		{
+			name:     "override of a missing step",
+			childXml: `<cdsl><flow id="kycUK" extends="kycProcess"><step id="extraChecks" override="true"><endRoute/></step></flow></cdsl>`,
+			expected: "overrides a step that does not exist",
+		},
+		{
+			name:     "redefinition without override",
+			childXml: `<cdsl><flow id="kycUK" extends="kycProcess"><step id="complete"><endRoute/></step></flow></cdsl>`,
+			expected: "without override",
+		},
+		{
+			name:     "unknown parent",
+			childXml: `<cdsl><flow id="kycUK" extends="kycMissing"><step id="init"><endRoute/></step></flow></cdsl>`,
+			expected: "extends unknown flow kycMissing",
+		},
+		{
+			name: "cycle",
+			childXml: `<cdsl>
+<flow id="a" extends="b"><step id="x"><endRoute/></step></flow>
+<flow id="b" extends="a"><step id="y"><endRoute/></step></flow>
+</cdsl>`,
+			expected: "a extends b extends a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flowRegistry, err := loadInheritedFlows(t, tt.childXml)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+			assert.Contains(t, err.Error(), "kyc-uk.xml:")
+
+			// Nothing is registered when any flow fails to resolve
+			flow, _ := flowRegistry.GetFlow("kycProcess")
+			assert.Nil(t, flow)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signingKey returns a fixed ed25519 key pair for tests
+func signingKey(seed byte) (ed25519.PublicKey, ed25519.PrivateKey) {
+	s := make([]byte, ed25519.SeedSize)
+	s[0] = seed
+	private := ed25519.NewKeyFromSeed(s)
+	return private.Public().(ed25519.PublicKey), private
+}
+
+// loadSigned loads every document in fsys requiring signatures from public
+func loadSigned(t *testing.T, fsys fstest.MapFS, public ed25519.PublicKey) (*registry.InMemoryFlowRegistry, error) {
+	docs, err := definitionsource.NewFSDefinitionSource(fsys).LoadAll()
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	loader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	loader.RequireSignatures(public)
+	return flowRegistry, loader.LoadDocuments(docs)
+}
+
+// TestSignedDocumentsAreVerified tests that only documents signed by a trusted key are loaded
+func TestSignedDocumentsAreVerified(t *testing.T) {
+	public, private := signingKey(0)
+	_, untrusted := signingKey(1)
+	data := []byte(singleStepFlowXml("kyc"))
+
+	tests := []struct {
+		name      string
+		signature []byte
+		expected  string
+	}{
+		{name: "base64 signature", signature: []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(private, data)) + "\n")},
+		{name: "raw signature", signature: ed25519.Sign(private, data)},
+		{name: "untrusted key", signature: ed25519.Sign(untrusted, data), expected: "Signature of document kyc.xml does not verify"},
+		{name: "other content", signature: ed25519.Sign(private, []byte("<cdsl/>")), expected: "does not verify"},
+		{name: "unsigned", expected: "Document kyc.xml is not signed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"kyc.xml": {Data: data}}
+			if tt.signature != nil {
+				fsys["kyc.xml.sig"] = &fstest.MapFile{Data: tt.signature}
+			}
+
+			flowRegistry, err := loadSigned(t, fsys, public)
+			flow, _ := flowRegistry.GetFlow("kyc")
+			if tt.expected != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expected)
+				assert.Nil(t, flow)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, flow)
+			assert.Equal(t, definitionsource.Checksum(data), flow.DocumentChecksum)
+		})
+	}
+}
+
+// TestFlowChecksumIsStampedIntoContext tests that contexts record the checksums of the flow that created them
+func TestFlowChecksumIsStampedIntoContext(t *testing.T) {
+	fsys := fstest.MapFS{
+		"kyc.xml":   {Data: []byte(singleStepFlowXml("kyc"))},
+		"kyc2.json": {Data: []byte(`{"flows": {"kyc2": {"defaultStep": "init", "steps": {"init": {"elements": [{"name": "endRoute"}]}}}}}`)},
+	}
+	flowRegistry, err := loadFragmentFlows(t, fsys)
+	require.NoError(t, err)
+
+	flow, err := flowRegistry.GetFlow("kyc")
+	require.NoError(t, err)
+	assert.Len(t, flow.Checksum, 64)
+	assert.Equal(t, definitionsource.Checksum(fsys["kyc.xml"].Data), flow.DocumentChecksum)
+
+	// The same flow content has the same checksum whatever its format
+	jsonFlow, err := flowRegistry.GetFlow("kyc2")
+	require.NoError(t, err)
+	jsonFlow.ID = "kyc"
+	checksum, err := registry.FlowChecksum(jsonFlow)
+	require.NoError(t, err)
+	assert.Equal(t, flow.Checksum, checksum)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	repository := context.NewCdslContextRepositoryUnitTestSupport()
+
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = repository
+
+	output, err := executor.Execute(flow, types.NewCdslInputEvent())
+	require.NoError(t, err)
+
+	ctx, err := repository.GetContext("", output.ContextID)
+	require.NoError(t, err)
+	assert.Equal(t, "kyc", ctx.CurrentFlow)
+	assert.Equal(t, flow.Checksum, ctx.FlowChecksum)
+	assert.Equal(t, flow.DocumentChecksum, ctx.DocumentChecksum)
+}
@@ -0,0 +1,361 @@
+package tests
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingModel is the model of countingDsl
+type countingModel struct {
+	Label string `cdsl:"label"`
+}
+
+// countingDsl records the models it is executed with
+type countingDsl struct {
+	dsl.DslSupport
+	models *[]*countingModel
+}
+
+// NewModel implements ModelDsl
+func (d *countingDsl) NewModel() interface{} {
+	return &countingModel{}
+}
+
+// Execute implements Dsl
+func (d *countingDsl) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	*d.models = append(*d.models, model.(*countingModel))
+	return nil, nil
+}
+
+// mutatingDsl is a DSL without a typed model that overwrites the model it is given
+type mutatingDsl struct {
+	dsl.DslSupport
+	seen *[]string
+}
+
+// Execute implements Dsl
+func (d *mutatingDsl) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	m := model.(*dsl.MapModel)
+	*d.seen = append(*d.seen, m.GetString("label"))
+	m.Set("label", "mutated")
+	m.AddChild(dsl.NewNamedMapModel("added"))
+	return nil, nil
+}
+
+// newPlanExecutor creates an executor for a registry with in memory support services
+func newPlanExecutor(flowRegistry execution.FlowRegistry, dslInitHelper execution.DslInitHelper) *execution.FlowExecutor {
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = context.NewCdslContextRepositoryUnitTestSupport()
+	return executor
+}
+
+// TestFlowsAreCompiledOnce tests that DSLs are resolved and models bound once per flow version
+func TestFlowsAreCompiledOnce(t *testing.T) {
+	var models []*countingModel
+	created := 0
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	dslInitHelper.RegisterDsl("counting", func() dsl.Dsl {
+		created++
+		return &countingDsl{models: &models}
+	})
+
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	flow, err := registry.NewFlowBuilder("counted").
+		DefaultStep("init").
+		Step("init", registry.Elem("counting", "label", "a"), registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	before := created
+	for i := 0; i < 3; i++ {
+		_, err := executor.ExecuteFlow("counted", types.NewCdslInputEvent())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, created-before)
+	require.Len(t, models, 3)
+	assert.Equal(t, "a", models[0].Label)
+	assert.Same(t, models[0], models[2])
+	assert.Same(t, executor.Compile(flow), executor.Compile(flow))
+	assert.NoError(t, executor.Compile(flow).Err())
+}
+
+// TestMapModelsAreCopiedForEachExecution tests that a DSL without a typed model cannot change the model of
+// the next execution or of the registered flow
+func TestMapModelsAreCopiedForEachExecution(t *testing.T) {
+	var seen []string
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	dslInitHelper.RegisterDsl("mutating", func() dsl.Dsl {
+		return &mutatingDsl{seen: &seen}
+	})
+
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	flow, err := registry.NewFlowBuilder("mutated").
+		DefaultStep("init").
+		Step("init", registry.Elem("mutating", "label", "original"), registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	for i := 0; i < 2; i++ {
+		_, err := executor.ExecuteFlow("mutated", types.NewCdslInputEvent())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"original", "original"}, seen)
+	registered := flow.Steps["init"].LogicElements[0].Model.(*dsl.MapModel)
+	assert.Equal(t, "original", registered.GetString("label"))
+	assert.Empty(t, registered.Children)
+}
+
+// TestPlansOfRetiredVersionsAreDropped tests that the executor recompiles a version after it is retired
+func TestPlansOfRetiredVersionsAreDropped(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	loader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+
+	v1 := loadVersion(t, loader, flowRegistry, reviewFlowXml("v1"))
+	plan := executor.Compile(v1)
+	loadVersion(t, loader, flowRegistry, reviewFlowXml("v2"))
+	assert.Same(t, plan, executor.Compile(v1))
+
+	require.NoError(t, flowRegistry.RetireVersion("kyc", 1, context.NewCdslContextRepositoryUnitTestSupport()))
+	assert.NotSame(t, plan, executor.Compile(v1))
+}
+
+// subscriptionCountingRegistry counts the listeners subscribed to a registry
+type subscriptionCountingRegistry struct {
+	*registry.InMemoryFlowRegistry
+	subscribed int
+}
+
+// Subscribe implements SubscribingFlowRegistry
+func (r *subscriptionCountingRegistry) Subscribe(listener func(registry.FlowRegistryEvent)) func() {
+	r.subscribed++
+	unsubscribe := r.InMemoryFlowRegistry.Subscribe(listener)
+	return func() {
+		r.subscribed--
+		unsubscribe()
+	}
+}
+
+// TestPlansOfUnregisteredFlowsAreDropped tests that the executor recompiles a flow after it is unregistered
+// and registered again
+func TestPlansOfUnregisteredFlowsAreDropped(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	flow, err := registry.NewFlowBuilder("removed").
+		DefaultStep("init").
+		Step("init", registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	plan := executor.Compile(flow)
+	require.NoError(t, flowRegistry.Unregister("removed"))
+	assert.NotSame(t, plan, executor.Compile(flow))
+}
+
+// TestClosedExecutorsUnsubscribe tests that Close removes the executor's registry listener and that the
+// executor subscribes again when it is used after being closed
+func TestClosedExecutorsUnsubscribe(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := &subscriptionCountingRegistry{InMemoryFlowRegistry: registry.NewInMemoryFlowRegistry()}
+	flow, err := registry.NewFlowBuilder("closed").
+		DefaultStep("init").
+		Step("init", registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	plan := executor.Compile(flow)
+	executor.Compile(flow)
+	assert.Equal(t, 1, flowRegistry.subscribed)
+
+	executor.Close()
+	executor.Close()
+	assert.Equal(t, 0, flowRegistry.subscribed)
+
+	assert.NotSame(t, plan, executor.Compile(flow))
+	assert.Equal(t, 1, flowRegistry.subscribed)
+	executor.Close()
+}
+
+// TestPlanCacheIsBounded tests that the oldest plan is dropped once the executor holds MaxPlans plans
+func TestPlanCacheIsBounded(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	executor.MaxPlans = 2
+	assert.Equal(t, execution.DefaultMaxPlans, execution.NewFlowExecutor().MaxPlans)
+
+	var flows []*model.Flow
+	var plans []*execution.FlowPlan
+	for _, id := range []string{"first", "second", "third"} {
+		flow, err := registry.NewFlowBuilder(id).
+			DefaultStep("init").
+			Step("init", registry.Elem("endRoute")).
+			BuildAndRegister(flowRegistry, dslInitHelper)
+		require.NoError(t, err)
+		flows = append(flows, flow)
+		plans = append(plans, executor.Compile(flow))
+	}
+
+	assert.Same(t, plans[2], executor.Compile(flows[2]))
+	assert.Same(t, plans[1], executor.Compile(flows[1]))
+	assert.NotSame(t, plans[0], executor.Compile(flows[0]))
+}
+
+// TestElementsThatFailToCompileFailWhenExecuted tests that compile errors are reported by the plan and raised,
+// or routed to the error step, only when the element is reached
+func TestElementsThatFailToCompileFailWhenExecuted(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	flow, err := registry.NewFlowBuilder("broken").
+		DefaultStep("init").
+		ErrorStep("error").
+		Step("init",
+			registry.Elem("setVar", "name", "reached", "val", "init"),
+			registry.Elem("setState", "val", "Sleeping"),
+			registry.Elem("endRoute"),
+		).
+		Step("error",
+			registry.Elem("setVar", "name", "recovered", "val", "true"),
+			registry.Elem("endRoute"),
+		).
+		Step("unused", registry.Elem("unknownDsl")).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, flowRegistry.RegisterFlow(flow))
+
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	planErr := executor.Compile(flow).Err()
+	require.Error(t, planErr)
+	assert.Contains(t, planErr.Error(), "Invalid model for DSL setState in step init of flow broken")
+	assert.Contains(t, planErr.Error(), "Failed to resolve DSL unknownDsl")
+
+	output, err := executor.ExecuteFlow("broken", types.NewCdslInputEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "init", output.OutputValues["reached"].Value)
+	assert.Equal(t, "true", output.OutputValues["recovered"].Value)
+}
+
+// loadKycBenchmark loads kycProcess into a registry and returns it with the DSLs it uses
+func loadKycBenchmark(b *testing.B) (*registry.InMemoryFlowRegistry, *registry.DslInitialisationHelper) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stdout) })
+
+	doc, err := definitionsource.NewXmlDomDefinitionSource(filepath.Join("..", "..", "resources")).LoadDocument("kyc-flow.xml")
+	require.NoError(b, err)
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(b, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(b, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocument(doc))
+	return flowRegistry, dslInitHelper
+}
+
+// BenchmarkKycProcess executes kycProcess from its compiled plan
+func BenchmarkKycProcess(b *testing.B) {
+	flowRegistry, dslInitHelper := loadKycBenchmark(b)
+	executor := newPlanExecutor(flowRegistry, dslInitHelper)
+	b.Cleanup(executor.Close)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := executor.ExecuteFlow("kycProcess", types.NewCdslInputEvent()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// uncompiledResolver resolves every DSL to an uncompiledDsl, so a plan does no work at compile time
+type uncompiledResolver struct {
+	resolver execution.DslInitHelper
+}
+
+// Resolve implements execution.DslInitHelper
+func (r uncompiledResolver) Resolve(meta types.DslMetadata) dsl.Dsl {
+	return &uncompiledDsl{resolver: r.resolver, meta: meta}
+}
+
+// uncompiledDsl does on each execution what the executor did for every element before flows were compiled:
+// it resolves the DSL, copies the model through a JSON round trip and binds the typed model
+type uncompiledDsl struct {
+	resolver execution.DslInitHelper
+	meta     types.DslMetadata
+}
+
+// Execute implements Dsl
+func (d *uncompiledDsl) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	return d.ExecuteContext(gocontext.Background(), runtime, ctx, model, input)
+}
+
+// ExecuteContext implements ContextDsl
+func (d *uncompiledDsl) ExecuteContext(goCtx gocontext.Context, runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	instance := d.resolver.Resolve(d.meta)
+	if instance == nil {
+		return nil, fmt.Errorf("failed to resolve DSL %s", d.meta.Name)
+	}
+
+	data, err := json.Marshal(d.meta.Model)
+	if err != nil {
+		return nil, err
+	}
+	copied := dsl.NewMapModel()
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, err
+	}
+
+	bound, err := dsl.Bind(instance, copied)
+	if err != nil {
+		return nil, err
+	}
+	return dsl.WithContext(instance).ExecuteContext(goCtx, runtime, ctx, bound, input)
+}
+
+// BenchmarkKycProcessCompiledEachTime executes kycProcess resolving every DSL, copying every model through JSON
+// and binding it on each execution, as the executor did before flows were compiled
+func BenchmarkKycProcessCompiledEachTime(b *testing.B) {
+	flowRegistry, dslInitHelper := loadKycBenchmark(b)
+	executor := newPlanExecutor(flowRegistry, uncompiledResolver{dslInitHelper})
+	b.Cleanup(executor.Close)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := executor.ExecuteFlow("kycProcess", types.NewCdslInputEvent()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
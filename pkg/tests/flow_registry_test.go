@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListFlowsDescribesLoadedFlows tests that the registry lists its flows with where they came from
+func TestListFlowsDescribesLoadedFlows(t *testing.T) {
+	doc, err := definitionsource.NewXmlDomDefinitionSource(filepath.Join("..", "..", "resources")).LoadDocument("kyc-flow.xml")
+	require.NoError(t, err)
+	flowRegistry := loadIntoRegistry(t, doc)
+
+	infos := flowRegistry.ListFlows()
+	require.Len(t, infos, 1)
+	info := infos[0]
+	assert.Equal(t, "kycProcess", info.ID)
+	assert.Equal(t, "kyc-flow.xml", info.Source)
+	assert.Equal(t, 1, info.Version)
+	assert.Equal(t, []int{1}, info.Versions)
+	assert.Equal(t, doc.Checksum, info.DocumentChecksum)
+
+	flow, err := flowRegistry.GetFlow("kycProcess")
+	require.NoError(t, err)
+	assert.Equal(t, len(flow.Steps), info.StepCount)
+	assert.Equal(t, flow.Checksum, info.Checksum)
+
+	single, err := flowRegistry.FlowInfo("kycProcess")
+	require.NoError(t, err)
+	assert.Equal(t, info, single)
+}
+
+// TestBuiltFlowsHaveNoSource tests that a flow built in code is not described as coming from a document
+func TestBuiltFlowsHaveNoSource(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	flow, err := registry.NewFlowBuilder("built").
+		DefaultStep("init").
+		Step("init", registry.Elem("endRoute")).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+	assert.NotEmpty(t, flow.Position.File)
+
+	info, err := flowRegistry.FlowInfo("built")
+	require.NoError(t, err)
+	assert.Equal(t, "", info.Source)
+}
+
+// TestUnknownFlowsReturnNotFound tests that looking up, describing, removing or executing an unknown flow
+// returns a CdslFlowNotFoundError
+func TestUnknownFlowsReturnNotFound(t *testing.T) {
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+
+	flow, err := flowRegistry.GetFlow("missing")
+	assert.Nil(t, flow)
+	var notFound *exceptions.CdslFlowNotFoundError
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "missing", notFound.FlowID)
+	assert.Equal(t, "Flow missing is not registered", err.Error())
+
+	_, err = flowRegistry.FlowInfo("missing")
+	assert.True(t, errors.As(err, &notFound))
+	assert.True(t, errors.As(flowRegistry.Unregister("missing"), &notFound))
+
+	_, err = flowRegistry.GetFlowVersion("missing", 2)
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, 2, notFound.Version)
+
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	_, err = executor.ExecuteFlow("missing", types.NewCdslInputEvent())
+	assert.True(t, errors.As(err, &notFound))
+}
+
+// TestRegistrySubscribersSeeEveryChange tests that subscribers are notified of registrations, removals and
+// retired versions until they unsubscribe
+func TestRegistrySubscribersSeeEveryChange(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	loader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+
+	var events []registry.FlowRegistryEvent
+	unsubscribe := flowRegistry.Subscribe(func(event registry.FlowRegistryEvent) {
+		events = append(events, event)
+	})
+
+	loadVersion(t, loader, flowRegistry, reviewFlowXml("v1"))
+	loadVersion(t, loader, flowRegistry, reviewFlowXml("v1"))
+	loadVersion(t, loader, flowRegistry, reviewFlowXml("v2"))
+	require.NoError(t, flowRegistry.RetireVersion("kyc", 1, context.NewCdslContextRepositoryUnitTestSupport()))
+
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = context.NewCdslContextRepositoryUnitTestSupport()
+	output, err := executor.ExecuteFlow("kyc", types.NewCdslInputEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "Await", output.ContextState)
+
+	require.NoError(t, flowRegistry.Unregister("kyc"))
+	_, err = executor.ExecuteFlow("kyc", types.NewCdslInputEvent())
+	assert.Error(t, err)
+	assert.Empty(t, flowRegistry.ListFlows())
+
+	unsubscribe()
+	loadVersion(t, loader, flowRegistry, reviewFlowXml("v3"))
+
+	assert.Equal(t, []registry.FlowRegistryEvent{
+		{Type: registry.FlowRegistered, FlowID: "kyc", Version: 1},
+		{Type: registry.FlowRegistered, FlowID: "kyc", Version: 2},
+		{Type: registry.FlowVersionRetired, FlowID: "kyc", Version: 1},
+		{Type: registry.FlowUnregistered, FlowID: "kyc", Version: 2},
+	}, events)
+
+	info, err := flowRegistry.FlowInfo("kyc")
+	require.NoError(t, err)
+	assert.Equal(t, 3, info.Version)
+	assert.Equal(t, []int{2, 3}, info.Versions)
+}
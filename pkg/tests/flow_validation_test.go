@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/exceptions"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newValidator creates a validator with the test DSLs registered
+func newValidator(t *testing.T) *registry.RegistryValidator {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	return registry.NewRegistryValidator(registry.NewInMemoryFlowRegistry(), dslInitHelper)
+}
+
+// problemMessages returns the messages of the problems in a report
+func problemMessages(problems []registry.ValidationProblem) []string {
+	messages := make([]string, len(problems))
+	for i, problem := range problems {
+		messages[i] = problem.Message
+	}
+	return messages
+}
+
+// TestKycFlowHasNoValidationProblems tests that the KYC flow passes graph analysis cleanly
+func TestKycFlowHasNoValidationProblems(t *testing.T) {
+	doc, err := definitionsource.NewXmlDomDefinitionSource(filepath.Join("..", "..", "resources")).LoadDocument("kyc-flow.xml")
+	require.NoError(t, err)
+	flow, err := loadIntoRegistry(t, doc).GetFlow("kycProcess")
+	require.NoError(t, err)
+
+	report := newValidator(t).AnalyseFlow(flow)
+	assert.Empty(t, report.Problems)
+	assert.NoError(t, report.Err())
+}
+
+// TestValidatorReportsEveryGraphProblem tests that dangling routes, unreachable steps, steps without
+// an exit and cycles without an await are all reported together
+func TestValidatorReportsEveryGraphProblem(t *testing.T) {
+	flow, err := registry.NewFlowBuilder("broken").
+		DefaultStep("start").
+		Step("start", registry.Elem("routeTo", "target", "chekc")).
+		Step("check", registry.Elem("setVar", "name", "checked", "val", "true")).
+		Step("retry", registry.Elem("routeTo", "target", "again")).
+		Step("again", registry.Elem("routeTo", "target", "retry")).
+		Build()
+	require.NoError(t, err)
+
+	report := newValidator(t).AnalyseFlow(flow)
+
+	assert.Equal(t, []string{
+		"Step start of flow broken routes to step chekc which does not exist",
+		"Steps again -> retry -> again of flow broken form a cycle without an await",
+	}, problemMessages(report.Errors()))
+	assert.Equal(t, []string{
+		"Step again of flow broken is not reachable from default step start",
+		"Step check of flow broken is not reachable from default step start",
+		"Step check of flow broken has no routeTo, await or endRoute",
+		"Step retry of flow broken is not reachable from default step start",
+	}, problemMessages(report.Warnings()))
+
+	dangling := report.Errors()[0]
+	assert.Equal(t, "start", dangling.StepID)
+	assert.Contains(t, dangling.Position.String(), "flow_validation_test.go:")
+
+	err = newValidator(t).ValidateFlow(flow)
+	require.Error(t, err)
+	var validationErr *registry.FlowValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Len(t, validationErr.Report.Errors(), 2)
+	var cdslErr *exceptions.CdslValidationError
+	require.True(t, errors.As(err, &cdslErr))
+	assert.Equal(t, validationErr.Message, cdslErr.Message)
+	assert.Contains(t, err.Error(), "Flow broken has 2 errors")
+	assert.Contains(t, err.Error(), "routes to step chekc which does not exist")
+	assert.Contains(t, err.Error(), "form a cycle without an await")
+}
+
+// TestCycleThroughAwaitIsValid tests that a loop which pauses on an await is not reported as a cycle
+func TestCycleThroughAwaitIsValid(t *testing.T) {
+	flow, err := registry.NewFlowBuilder("review").
+		DefaultStep("submit").
+		ErrorStep("error").
+		Step("submit", registry.Elem("await", "at", "review")).
+		Step("review", registry.Elem("routeTo", "target", "submit")).
+		Step("error", registry.Elem("endRoute")).
+		Build()
+	require.NoError(t, err)
+
+	report := newValidator(t).AnalyseFlow(flow)
+	assert.Empty(t, report.Problems)
+}
+
+// routeIfModel is the model of the routeIf DSL
+type routeIfModel struct {
+	Var    string `cdsl:"var,required"`
+	Target string `cdsl:"target,required"`
+}
+
+// routeIf routes to its target while a context variable is set
+type routeIf struct {
+	dsl.DslSupport
+}
+
+// NewModel implements ModelDsl
+func (d *routeIf) NewModel() interface{} {
+	return &routeIfModel{}
+}
+
+// Execute implements Dsl
+func (d *routeIf) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	m := model.(*routeIfModel)
+	if ctx.GetVar(m.Var) == "" {
+		return nil, nil
+	}
+	output := types.NewCdslOutputEvent()
+	output.Action = types.ActionRoute
+	output.NextRoute = m.Target
+	return output, nil
+}
+
+// Routes implements RoutingDsl
+func (d *routeIf) Routes(model interface{}) []dsl.Route {
+	m, ok := model.(*routeIfModel)
+	if !ok {
+		return nil
+	}
+	return []dsl.Route{{Action: types.ActionRoute, Target: m.Target, Conditional: true}}
+}
+
+// TestConditionalCyclesAreWarnings tests that a cycle a conditional route could leave is only a warning
+func TestConditionalCyclesAreWarnings(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	require.NoError(t, dslInitHelper.RegisterDsl("routeIf", func() dsl.Dsl { return &routeIf{} }))
+	validator := registry.NewRegistryValidator(registry.NewInMemoryFlowRegistry(), dslInitHelper)
+
+	flow, err := registry.NewFlowBuilder("polling").
+		DefaultStep("poll").
+		Step("poll", registry.Elem("routeIf", "var", "ready", "target", "done"), registry.Elem("routeTo", "target", "wait")).
+		Step("wait", registry.Elem("routeTo", "target", "poll")).
+		Step("done", registry.Elem("endRoute")).
+		Build()
+	require.NoError(t, err)
+
+	report := validator.AnalyseFlow(flow)
+	assert.Empty(t, report.Errors())
+	assert.Equal(t, []string{
+		"Steps poll -> wait -> poll of flow polling form a cycle without an await unless a conditional route leaves it",
+	}, problemMessages(report.Warnings()))
+	assert.NoError(t, validator.ValidateFlow(flow))
+}
+
+// TestWarningsDoNotFailValidation tests that a flow with only warnings still validates
+func TestWarningsDoNotFailValidation(t *testing.T) {
+	flow, err := registry.NewFlowBuilder("unused").
+		DefaultStep("start").
+		Step("start", registry.Elem("endRoute")).
+		Step("orphan", registry.Elem("endRoute")).
+		Build()
+	require.NoError(t, err)
+
+	validator := newValidator(t)
+	assert.Len(t, validator.AnalyseFlow(flow).Warnings(), 1)
+	assert.NoError(t, validator.ValidateFlow(flow))
+}
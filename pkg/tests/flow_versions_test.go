@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/model"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reviewFlowXml returns a flow that waits for a review and then records which version completed it
+func reviewFlowXml(version string) string {
+	return `<cdsl>
+    <flow id="kyc" defaultStep="init">
+        <step id="init">
+            <await at="review"/>
+        </step>
+        <step id="review">
+            <setVar name="reviewedBy" val="` + version + `"/>
+            <endRoute/>
+            <finally>
+                <setState val="End"/>
+            </finally>
+        </step>
+    </flow>
+</cdsl>`
+}
+
+// loadVersion loads a document into an existing registry and returns the current flow
+func loadVersion(t *testing.T, loader *registry.RegistryLoader, flowRegistry *registry.InMemoryFlowRegistry, xml string) *model.Flow {
+	doc, err := definitionsource.XmlDocumentParser{}.ParseDocument("kyc.xml", strings.NewReader(xml))
+	require.NoError(t, err)
+	doc.Source = "kyc.xml"
+	require.NoError(t, loader.LoadDocument(doc))
+
+	flow, err := flowRegistry.GetFlow("kyc")
+	require.NoError(t, err)
+	return flow
+}
+
+// TestContextsResumeOnPinnedVersion tests that a context started before a flow changed finishes on its original version
+func TestContextsResumeOnPinnedVersion(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	loader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	repository := context.NewCdslContextRepositoryUnitTestSupport()
+
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = repository
+
+	v1 := loadVersion(t, loader, flowRegistry, reviewFlowXml("v1"))
+	assert.Equal(t, 1, v1.Version)
+
+	started, err := executor.Execute(v1, types.NewCdslInputEvent())
+	require.NoError(t, err)
+
+	// Loading the same definition again does not create a version
+	assert.Same(t, v1, loadVersion(t, loader, flowRegistry, reviewFlowXml("v1")))
+
+	v2 := loadVersion(t, loader, flowRegistry, reviewFlowXml("v2"))
+	assert.Equal(t, 2, v2.Version)
+	assert.Len(t, flowRegistry.ListVersions("kyc"), 2)
+
+	// Version 1 cannot be retired while the context started on it is live
+	err = flowRegistry.RetireVersion("kyc", 1, repository)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "used by 1 live contexts")
+	err = flowRegistry.RetireVersion("kyc", 2, repository)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is the current version")
+
+	// Resuming with the current flow runs the version the context started on
+	resume := types.NewCdslInputEvent()
+	resume.ContextID = started.ContextID
+	_, err = executor.Execute(v2, resume)
+	require.NoError(t, err)
+
+	ctx, err := repository.GetContext("", started.ContextID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, ctx.FlowVersion)
+	assert.Equal(t, "v1", ctx.GetVar("reviewedBy"))
+	assert.Equal(t, context.StateEnd, ctx.State)
+
+	retired, err := flowRegistry.RetireUnusedVersions("kyc", repository)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, retired)
+
+	versions := flowRegistry.ListVersions("kyc")
+	require.Len(t, versions, 1)
+	assert.Same(t, v2, versions[0])
+	retiredFlow, _ := flowRegistry.GetFlowVersion("kyc", 1)
+	assert.Nil(t, retiredFlow)
+}
+
+// TestResumeOnRetiredVersionFails tests that a context whose version was retired cannot silently move to another version
+func TestResumeOnRetiredVersionFails(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+
+	flowRegistry, err := loadFragmentFlows(t, fstest.MapFS{"kyc.xml": {Data: []byte(reviewFlowXml("v1"))}})
+	require.NoError(t, err)
+	v1, _ := flowRegistry.GetFlow("kyc")
+
+	repository := context.NewCdslContextRepositoryUnitTestSupport()
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = repository
+
+	started, err := executor.Execute(v1, types.NewCdslInputEvent())
+	require.NoError(t, err)
+
+	loader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	v2 := loadVersion(t, loader, flowRegistry, reviewFlowXml("v2"))
+	require.NoError(t, flowRegistry.RetireVersion("kyc", 1, context.NewCdslContextRepositoryUnitTestSupport()))
+
+	resume := types.NewCdslInputEvent()
+	resume.ContextID = started.ContextID
+	_, err = executor.Execute(v2, resume)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "version 1")
+}
+
+// TestDuplicateRegistrationsAreLeftUnchanged tests that a flow discarded because the current version has the same
+// checksum keeps its own version number
+func TestDuplicateRegistrationsAreLeftUnchanged(t *testing.T) {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	builder := func() *registry.FlowBuilder {
+		return registry.NewFlowBuilder("same").DefaultStep("init").Step("init", registry.Elem("endRoute"))
+	}
+
+	registered, err := builder().BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+	assert.Equal(t, 1, registered.Version)
+
+	duplicate, err := builder().Build()
+	require.NoError(t, err)
+	require.NoError(t, flowRegistry.RegisterFlow(duplicate))
+	assert.Equal(t, 0, duplicate.Version)
+
+	current, err := builder().BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+	assert.Same(t, registered, current)
+	assert.Len(t, flowRegistry.ListVersions("same"), 1)
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadFragmentFlows loads every document in fsys into a fresh registry
+func loadFragmentFlows(t *testing.T, fsys fstest.MapFS) (*registry.InMemoryFlowRegistry, error) {
+	docs, err := definitionsource.NewFSDefinitionSource(fsys).LoadAll()
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	return flowRegistry, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocuments(docs)
+}
+
+// TestFragmentsExpandIntoSteps tests that useFragment is replaced by the fragment's elements with parameters substituted
+func TestFragmentsExpandIntoSteps(t *testing.T) {
+	fsys := fstest.MapFS{
+		"shared/fragments.xml": {Data: []byte(`<cdsl>
+    <fragment id="enterStep">
+        <setVar name="status" val="${status}"/>
+        <setVar name="audit" val="entered ${status} in ${region:EU}"/>
+        <useFragment ref="guard" level="${status}"/>
+    </fragment>
+    <fragment id="guard">
+        <setVar name="guard" val="${level}"/>
+    </fragment>
+</cdsl>`)},
+		"kyc.xml": {Data: []byte(`<cdsl>
+    <import href="shared/fragments.xml"/>
+    <flow id="kyc" defaultStep="init">
+        <step id="init">
+            <useFragment ref="enterStep" status="collecting"/>
+            <routeTo target="done"/>
+        </step>
+        <step id="done">
+            <endRoute/>
+            <finally>
+                <useFragment ref="guard" level="final"/>
+            </finally>
+        </step>
+    </flow>
+</cdsl>`)},
+	}
+
+	flowRegistry, err := loadFragmentFlows(t, fsys)
+	require.NoError(t, err)
+
+	flow, err := flowRegistry.GetFlow("kyc")
+	require.NoError(t, err)
+	require.NotNil(t, flow)
+
+	init := flow.Steps["init"]
+	require.Len(t, init.LogicElements, 4)
+	values := make([]string, 0, 3)
+	for _, elem := range init.LogicElements[:3] {
+		assert.Equal(t, "setVar", elem.Name)
+		values = append(values, elem.Model.(*dsl.MapModel).GetString("val"))
+	}
+	assert.Equal(t, []string{"collecting", "entered collecting in EU", "collecting"}, values)
+	assert.Equal(t, "routeTo", init.LogicElements[3].Name)
+	assert.Equal(t, "shared/fragments.xml", init.LogicElements[0].Position.File)
+
+	done := flow.Steps["done"]
+	require.Len(t, done.FinalElements, 1)
+	assert.Equal(t, "final", done.FinalElements[0].Model.(*dsl.MapModel).GetString("val"))
+}
+
+// TestFragmentErrors tests that unknown and recursive fragments are rejected
+func TestFragmentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		xml      string
+		expected string
+	}{
+		{
+			name:     "unknown fragment",
+			xml:      `<cdsl><flow id="kyc" defaultStep="init"><step id="init"><useFragment ref="missing"/></step></flow></cdsl>`,
+			expected: "Unknown fragment missing at kyc.xml:1:57",
+		},
+		{
+			name:     "missing ref",
+			xml:      `<cdsl><flow id="kyc" defaultStep="init"><step id="init"><useFragment/></step></flow></cdsl>`,
+			expected: "must have a ref attribute",
+		},
+		{
+			name: "cycle",
+			xml: `<cdsl>
+<fragment id="a"><useFragment ref="b"/></fragment>
+<fragment id="b"><useFragment ref="a"/></fragment>
+<flow id="kyc" defaultStep="init"><step id="init"><useFragment ref="a"/></step></flow>
+</cdsl>`,
+			expected: "Fragment cycle: a -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadFragmentFlows(t, fstest.MapFS{"kyc.xml": {Data: []byte(tt.xml)}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+// TestDuplicateFragmentIsRejected tests that a fragment ID defined in two documents is rejected, whether or not
+// they have a Source
+func TestDuplicateFragmentIsRejected(t *testing.T) {
+	fragment := `<cdsl><fragment id="enterStep"><setVar name="a" val="b"/></fragment></cdsl>`
+	_, err := loadFragmentFlows(t, fstest.MapFS{
+		"first.xml":  {Data: []byte(fragment)},
+		"second.xml": {Data: []byte(fragment)},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fragment enterStep is already defined in first.xml")
+
+	unnamed := func() *definitionsource.DocumentDefinition {
+		return &definitionsource.DocumentDefinition{
+			Fragments: map[string]*definitionsource.FragmentDefinition{"enterStep": {ID: "enterStep"}},
+			Flows:     map[string]*definitionsource.FlowDefinition{},
+		}
+	}
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	loader := registry.NewRegistryLoader(registry.NewInMemoryFlowRegistry(), dslInitHelper)
+	err = loader.LoadDocuments([]*definitionsource.DocumentDefinition{unnamed(), unnamed()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fragment enterStep is already defined in <unnamed document>")
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// greetModel is bound from <greet name="..." times="..."/>
+type greetModel struct {
+	Name  string `cdsl:"name,required" doc:"Who to greet"`
+	Times int    `cdsl:"times,default=1"`
+}
+
+// thresholdModel is bound from <threshold var="..." max="..." over="..."/>
+type thresholdModel struct {
+	Var  string `cdsl:"var,required"`
+	Max  int    `cdsl:"max,required"`
+	Over string `cdsl:"over,required"`
+}
+
+// funcHelper registers the test DSLs and two function DSLs
+func funcHelper(t *testing.T) *registry.DslInitialisationHelper {
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+
+	registry.RegisterFunc(dslInitHelper, "greet", func(ec *dsl.ExecContext, m greetModel) (*types.CdslOutputEvent, error) {
+		punctuation, _ := ec.Payload("punctuation")
+		greeting := strings.Repeat("Hello "+m.Name, m.Times)
+		ec.Output("greeted", m.Name)
+		return nil, ec.SetVar("greeting", greeting+punctuation.(string))
+	})
+
+	registry.RegisterFunc(dslInitHelper, "threshold", func(ec *dsl.ExecContext, m *thresholdModel) (*types.CdslOutputEvent, error) {
+		if len(ec.Var(m.Var)) <= m.Max {
+			return nil, nil
+		}
+		output := types.NewCdslOutputEvent()
+		output.Action = types.ActionRoute
+		output.NextRoute = m.Over
+		return output, nil
+	})
+	return dslInitHelper
+}
+
+// TestFuncDslsExecute tests that function DSLs receive their bound model and the execution context
+func TestFuncDslsExecute(t *testing.T) {
+	dslInitHelper := funcHelper(t)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	_, err := registry.NewFlowBuilder("greeting").
+		DefaultStep("init").
+		Step("init",
+			registry.Elem("greet", "name", "Go"),
+			registry.Elem("threshold", "var", "greeting", "max", "5", "over", "long"),
+			registry.Elem("endRoute"),
+		).
+		Step("long",
+			registry.Elem("setVar", "name", "length", "val", "long"),
+			registry.Elem("endRoute"),
+		).
+		BuildAndRegister(flowRegistry, dslInitHelper)
+	require.NoError(t, err)
+
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = context.NewCdslContextRepositoryUnitTestSupport()
+
+	input := types.NewCdslInputEvent()
+	input.Payload["punctuation"] = "!"
+	output, err := executor.ExecuteFlow("greeting", input)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Go!", output.OutputValues["greeting"].Value)
+	assert.Equal(t, "Go", output.OutputValues["greeted"].Value)
+	assert.Equal(t, "long", output.OutputValues["length"].Value)
+}
+
+// TestFuncDslsAreValidatedAndCatalogued tests that function DSLs are described by their model
+func TestFuncDslsAreValidatedAndCatalogued(t *testing.T) {
+	dslInitHelper := funcHelper(t)
+
+	greet, ok := dslInitHelper.Descriptor("greet")
+	require.True(t, ok)
+	name, _ := greet.Attribute("name")
+	assert.True(t, name.Required)
+	assert.Equal(t, "Who to greet", name.Description)
+	times, _ := greet.Attribute("times")
+	assert.Equal(t, dsl.AttributeInt, times.Type)
+	_, ok = dslInitHelper.Descriptor("threshold")
+	assert.True(t, ok)
+
+	flow, err := registry.NewFlowBuilder("invalid").
+		DefaultStep("init").
+		Step("init",
+			registry.Elem("greet", "times", "twice"),
+			registry.Elem("threshold", "var", "a", "max", "1"),
+			registry.Elem("endRoute"),
+		).
+		Build()
+	require.NoError(t, err)
+
+	errs := registry.NewRegistryValidator(registry.NewInMemoryFlowRegistry(), dslInitHelper).AnalyseFlow(flow).Errors()
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[0].String(), `Attribute times of element greet is "twice", expected an integer`)
+	assert.Contains(t, errs[1].String(), "Element threshold requires attribute over")
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFlowReloaderSwapsOnlyValidFlows tests reload diffs and that invalid definitions are not applied
+func TestFlowReloaderSwapsOnlyValidFlows(t *testing.T) {
+	fsys := fstest.MapFS{
+		"kyc.xml":     {Data: []byte(singleStepFlowXml("kyc"))},
+		"renewal.xml": {Data: []byte(singleStepFlowXml("renewal"))},
+	}
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	reloader := registry.NewFlowReloader(definitionsource.NewFSDefinitionSource(fsys), flowRegistry, dslInitHelper)
+
+	var events []registry.ReloadEvent
+	reloader.OnReload(func(event registry.ReloadEvent) {
+		events = append(events, event)
+	})
+
+	event := reloader.Reload()
+	require.NoError(t, event.Err)
+	assert.Equal(t, []string{"kyc", "renewal"}, event.Added)
+
+	// Nothing changed, so no event is emitted
+	reloader.Reload()
+	assert.Len(t, events, 1)
+
+	original, _ := flowRegistry.GetFlow("kyc")
+
+	// A broken default step fails validation and the previous flow is kept
+	fsys["kyc.xml"] = &fstest.MapFile{Data: []byte(strings.Replace(singleStepFlowXml("kyc"), `defaultStep="init"`, `defaultStep="missing"`, 1))}
+	event = reloader.Reload()
+	require.Error(t, event.Err)
+	current, _ := flowRegistry.GetFlow("kyc")
+	assert.Same(t, original, current)
+
+	// A valid change is applied and removed files drop their flows
+	fsys["kyc.xml"] = &fstest.MapFile{Data: []byte(strings.Replace(singleStepFlowXml("kyc"), "<endRoute/>", `<setVar name="a" val="b"/><endRoute/>`, 1))}
+	delete(fsys, "renewal.xml")
+	event = reloader.Reload()
+	require.NoError(t, event.Err)
+	assert.Equal(t, []string{"kyc"}, event.Changed)
+	assert.Equal(t, []string{"renewal"}, event.Removed)
+	assert.Len(t, events, 3)
+
+	current, _ = flowRegistry.GetFlow("kyc")
+	assert.NotSame(t, original, current)
+	removed, _ := flowRegistry.GetFlow("renewal")
+	assert.Nil(t, removed)
+}
+
+// TestFlowReloaderPolls tests that a started reloader loads the source in the background
+func TestFlowReloaderPolls(t *testing.T) {
+	fsys := fstest.MapFS{
+		"kyc.xml": {Data: []byte(singleStepFlowXml("kyc"))},
+	}
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	reloader := registry.NewFlowReloader(definitionsource.NewFSDefinitionSource(fsys), flowRegistry, dslInitHelper)
+
+	reloaded := make(chan registry.ReloadEvent, 1)
+	reloader.OnReload(func(event registry.ReloadEvent) {
+		reloaded <- event
+	})
+
+	reloader.Start(10 * time.Millisecond)
+	defer reloader.Stop()
+
+	select {
+	case event := <-reloaded:
+		assert.Equal(t, []string{"kyc"}, event.Added)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for reload")
+	}
+}
@@ -13,21 +13,13 @@ import (
 	"github.com/rsqn/go-cdsl/pkg/execution"
 	"github.com/rsqn/go-cdsl/pkg/registry"
 	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/require"
 )
 
-// registerDSLs registers all DSL implementations
-func registerDSLs(dslInitHelper *registry.DslInitialisationHelper) {
-	dslInitHelper.RegisterDsl("setState", func() dsl.Dsl { return &dsl.SetState{} })
-	dslInitHelper.RegisterDsl("setVar", func() dsl.Dsl { return &dsl.SetVar{} })
-	dslInitHelper.RegisterDsl("routeTo", func() dsl.Dsl { return &dsl.RouteTo{} })
-	dslInitHelper.RegisterDsl("endRoute", func() dsl.Dsl { return &dsl.EndRoute{} })
-	dslInitHelper.RegisterDsl("riskAssessment", func() dsl.Dsl { return &dsl.RiskAssessment{} })
-	dslInitHelper.RegisterDsl("collectCustomerInfo", func() dsl.Dsl { return &dsl.CollectCustomerInfo{} })
-	dslInitHelper.RegisterDsl("validateCustomerInfo", func() dsl.Dsl { return &dsl.ValidateCustomerInfo{} })
-	dslInitHelper.RegisterDsl("documentVerification", func() dsl.Dsl { return &dsl.DocumentVerification{} })
-	dslInitHelper.RegisterDsl("sanctionsCheck", func() dsl.Dsl { return &dsl.SanctionsCheck{} })
-	dslInitHelper.RegisterDsl("amlCheck", func() dsl.Dsl { return &dsl.AmlCheck{} })
-	dslInitHelper.RegisterDsl("finalDecision", func() dsl.Dsl { return &dsl.FinalDecision{} })
+// registerDSLs registers the core and KYC DSL libraries
+func registerDSLs(t testing.TB, dslInitHelper *registry.DslInitialisationHelper) {
+	require.NoError(t, dslInitHelper.RegisterLibrary(dsl.CoreLibrary()))
+	require.NoError(t, dslInitHelper.RegisterLibrary(dsl.KycLibrary()))
 }
 
 // TestKycFlow tests the KYC flow
@@ -36,7 +28,7 @@ func TestKycFlow(t *testing.T) {
 	dslInitHelper := registry.NewDslInitialisationHelper()
 	
 	// Register DSL implementations
-	registerDSLs(dslInitHelper)
+	registerDSLs(t, dslInitHelper)
 	
 	// Create the flow registry
 	flowRegistry := registry.NewInMemoryFlowRegistry()
@@ -122,7 +114,7 @@ func TestKycFlowHighRisk(t *testing.T) {
 	dslInitHelper := registry.NewDslInitialisationHelper()
 	
 	// Register DSL implementations
-	registerDSLs(dslInitHelper)
+	registerDSLs(t, dslInitHelper)
 	
 	// Create the flow registry
 	flowRegistry := registry.NewInMemoryFlowRegistry()
@@ -212,7 +204,7 @@ func TestKycFlowWithError(t *testing.T) {
 	dslInitHelper := registry.NewDslInitialisationHelper()
 	
 	// Register DSL implementations
-	registerDSLs(dslInitHelper)
+	registerDSLs(t, dslInitHelper)
 	dslInitHelper.RegisterDsl("errorDsl", func() dsl.Dsl { return &dsl.ErrorDsl{} })
 	
 	// Create the flow registry
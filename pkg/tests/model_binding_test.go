@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retryPolicyModel exercises each kind of field a model can bind
+type retryPolicyModel struct {
+	Mode     string        `cdsl:"mode,default=linear,enum=linear|exponential"`
+	Attempts int           `cdsl:"attempts,required"`
+	Jitter   bool          `cdsl:"jitter"`
+	Delay    time.Duration `cdsl:"delay,default=1s"`
+	Factor   float64       `cdsl:"factor,default=1.5"`
+	Notify   *struct {
+		Channel string `cdsl:"channel,required"`
+		Message string `cdsl:",content"`
+	} `cdsl:"notify"`
+	Skips []struct {
+		Code int `cdsl:"code,required"`
+	} `cdsl:"skip"`
+	Ignored string
+}
+
+// retryPolicy builds the MapModel of a <retryPolicy> element
+func retryPolicy(attrs ...string) *dsl.MapModel {
+	m := dsl.NewNamedMapModel("retryPolicy")
+	for i := 0; i+1 < len(attrs); i += 2 {
+		m.Set(attrs[i], attrs[i+1])
+	}
+	return m
+}
+
+// TestBindModelConvertsAttributesAndChildren tests that attributes are converted to their field types and
+// children bind into nested structs in document order
+func TestBindModelConvertsAttributesAndChildren(t *testing.T) {
+	m := retryPolicy("mode", "exponential", "attempts", "5", "jitter", "true", "delay", "250ms", "Ignored", "x")
+	notify := dsl.NewNamedMapModel("notify")
+	notify.Set("channel", "ops")
+	notify.Content = "Retrying"
+	m.AddChild(notify)
+	for _, code := range []string{"404", "410"} {
+		skip := dsl.NewNamedMapModel("skip")
+		skip.Set("code", code)
+		m.AddChild(skip)
+	}
+
+	var bound retryPolicyModel
+	require.NoError(t, dsl.BindModel(m, &bound))
+	assert.Equal(t, "exponential", bound.Mode)
+	assert.Equal(t, 5, bound.Attempts)
+	assert.True(t, bound.Jitter)
+	assert.Equal(t, 250*time.Millisecond, bound.Delay)
+	assert.Equal(t, 1.5, bound.Factor)
+	require.NotNil(t, bound.Notify)
+	assert.Equal(t, "ops", bound.Notify.Channel)
+	assert.Equal(t, "Retrying", bound.Notify.Message)
+	require.Len(t, bound.Skips, 2)
+	assert.Equal(t, 410, bound.Skips[1].Code)
+	assert.Empty(t, bound.Ignored)
+}
+
+// TestBindModelAppliesDefaults tests that absent optional attributes take their defaults
+func TestBindModelAppliesDefaults(t *testing.T) {
+	var bound retryPolicyModel
+	require.NoError(t, dsl.BindModel(retryPolicy("attempts", "3"), &bound))
+	assert.Equal(t, "linear", bound.Mode)
+	assert.Equal(t, time.Second, bound.Delay)
+	assert.False(t, bound.Jitter)
+	assert.Nil(t, bound.Notify)
+	assert.Empty(t, bound.Skips)
+}
+
+// TestBindModelReportsInvalidValues tests the errors for missing, mistyped and out of range values
+func TestBindModelReportsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    *dsl.MapModel
+		expected string
+	}{
+		{"required", retryPolicy(), "Element retryPolicy requires attribute attempts"},
+		{"int", retryPolicy("attempts", "three"), `Attribute attempts of element retryPolicy is "three", expected an integer`},
+		{"bool", retryPolicy("attempts", "3", "jitter", "yes please"), `Attribute jitter of element retryPolicy is "yes please", expected true or false`},
+		{"duration", retryPolicy("attempts", "3", "delay", "soon"), `Attribute delay of element retryPolicy is "soon", expected a duration`},
+		{"enum", retryPolicy("attempts", "3", "mode", "random"), `Attribute mode of element retryPolicy is "random", expected one of linear, exponential`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bound retryPolicyModel
+			err := dsl.BindModel(tt.model, &bound)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+// quoteModel has an attribute named content as well as text content
+type quoteModel struct {
+	Content string `cdsl:"content,required"`
+	Text    string `cdsl:",content"`
+}
+
+// TestContentAttributesAreKeptApartFromText tests that an attribute named content survives loading, binding,
+// validation and writing alongside the text content of its element
+func TestContentAttributesAreKeptApartFromText(t *testing.T) {
+	doc, err := definitionsource.XmlDocumentParser{}.ParseDocument("quote.xml", strings.NewReader(`<cdsl>
+    <flow id="quoting" defaultStep="quote">
+        <step id="quote">
+            <quote content="attribute">text</quote>
+            <endRoute/>
+        </step>
+    </flow>
+</cdsl>`))
+	require.NoError(t, err)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, registry.NewDslInitialisationHelper()).LoadDocument(doc))
+	flow, err := flowRegistry.GetFlow("quoting")
+	require.NoError(t, err)
+
+	m, ok := flow.FetchStep("quote").LogicElements[0].Model.(*dsl.MapModel)
+	require.True(t, ok)
+	assert.Equal(t, "attribute", m.GetString("content"))
+	assert.Equal(t, "text", m.Content)
+
+	var bound quoteModel
+	require.NoError(t, dsl.BindModel(m, &bound))
+	assert.Equal(t, quoteModel{Content: "attribute", Text: "text"}, bound)
+	descriptor := dsl.DescribeModel(&quoteModel{})
+	assert.True(t, descriptor.Content)
+	require.NoError(t, dsl.ValidateElement(descriptor, m))
+
+	err = dsl.ValidateElement(dsl.DescribeModel(&struct {
+		Content string `cdsl:"content"`
+	}{}), m)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Element quote does not take text content")
+
+	flowDef, err := registry.FlowDefinitionOf(flow)
+	require.NoError(t, err)
+	quote := flowDef.Steps["quote"].Elements[0]
+	assert.Equal(t, map[string]string{"content": "attribute"}, quote.Attributes)
+	assert.Equal(t, "text", quote.Content)
+}
+
+// TestValidatorReportsBindingErrors tests that a model which does not bind fails validation at its element
+func TestValidatorReportsBindingErrors(t *testing.T) {
+	flow, err := registry.NewFlowBuilder("screening").
+		DefaultStep("screen").
+		Step("screen",
+			registry.Elem("sanctionsCheck", "checkType", "thorough"),
+			registry.Elem("routeTo"),
+		).
+		Build()
+	require.NoError(t, err)
+
+	report := newValidator(t).AnalyseFlow(flow)
+	errs := report.Errors()
+	require.Len(t, errs, 2)
+	assert.Equal(t, "Invalid logic element sanctionsCheck in step screen of flow screening", errs[0].Message)
+	assert.Contains(t, errs[0].String(), `Attribute checkType of element sanctionsCheck is "thorough", expected one of standard, enhanced`)
+	assert.Contains(t, errs[0].String(), "model_binding_test.go:")
+	assert.Contains(t, errs[1].String(), "Element routeTo requires attribute target")
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const screeningFlowXml = `<cdsl>
+    <flow id="screening" defaultStep="screen">
+        <step id="screen">
+            <sanctionsCheck checkType="enhanced">
+                <list name="OFAC"/>
+                <rule field="country">
+                    <condition op="in">IR,KP</condition>
+                    <condition op="eq">SY</condition>
+                </rule>
+                <list name="EU"/>
+            </sanctionsCheck>
+            <endRoute/>
+        </step>
+    </flow>
+</cdsl>`
+
+// TestNestedElementsBecomeStructuredModels tests that child elements reach DSLs as ordered, typed structures
+func TestNestedElementsBecomeStructuredModels(t *testing.T) {
+	doc, err := definitionsource.NewFSDefinitionSource(fstest.MapFS{
+		"screening.xml": {Data: []byte(screeningFlowXml)},
+	}).LoadDocument("screening.xml")
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocument(doc))
+
+	flow, err := flowRegistry.GetFlow("screening")
+	require.NoError(t, err)
+
+	model, ok := flow.FetchStep("screen").LogicElements[0].Model.(*dsl.MapModel)
+	require.True(t, ok)
+	assert.Equal(t, "sanctionsCheck", model.Name)
+	require.Len(t, model.Children, 3)
+	assert.Equal(t, []string{"list", "rule", "list"}, []string{model.Children[0].Name, model.Children[1].Name, model.Children[2].Name})
+
+	rule := model.Child("rule")
+	require.NotNil(t, rule)
+	conditions := rule.ChildrenNamed("condition")
+	require.Len(t, conditions, 2)
+	assert.Equal(t, "IR,KP", conditions[0].Content)
+
+	var typed struct {
+		CheckType string `json:"checkType"`
+		Lists     []struct {
+			Name string `json:"name"`
+		} `json:"list"`
+		Rules []struct {
+			Field      string `json:"field"`
+			Conditions []struct {
+				Op      string `json:"op"`
+				Content string `json:"#text"`
+			} `json:"condition"`
+		} `json:"rule"`
+	}
+	require.NoError(t, model.Decode(&typed))
+	assert.Equal(t, "enhanced", typed.CheckType)
+	require.Len(t, typed.Lists, 2)
+	assert.Equal(t, "EU", typed.Lists[1].Name)
+	require.Len(t, typed.Rules, 1)
+	assert.Equal(t, "SY", typed.Rules[0].Conditions[1].Content)
+
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = context.NewCdslContextRepositoryUnitTestSupport()
+
+	outputEvent, err := executor.Execute(flow, types.NewCdslInputEvent())
+	require.NoError(t, err)
+	require.Contains(t, outputEvent.OutputValues, "sanctionsListsChecked")
+	assert.Equal(t, "OFAC,EU", outputEvent.OutputValues["sanctionsListsChecked"].Value)
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadKycFlowWithProperties loads kyc-flow.xml resolving placeholders with resolver
+func loadKycFlowWithProperties(t *testing.T, resolver registry.PropertyResolver) *dsl.MapModel {
+	doc, err := definitionsource.NewXmlDomDefinitionSource(filepath.Join("..", "..", "resources")).LoadDocument("kyc-flow.xml")
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	loader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	loader.SetPropertyResolver(resolver)
+	require.NoError(t, loader.LoadDocument(doc))
+
+	flow, err := flowRegistry.GetFlow("kycProcess")
+	require.NoError(t, err)
+	return flow.Steps["performAmlCheck"].LogicElements[1].Model.(*dsl.MapModel)
+}
+
+// TestPropertyPlaceholdersAreResolved tests that placeholders take the first resolver's value or their default
+func TestPropertyPlaceholdersAreResolved(t *testing.T) {
+	assert.Equal(t, "standard", loadKycFlowWithProperties(t, nil).GetString("checkLevel"))
+
+	fileResolver, err := registry.NewPropertiesFileResolver(fstest.MapFS{
+		"prod.properties": {Data: []byte("# production\nkyc.amlCheckLevel = enhanced\n! unused: value\n")},
+	}, "prod.properties")
+	require.NoError(t, err)
+	assert.Equal(t, "enhanced", loadKycFlowWithProperties(t, fileResolver).GetString("checkLevel"))
+
+	t.Setenv("KYC_AMLCHECKLEVEL", "basic")
+	chain := registry.NewChainPropertyResolver(registry.NewEnvPropertyResolver(), fileResolver)
+	assert.Equal(t, "basic", loadKycFlowWithProperties(t, chain).GetString("checkLevel"))
+
+	override := registry.NewMapPropertyResolver(map[string]string{"kyc.amlCheckLevel": "strict"})
+	chain = registry.NewChainPropertyResolver(override, registry.NewEnvPropertyResolver())
+	assert.Equal(t, "strict", loadKycFlowWithProperties(t, chain).GetString("checkLevel"))
+}
+
+// TestUnresolvedPropertiesAreListed tests that every placeholder without a value or default is reported at once
+func TestUnresolvedPropertiesAreListed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"kyc.xml": {Data: []byte(`<cdsl>
+    <flow id="kyc" defaultStep="init">
+        <step id="init">
+            <riskAssessment transactionValue="${kyc.limit}" countryCode="${kyc.country:US}"/>
+            <note>${kyc.note}</note>
+            <endRoute/>
+        </step>
+    </flow>
+</cdsl>`)},
+	}
+	docs, err := definitionsource.NewFSDefinitionSource(fsys).LoadAll()
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	loader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	loader.SetPropertyResolver(registry.NewMapPropertyResolver(map[string]string{"kyc.other": "x"}))
+
+	err = loader.LoadDocuments(docs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kyc.limit at kyc.xml:4:13, kyc.note at kyc.xml:5:13")
+	assert.NotContains(t, err.Error(), "kyc.country")
+
+	flow, _ := flowRegistry.GetFlow("kyc")
+	assert.Nil(t, flow)
+}
+
+// placeholderEscapesXml uses escaped placeholders and a fragment parameter named like a property
+const placeholderEscapesXml = `<cdsl>
+    <fragment id="greet">
+        <setVar name="greeting" val="${greeting} $${name}"/>
+    </fragment>
+    <flow id="escaped" defaultStep="init">
+        <step id="init">
+            <setVar name="template" val="$${kyc.level:standard} is ${kyc.level}"/>
+            <useFragment ref="greet" greeting="hello"/>
+            <endRoute/>
+        </step>
+    </flow>
+</cdsl>`
+
+// TestPlaceholderEscapesAndParameterShadowing tests that $${ stands for a literal ${ in fragments and flows, and
+// that a fragment parameter hides a property with the same name, including one from the environment
+func TestPlaceholderEscapesAndParameterShadowing(t *testing.T) {
+	doc, err := definitionsource.XmlDocumentParser{}.ParseDocument("escaped.xml", strings.NewReader(placeholderEscapesXml))
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	loader := registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	loader.SetPropertyResolver(registry.NewMapPropertyResolver(map[string]string{"kyc.level": "enhanced", "name": "unused"}))
+	require.NoError(t, loader.LoadDocument(doc))
+
+	flow, err := flowRegistry.GetFlow("escaped")
+	require.NoError(t, err)
+	elements := flow.Steps["init"].LogicElements
+	assert.Equal(t, "${kyc.level:standard} is enhanced", elements[0].Model.(*dsl.MapModel).GetString("val"))
+	assert.Equal(t, "hello ${name}", elements[1].Model.(*dsl.MapModel).GetString("val"))
+
+	t.Setenv("GREETING", "hi")
+	flowRegistry = registry.NewInMemoryFlowRegistry()
+	loader = registry.NewRegistryLoader(flowRegistry, dslInitHelper)
+	loader.SetPropertyResolver(registry.NewChainPropertyResolver(
+		registry.NewEnvPropertyResolver(),
+		registry.NewMapPropertyResolver(map[string]string{"kyc.level": "enhanced"}),
+	))
+	require.NoError(t, loader.LoadDocument(doc))
+	flow, err = flowRegistry.GetFlow("escaped")
+	require.NoError(t, err)
+	assert.Equal(t, "hello ${name}", flow.Steps["init"].LogicElements[1].Model.(*dsl.MapModel).GetString("val"))
+}
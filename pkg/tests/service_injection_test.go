@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecycleLog records the order services are initialised and closed in
+type lifecycleLog []string
+
+// denyingScreener is a sanctions screener that fails every customer from a country
+type denyingScreener struct {
+	country  string
+	requests []dsl.SanctionsScreeningRequest
+	log      *lifecycleLog
+}
+
+// Init implements registry.InitialisingService
+func (s *denyingScreener) Init() error {
+	*s.log = append(*s.log, "init screener")
+	return nil
+}
+
+// Close implements io.Closer
+func (s *denyingScreener) Close() error {
+	*s.log = append(*s.log, "close screener")
+	return nil
+}
+
+// Screen implements dsl.SanctionsScreener
+func (s *denyingScreener) Screen(request dsl.SanctionsScreeningRequest) (bool, error) {
+	s.requests = append(s.requests, request)
+	return request.CountryCode != s.country, nil
+}
+
+// auditClient is a service that fails to close
+type auditClient struct {
+	log *lifecycleLog
+}
+
+// Init implements registry.InitialisingService
+func (c *auditClient) Init() error {
+	*c.log = append(*c.log, "init audit")
+	return nil
+}
+
+// Close implements io.Closer
+func (c *auditClient) Close() error {
+	*c.log = append(*c.log, "close audit")
+	return errors.New("connection reset")
+}
+
+// auditedDsl needs the audit client and optionally a screener
+type auditedDsl struct {
+	dsl.DslSupport
+	Audit    *auditClient          `inject:"audit"`
+	Screener dsl.SanctionsScreener `inject:",optional"`
+}
+
+// Execute implements Dsl
+func (d *auditedDsl) Execute(runtime *context.CdslRuntime, ctx *context.CdslContext, model interface{}, input *types.CdslInputEvent) (*types.CdslOutputEvent, error) {
+	return nil, nil
+}
+
+// TestServicesAreInjectedIntoDsls tests that registered services reach the built-in KYC DSLs
+func TestServicesAreInjectedIntoDsls(t *testing.T) {
+	var lifecycle lifecycleLog
+	screener := &denyingScreener{country: "US", log: &lifecycle}
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	require.NoError(t, dslInitHelper.Services().Register("screener", screener))
+	require.NoError(t, dslInitHelper.Init())
+
+	doc, err := definitionsource.NewXmlDomDefinitionSource(filepath.Join("..", "..", "resources")).LoadDocument("kyc-flow.xml")
+	require.NoError(t, err)
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocument(doc))
+
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = context.NewCdslContextAuditorUnitTestSupport()
+	executor.ContextRepository = context.NewCdslContextRepositoryUnitTestSupport()
+
+	output, err := executor.ExecuteFlow("kycProcess", types.NewCdslInputEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "false", output.OutputValues["sanctionsCheckPassed"].Value)
+	require.Len(t, screener.requests, 1)
+	assert.Equal(t, "standard", screener.requests[0].CheckType)
+
+	require.NoError(t, dslInitHelper.Close())
+	assert.Equal(t, lifecycleLog{"init screener", "close screener"}, lifecycle)
+}
+
+// TestServiceLifecycle tests injection by name and type, and that services close in reverse order
+func TestServiceLifecycle(t *testing.T) {
+	var lifecycle lifecycleLog
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	dslInitHelper.RegisterDsl("audited", func() dsl.Dsl { return &auditedDsl{} })
+
+	err := dslInitHelper.Init()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DSL audited is missing a service")
+	assert.Contains(t, err.Error(), "No service named audit is registered")
+	assert.Nil(t, dslInitHelper.Resolve(types.DslMetadata{Name: "audited"}))
+
+	services := dslInitHelper.Services()
+	require.NoError(t, services.Register("audit", &auditClient{log: &lifecycle}))
+	require.NoError(t, services.Register("screener", &denyingScreener{log: &lifecycle}))
+	assert.Error(t, services.Register("audit", &auditClient{log: &lifecycle}))
+	require.NoError(t, dslInitHelper.Init())
+
+	resolved, ok := dslInitHelper.Resolve(types.DslMetadata{Name: "audited"}).(*auditedDsl)
+	require.True(t, ok)
+	assert.NotNil(t, resolved.Audit)
+	assert.NotNil(t, resolved.Screener)
+
+	screener, err := registry.ServiceOf[dsl.SanctionsScreener](services)
+	require.NoError(t, err)
+	assert.Same(t, resolved.Screener, screener)
+
+	require.NoError(t, services.Register("backupScreener", &denyingScreener{log: &lifecycle}))
+	_, err = registry.ServiceOf[dsl.SanctionsScreener](services)
+	assert.ErrorContains(t, err, "name one of screener, backupScreener")
+
+	err = dslInitHelper.Close()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Failed to close service audit")
+	assert.Equal(t, lifecycleLog{"init audit", "init screener", "close screener", "close audit"}, lifecycle)
+}
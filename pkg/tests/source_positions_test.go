@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rsqn/go-cdsl/pkg/concurrency"
+	"github.com/rsqn/go-cdsl/pkg/context"
+	"github.com/rsqn/go-cdsl/pkg/definitionsource"
+	"github.com/rsqn/go-cdsl/pkg/dsl"
+	"github.com/rsqn/go-cdsl/pkg/execution"
+	"github.com/rsqn/go-cdsl/pkg/registry"
+	"github.com/rsqn/go-cdsl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSourcePositionsReachTheRegistry tests that elements remember where they were declared
+func TestSourcePositionsReachTheRegistry(t *testing.T) {
+	resourcesDir := filepath.Join("..", "..", "resources")
+
+	for _, name := range []string{"kyc-flow.xml", "kyc-flow.yaml"} {
+		doc, err := definitionsource.NewFSDefinitionSource(os.DirFS(resourcesDir)).LoadDocument(name)
+		require.NoError(t, err)
+
+		flow, err := loadIntoRegistry(t, doc).GetFlow("kycProcess")
+		require.NoError(t, err)
+
+		step := flow.FetchStep("checkRiskLevel")
+		assert.Equal(t, name, step.Position.File)
+		assert.Greater(t, step.Position.Line, 0)
+
+		first, second := step.LogicElements[0].Position, step.LogicElements[1].Position
+		assert.Equal(t, name, first.File)
+		assert.Greater(t, second.Line, first.Line, name)
+	}
+}
+
+// TestRuntimeErrorsNameTheFailingElement tests that a failing element is identified by its position
+func TestRuntimeErrorsNameTheFailingElement(t *testing.T) {
+	fsys := fstest.MapFS{
+		"failing.xml": {Data: []byte(`<cdsl>
+    <flow id="failing" defaultStep="init">
+        <step id="init">
+            <setVar name="a" val="1"/>
+            <errorDsl/>
+            <setVar name="b" val="2"/>
+        </step>
+    </flow>
+</cdsl>`)},
+	}
+
+	doc, err := definitionsource.NewFSDefinitionSource(fsys).LoadDocument("failing.xml")
+	require.NoError(t, err)
+
+	dslInitHelper := registry.NewDslInitialisationHelper()
+	registerDSLs(t, dslInitHelper)
+	dslInitHelper.RegisterDsl("errorDsl", func() dsl.Dsl { return &dsl.ErrorDsl{} })
+	flowRegistry := registry.NewInMemoryFlowRegistry()
+	require.NoError(t, registry.NewRegistryLoader(flowRegistry, dslInitHelper).LoadDocument(doc))
+
+	auditor := &positionRecordingAuditor{CdslContextAuditorUnitTestSupport: context.NewCdslContextAuditorUnitTestSupport()}
+	executor := execution.NewFlowExecutor()
+	executor.FlowRegistry = flowRegistry
+	executor.DslInitHelper = dslInitHelper
+	executor.LockProvider = concurrency.NewLockProviderUnitTestSupport()
+	executor.Auditor = auditor
+	executor.ContextRepository = context.NewCdslContextRepositoryUnitTestSupport()
+
+	flow, err := flowRegistry.GetFlow("failing")
+	require.NoError(t, err)
+
+	_, err = executor.Execute(flow, types.NewCdslInputEvent())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failing.xml:5:13")
+	assert.Equal(t, types.SourcePosition{File: "failing.xml", Line: 5, Column: 13}, auditor.errorPosition)
+}
+
+// positionRecordingAuditor records the position passed with the last audited error
+type positionRecordingAuditor struct {
+	*context.CdslContextAuditorUnitTestSupport
+	errorPosition types.SourcePosition
+}
+
+// Error implements CdslContextAuditor
+func (a *positionRecordingAuditor) Error(ctx *context.CdslContext, flowID string, stepID string, dslName string, position types.SourcePosition, err error) {
+	a.errorPosition = position
+}
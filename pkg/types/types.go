@@ -1,9 +1,45 @@
 package types
 
+import (
+	"fmt"
+)
+
+// SourcePosition identifies where a definition was declared
+type SourcePosition struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsZero reports whether the position is unknown
+func (p SourcePosition) IsZero() bool {
+	return p.File == "" && p.Line == 0
+}
+
+// String formats the position as file:line:column, omitting parts that are unknown
+func (p SourcePosition) String() string {
+	if p.IsZero() {
+		return "<unknown>"
+	}
+	
+	file := p.File
+	if file == "" {
+		file = "<unknown>"
+	}
+	if p.Line == 0 {
+		return file
+	}
+	if p.Column == 0 {
+		return fmt.Sprintf("%s:%d", file, p.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", file, p.Line, p.Column)
+}
+
 // DslMetadata contains metadata about a DSL element
 type DslMetadata struct {
-	Name  string
-	Model interface{}
+	Name     string
+	Model    interface{}
+	Position SourcePosition
 }
 
 // Action represents the action to take after executing a DSL element